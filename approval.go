@@ -0,0 +1,223 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DefaultDenyMessage is returned to the model when an approver denies a call without a message.
+const DefaultDenyMessage = "The user denied this tool call."
+
+// ErrToolCallDenied is returned, wrapped, by InvokeToolAs when the approver denied
+// the call, since the denial message cannot be decoded as the tool's result.
+var ErrToolCallDenied = errors.New("工具调用被拒绝")
+
+// ApprovalRequest describes a tool call waiting for approval.
+type ApprovalRequest struct {
+	Server      string             // Server providing the tool
+	Tool        string             // Original MCP tool name
+	Arguments   map[string]any     // Arguments that will be sent, after tool overrides
+	Annotations mcp.ToolAnnotation // Hints declared by the server, such as DestructiveHint
+}
+
+// ApprovalDecision is the outcome of an approval request.
+type ApprovalDecision struct {
+	Approved  bool           // Whether the call may run
+	Message   string         // Returned to the model instead of a result when the call is denied
+	Arguments map[string]any // Replaces the arguments of an approved call if non-nil
+}
+
+// ToolApprover decides whether a tool call may run. An error aborts the call and is
+// returned to the caller, while a denial is reported to the model as the tool output.
+type ToolApprover interface {
+	Approve(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error)
+}
+
+// ToolApproverFunc adapts a function to the ToolApprover interface.
+type ToolApproverFunc func(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error)
+
+// Approve calls f(ctx, request).
+func (f ToolApproverFunc) Approve(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+	return f(ctx, request)
+}
+
+// WithToolApprover makes the hub ask approver before running any tool that is not
+// listed in the server's autoApprove setting. Without an approver every tool runs
+// without confirmation, as before.
+func WithToolApprover(approver ToolApprover) MCPHubOption {
+	return func(h *MCPHub) {
+		h.approver = approver
+	}
+}
+
+// DenyAllApprover returns an approver that denies every call that needs approval,
+// for unattended jobs that must only run auto-approved tools.
+//
+// Parameters:
+//   - message: Message returned to the model, DefaultDenyMessage if empty
+//
+// Returns:
+//   - ToolApprover: Approver that always denies
+func DenyAllApprover(message string) ToolApprover {
+	return ToolApproverFunc(func(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+		return ApprovalDecision{Message: message}, nil
+	})
+}
+
+// cliApprover prompts on a terminal for every call that needs approval.
+type cliApprover struct {
+	turn   chan struct{} // Held by the call whose prompt is shown, so prompts do not interleave
+	reader *bufio.Reader
+	out    io.Writer
+
+	// pending receives the line being read for a prompt. A read outlives the prompt
+	// of a cancelled call and then answers the next prompt. Protected by turn.
+	pending chan cliAnswer
+}
+
+// cliAnswer is a line read from the terminal.
+type cliAnswer struct {
+	line string
+	err  error
+}
+
+// NewCLIApprover returns an approver that prints the call to out and reads the answer
+// from in: "y" approves, "n" or an empty line denies, and a JSON object approves the
+// call with the object as its new arguments. A call whose context is done while it
+// waits for its prompt or answer returns the context error.
+//
+// Parameters:
+//   - in: Source of answers, usually os.Stdin
+//   - out: Destination of prompts, usually os.Stderr
+//
+// Returns:
+//   - ToolApprover: Interactive approver
+func NewCLIApprover(in io.Reader, out io.Writer) ToolApprover {
+	return &cliApprover{turn: make(chan struct{}, 1), reader: bufio.NewReader(in), out: out}
+}
+
+// Approve implements ToolApprover.
+func (a *cliApprover) Approve(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+	args, err := json.Marshal(request.Arguments)
+	if err != nil {
+		return ApprovalDecision{}, fmt.Errorf("序列化参数失败: %w", err)
+	}
+
+	select {
+	case a.turn <- struct{}{}:
+	case <-ctx.Done():
+		return ApprovalDecision{}, ctx.Err()
+	}
+	defer func() { <-a.turn }()
+
+	if a.pending != nil {
+		select {
+		case <-a.pending:
+			// 已取消的调用在提示之后得到的回答，不能用于这次调用
+			a.pending = nil
+		default:
+		}
+	}
+
+	warning := ""
+	if request.Annotations.DestructiveHint != nil && *request.Annotations.DestructiveHint {
+		warning = " [destructive]"
+	}
+	fmt.Fprintf(a.out, "Run tool %s/%s%s with %s? [y/N or JSON arguments]: ", request.Server, request.Tool, warning, args)
+
+	if a.pending == nil {
+		answer := make(chan cliAnswer, 1)
+		go func() {
+			line, err := a.reader.ReadString('\n')
+			answer <- cliAnswer{line: line, err: err}
+		}()
+		a.pending = answer
+	}
+
+	var answer cliAnswer
+	select {
+	case answer = <-a.pending:
+		a.pending = nil
+	case <-ctx.Done():
+		fmt.Fprintln(a.out, "cancelled")
+		return ApprovalDecision{}, ctx.Err()
+	}
+	if answer.err != nil && answer.line == "" {
+		return ApprovalDecision{}, fmt.Errorf("读取审批输入失败: %w", answer.err)
+	}
+	line := strings.TrimSpace(answer.line)
+
+	switch {
+	case strings.EqualFold(line, "y"), strings.EqualFold(line, "yes"):
+		return ApprovalDecision{Approved: true}, nil
+	case strings.HasPrefix(line, "{"):
+		var edited map[string]any
+		if err := json.Unmarshal([]byte(line), &edited); err != nil {
+			return ApprovalDecision{}, fmt.Errorf("解析修改后的参数失败: %w", err)
+		}
+		return ApprovalDecision{Approved: true, Arguments: edited}, nil
+	default:
+		return ApprovalDecision{}, nil
+	}
+}
+
+// needsApproval reports whether a call to the tool must be confirmed by the approver.
+// Entries of autoApprove accept the same patterns as allowedTools.
+func (h *MCPHub) needsApproval(config *ServerConfig, toolName string) bool {
+	if h.approver == nil {
+		return false
+	}
+	if config == nil {
+		return true
+	}
+	return !matchesAny(config.AutoApprove, func(string) string { return toolName })
+}
+
+// approveCall asks the approver about a call if the tool is not auto-approved.
+//
+// Returns:
+//   - map[string]any: Arguments to send, possibly edited by the approver
+//   - string: Message for the model if the call was denied, empty if approved
+//   - error: Error if the approver failed
+func (h *MCPHub) approveCall(ctx context.Context, prepared *preparedTool, args map[string]any) (map[string]any, string, error) {
+	if h.approver == nil {
+		return args, "", nil
+	}
+
+	// 从配置读取审批设置，延迟连接的服务器在审批之后才连接
+	h.mu.RLock()
+	config := h.config.MCPServers[prepared.server]
+	h.mu.RUnlock()
+	if !h.needsApproval(config, prepared.mcpTool.Name) {
+		return args, "", nil
+	}
+
+	decision, err := h.approver.Approve(ctx, ApprovalRequest{
+		Server:      prepared.server,
+		Tool:        prepared.mcpTool.Name,
+		Arguments:   args,
+		Annotations: prepared.mcpTool.Annotations,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("工具 %s/%s 审批失败: %w", prepared.server, prepared.mcpTool.Name, err)
+	}
+	if !decision.Approved {
+		if decision.Message == "" {
+			return nil, DefaultDenyMessage, nil
+		}
+		return nil, decision.Message, nil
+	}
+	if decision.Arguments != nil {
+		// 固定参数不允许被审批修改
+		args = prepared.override.applyArgs(decision.Arguments)
+	}
+	return args, "", nil
+}
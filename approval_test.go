@@ -0,0 +1,152 @@
+package einomcphost
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newApprovalHub 创建一个包含只读和破坏性工具的hub，read工具自动批准
+func newApprovalHub(t *testing.T, approver ToolApprover) *MCPHub {
+	t.Helper()
+
+	s := server.NewMCPServer("approval-test-server", "1.0.0")
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, _ := json.Marshal(request.GetArguments())
+		return mcp.NewToolResultText(request.Params.Name + " " + string(args)), nil
+	}
+	s.AddTool(mcp.NewTool("read", mcp.WithReadOnlyHintAnnotation(true), mcp.WithString("path")), handler)
+	s.AddTool(mcp.NewTool("delete", mcp.WithDestructiveHintAnnotation(true), mcp.WithString("path")), handler)
+
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"fs": {Transport: transportHTTP1, URL: newTestHTTPServer(t, s), AutoApprove: []string{"read"}},
+		},
+	}
+	hub, err := NewMCPHubFromSettings(context.Background(), settings, WithToolApprover(approver))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+	return hub
+}
+
+// TestToolApprover 测试审批钩子的批准、拒绝和修改参数
+func TestToolApprover(t *testing.T) {
+	ctx := context.Background()
+
+	var (
+		mu       sync.Mutex
+		requests []ApprovalRequest
+		decision ApprovalDecision
+	)
+	hub := newApprovalHub(t, ToolApproverFunc(func(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, request)
+		return decision, nil
+	}))
+
+	// 自动批准的工具不经过审批
+	result, err := hub.InvokeTool(ctx, "fs_read", map[string]any{"path": "/tmp"})
+	require.NoError(t, err)
+	assert.Equal(t, `read {"path":"/tmp"}`, result)
+	assert.Empty(t, requests)
+
+	// 拒绝时信息作为输出返回给模型
+	decision = ApprovalDecision{Message: "not allowed"}
+	result, err = hub.InvokeTool(ctx, "fs_delete", map[string]any{"path": "/"})
+	require.NoError(t, err)
+	assert.Equal(t, "not allowed", result)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "fs", requests[0].Server)
+	assert.Equal(t, "delete", requests[0].Tool)
+	assert.Equal(t, map[string]any{"path": "/"}, requests[0].Arguments)
+	require.NotNil(t, requests[0].Annotations.DestructiveHint)
+	assert.True(t, *requests[0].Annotations.DestructiveHint)
+
+	// 批准并修改参数
+	decision = ApprovalDecision{Approved: true, Arguments: map[string]any{"path": "/tmp/x"}}
+	result, err = hub.InvokeTool(ctx, "fs_delete", map[string]any{"path": "/"})
+	require.NoError(t, err)
+	assert.Equal(t, `delete {"path":"/tmp/x"}`, result)
+}
+
+// TestDenyAllApprover 测试无人值守时拒绝所有需要审批的调用
+func TestDenyAllApprover(t *testing.T) {
+	ctx := context.Background()
+	hub := newApprovalHub(t, DenyAllApprover(""))
+
+	result, err := hub.InvokeTool(ctx, "fs_delete", map[string]any{"path": "/"})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultDenyMessage, result)
+
+	_, err = hub.InvokeTool(ctx, "fs_read", map[string]any{"path": "/tmp"})
+	assert.NoError(t, err)
+}
+
+// TestCLIApprover 测试命令行审批的输入解析
+func TestCLIApprover(t *testing.T) {
+	ctx := context.Background()
+	var out bytes.Buffer
+	approver := NewCLIApprover(strings.NewReader("y\n\n{\"path\":\"/tmp\"}\n"), &out)
+	request := ApprovalRequest{Server: "fs", Tool: "delete", Arguments: map[string]any{"path": "/"}}
+
+	decision, err := approver.Approve(ctx, request)
+	require.NoError(t, err)
+	assert.True(t, decision.Approved)
+	assert.Contains(t, out.String(), `Run tool fs/delete with {"path":"/"}?`)
+
+	decision, err = approver.Approve(ctx, request)
+	require.NoError(t, err)
+	assert.False(t, decision.Approved)
+
+	decision, err = approver.Approve(ctx, request)
+	require.NoError(t, err)
+	assert.True(t, decision.Approved)
+	assert.Equal(t, map[string]any{"path": "/tmp"}, decision.Arguments)
+
+	// 输入结束时返回错误而不是默认批准
+	_, err = approver.Approve(ctx, request)
+	assert.Error(t, err)
+}
+
+// TestCLIApproverCancel 测试上下文结束时审批立即返回，等待提示的调用也一样
+func TestCLIApproverCancel(t *testing.T) {
+	in, answers := io.Pipe()
+	t.Cleanup(func() { answers.Close() })
+	approver := NewCLIApprover(in, io.Discard)
+	request := ApprovalRequest{Server: "fs", Tool: "delete"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := approver.Approve(ctx, request)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 未结束的调用占着终端时，排队的调用随上下文返回
+	decided := make(chan ApprovalDecision, 1)
+	go func() {
+		decision, err := approver.Approve(context.Background(), request)
+		assert.NoError(t, err)
+		decided <- decision
+	}()
+	turn := approver.(*cliApprover).turn
+	require.Eventually(t, func() bool { return len(turn) == 1 }, time.Second, time.Millisecond)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = approver.Approve(ctx, request)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// 已取消调用留下的读取回答下一个提示
+	_, err = io.WriteString(answers, "y\n")
+	require.NoError(t, err)
+	assert.True(t, (<-decided).Approved)
+}
@@ -3,7 +3,7 @@
 // for various MCP server implementations including stdio and SSE transports.
 //
 // The package supports:
-//   - JSON-based configuration files
+//   - JSON, YAML and TOML configuration files
 //   - Multiple transport types (stdio, SSE)
 //   - Server validation and timeout management
 //   - Graceful error handling for missing or invalid configurations
@@ -25,13 +25,19 @@ package einomcphost
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"math"
+	"net/http"
 	"net/url"
 	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mark3labs/mcp-go/client"
+	mcptransport "github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
 )
 
 // Timeout configuration constants define default and minimum timeout values
@@ -44,14 +50,43 @@ const (
 
 // Error message constants provide consistent error reporting
 const (
-	errMsgSettingsNil           = "settings cannot be nil"
-	errMsgTimeoutTooSmall       = "server %s: timeout must be at least %d seconds"
-	errMsgURLRequired           = "server %s: URL is required for SSE transport"
-	errMsgCommandRequired       = "server %s: command is required for stdio transport"
-	errMsgUnsupportedTransport  = "server %s: unsupported transport type: %s"
-	errMsgFailedToParseSettings = "failed to parse settings: %w"
-	errMsgInvalidSettings       = "invalid settings: %w"
-	errMsgFailedToReadFile      = "failed to read settings file: %w"
+	errMsgSettingsNil            = "settings cannot be nil"
+	errMsgTimeoutTooSmall        = "server %s: timeout must be at least %d seconds"
+	errMsgURLRequired            = "server %s: URL is required for SSE transport"
+	errMsgCommandRequired        = "server %s: command is required for stdio transport"
+	errMsgUnsupportedTransport   = "server %s: unsupported transport type: %s"
+	errMsgFailedToParseSettings  = "failed to parse settings: %w"
+	errMsgInvalidSettings        = "invalid settings: %w"
+	errMsgFailedToReadFile       = "failed to read settings file: %w"
+	errMsgToolTimeoutInvalid     = "server %s: timeout for tool %s must be positive"
+	errMsgToolOverrideConflict   = "server %s: tool overrides rename both %s and %s to %s"
+	errMsgInvalidToolPattern     = "server %s: invalid tool pattern %w"
+	errMsgInvalidHubToolPattern  = "invalid tool pattern %w"
+	errMsgInvalidDuration        = "invalid duration %s: expected seconds or a duration string such as \"90s\""
+	errMsgDurationOutOfRange     = "duration %s seconds is out of range: write it as a duration string such as \"90s\""
+	errMsgEnvExpansion           = "server %s: %w"
+	errMsgEnvRequired            = "environment variable %s: %s"
+	errMsgHeadersNotSupported    = "server %s: headers and bearerToken require the sse, http or streamable transport"
+	errMsgOAuthNotSupported      = "server %s: oauth requires the sse, http or streamable transport"
+	errMsgOAuthBearerToken       = "server %s: oauth and bearerToken cannot be combined"
+	errMsgOAuthClientID          = "server %s: oauth clientId is required"
+	errMsgOAuthRedirectURI       = "server %s: oauth redirectUri is required for the authorization_code grant"
+	errMsgOAuthClientSecret      = "server %s: oauth clientSecret is required for the client_credentials grant"
+	errMsgOAuthGrantType         = "server %s: unsupported oauth grantType: %s"
+	errMsgHTTPClientNotSupported = "server %s: proxy and tls require the sse, http or streamable transport"
+	errMsgInvalidProxy           = "server %s: invalid proxy: %w"
+	errMsgProcessNotSupported    = "server %s: cwd, inheritEnv, envAllowlist and limits require the stdio transport"
+	errMsgEnvAllowlist           = "server %s: envAllowlist cannot be combined with inheritEnv false"
+	errMsgInvalidEnvPattern      = "server %s: invalid envAllowlist pattern %s: %w"
+	errMsgProcessLimitInvalid    = "server %s: limits must not be negative"
+	errMsgTLSKeyPair             = "server %s: tls certFile and keyFile must be set together"
+	errMsgRestartNotSupported    = "server %s: restart requires the stdio transport"
+	errMsgRestartMode            = "server %s: unsupported restart mode: %s"
+	errMsgRestartInvalid         = "server %s: restart maxRestarts and window must not be negative"
+	errMsgIdleTimeoutInvalid     = "server %s: idleTimeout must not be negative"
+	errMsgManifestToolName       = "server %s: tools entries require a name"
+	errMsgCallLimitsInvalid      = "server %s: maxConcurrentCalls and maxQueueLength must not be negative"
+	errMsgQueueWithoutLimit      = "server %s: maxQueueLength requires maxConcurrentCalls"
 )
 
 // MCPSettings represents the main configuration structure for MCP servers.
@@ -61,7 +96,12 @@ const (
 // The configuration supports both enabled and disabled servers, with validation
 // ensuring that all required fields are present for enabled servers.
 type MCPSettings struct {
-	MCPServers map[string]*ServerConfig `json:"mcpServers"`
+	MCPServers map[string]*ServerConfig `json:"mcpServers" yaml:"mcpServers"`
+
+	// Hub-level tool filters applied to every server in addition to the server's own
+	// filters. Patterns containing a '/' are matched against "serverName/toolName".
+	AllowedTools  []string `json:"allowedTools,omitempty" yaml:"allowedTools,omitempty"`
+	ExcludedTools []string `json:"excludedTools,omitempty" yaml:"excludedTools,omitempty"`
 }
 
 // transport represents the type of transport used by an MCP server
@@ -88,24 +128,85 @@ const (
 // ref: https://github.com/mark3labs/mcphost/blob/4f2f61c6738417f74bc81c6910eebce632628569/internal/config/config.go
 type ServerConfig struct {
 	Transport   transport     `json:"transport,omitempty" yaml:"transport,omitempty" mapstructure:"transport"`         // "sse" or "stdio" or "http" (defaults to "stdio")
-	AutoApprove []string      `json:"autoApprove,omitempty" yaml:"auto_approve,omitempty" mapstructure:"auto_approve"` // List of auto-approved operations
+	AutoApprove []string      `json:"autoApprove,omitempty" yaml:"auto_approve,omitempty" mapstructure:"auto_approve"` // Tools that run without asking the ToolApprover, names or patterns
 	Disabled    bool          `json:"disabled,omitempty" yaml:"disabled,omitempty" mapstructure:"disabled"`            // Whether the server is disabled
-	Timeout     time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty" mapstructure:"timeout"`               // Operation timeout (defaults to 30s)
+	Timeout     time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty" mapstructure:"timeout"`               // Operation timeout, seconds or a duration string in JSON (defaults to 30s)
 
 	// SSE specific configuration
 	URL string `json:"url,omitempty" yaml:"url,omitempty" mapstructure:"url"` // Server URL for SSE transport
 
+	// HTTP headers sent with every request of the sse, http and streamable transports.
+	// BearerToken sets the Authorization header to "Bearer <token>", replacing any
+	// Authorization entry of Headers.
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty" mapstructure:"headers"`
+	BearerToken string            `json:"bearerToken,omitempty" yaml:"bearerToken,omitempty" mapstructure:"bearerToken"`
+
+	// OAuth authorizes requests of the sse, http and streamable transports with tokens
+	// obtained from the server's authorization server. Cannot be combined with BearerToken.
+	OAuth *OAuthConfig `json:"oauth,omitempty" yaml:"oauth,omitempty" mapstructure:"oauth"`
+
+	// Network settings of the sse, http and streamable transports. Proxy is an http,
+	// https or socks5 URL; TLS adds CAs, a client certificate for mTLS or disables
+	// certificate verification.
+	Proxy string     `json:"proxy,omitempty" yaml:"proxy,omitempty" mapstructure:"proxy"`
+	TLS   *TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty" mapstructure:"tls"`
+
 	// Stdio specific configuration
 	Command string            `json:"command" yaml:"command" mapstructure:"command"`         // Command to execute for stdio transport
 	Args    []string          `json:"args" yaml:"args" mapstructure:"args"`                  // Command arguments
 	Env     map[string]string `json:"env,omitempty" yaml:"env,omitempty" mapstructure:"env"` // Environment variables
 
-	// Tool configuration
+	// Process controls of the stdio transport. By default the process inherits the
+	// hub's environment; EnvAllowlist restricts it to matching variable names and
+	// InheritEnv false passes only Env.
+	Cwd          string         `json:"cwd,omitempty" yaml:"cwd,omitempty" mapstructure:"cwd"`                            // Working directory, defaults to the hub's
+	InheritEnv   *bool          `json:"inheritEnv,omitempty" yaml:"inheritEnv,omitempty" mapstructure:"inheritEnv"`       // Whether the hub's environment is inherited, defaults to true
+	EnvAllowlist []string       `json:"envAllowlist,omitempty" yaml:"envAllowlist,omitempty" mapstructure:"envAllowlist"` // Inherited variables, names or globs such as "LC_*"
+	Limits       *ProcessLimits `json:"limits,omitempty" yaml:"limits,omitempty" mapstructure:"limits"`                   // Resource limits of the process
+	Restart      *RestartPolicy `json:"restart,omitempty" yaml:"restart,omitempty" mapstructure:"restart"`                // When the process is restarted after it exits, defaults to the hub's policy
+
+	// Lazy connection. A lazy server whose tools are known from Tools or the hub's tool
+	// manifest registers them without connecting and connects on the first call; it
+	// is disconnected again after IdleTimeout without calls.
+	Lazy        *bool         `json:"lazy,omitempty" yaml:"lazy,omitempty" mapstructure:"lazy"`                      // Connect on first use, defaults to the hub's WithLazyConnect
+	IdleTimeout time.Duration `json:"idleTimeout,omitempty" yaml:"idleTimeout,omitempty" mapstructure:"idleTimeout"` // Idle time after which a lazy server is disconnected, defaults to the hub's WithIdleTimeout
+	Tools       []mcp.Tool    `json:"tools,omitempty" yaml:"tools,omitempty" mapstructure:"tools"`                   // Declared tools of the server, used instead of a discovery run
+
+	// Call limits. Calls beyond MaxConcurrentCalls wait in a queue of at most
+	// MaxQueueLength calls; once it is full calls fail with ErrServerOverloaded.
+	MaxConcurrentCalls int `json:"maxConcurrentCalls,omitempty" yaml:"maxConcurrentCalls,omitempty" mapstructure:"maxConcurrentCalls"` // Calls sent to the server at once, 0 for no limit
+	MaxQueueLength     int `json:"maxQueueLength,omitempty" yaml:"maxQueueLength,omitempty" mapstructure:"maxQueueLength"`             // Calls waiting for a free slot, 0 for no limit
+
+	// Tool configuration. Entries are tool names, globs such as "github_*" or
+	// regular expressions prefixed with "re:".
 	AllowedTools  []string `json:"allowedTools,omitempty" yaml:"allowedTools,omitempty"`   // Allowed tools for this server
 	ExcludedTools []string `json:"excludedTools,omitempty" yaml:"excludedTools,omitempty"` // Excluded tools for this server
 
+	// ToolTimeouts overrides Timeout for individual tools, keyed by the MCP tool name.
+	// Useful for long-running tools such as crawlers.
+	ToolTimeouts map[string]time.Duration `json:"toolTimeouts,omitempty" yaml:"toolTimeouts,omitempty"`
+
+	// ToolOverrides changes how individual tools are presented to the model, keyed by the MCP tool name.
+	ToolOverrides map[string]ToolOverride `json:"toolOverrides,omitempty" yaml:"toolOverrides,omitempty"`
+
 	// Inprocess specific configuration
 	inProcessClient *client.Client `json:"inprocessClient,omitempty" yaml:"inprocessClient,omitempty" mapstructure:"inprocessClient"` // MCP client implementation to be used for this server
+
+	expanded   bool                        // Set once ${VAR} references have been expanded
+	headerFunc mcptransport.HTTPHeaderFunc // Per-request headers, set on the resolved copy used to create the client
+	oauth      *oauthSession               // OAuth token source, set on the resolved copy used to create the client
+	client     *http.Client                // HTTP client with proxy and TLS settings, set on the resolved copy used to create the client
+	process    *stdioProcess               // Spawns and watches the stdio process, set on the resolved copy used to create the client
+}
+
+// ToolOverride customizes a single MCP tool without changing the server.
+// The tool is still called by its original MCP name.
+type ToolOverride struct {
+	Name              string         `json:"name,omitempty" yaml:"name,omitempty"`                           // Name exposed instead of the MCP tool name, before the naming strategy is applied
+	Description       string         `json:"description,omitempty" yaml:"description,omitempty"`             // Replaces the tool description
+	AppendDescription string         `json:"appendDescription,omitempty" yaml:"appendDescription,omitempty"` // Appended to the (possibly replaced) description
+	HiddenParams      []string       `json:"hiddenParams,omitempty" yaml:"hiddenParams,omitempty"`           // Parameters removed from the schema and from call arguments
+	FixedArgs         map[string]any `json:"fixedArgs,omitempty" yaml:"fixedArgs,omitempty"`                 // Arguments injected at call time and removed from the schema
 }
 
 // GetTimeoutDuration returns the timeout duration for the server.
@@ -121,6 +222,144 @@ func (c *ServerConfig) GetTimeoutDuration() time.Duration {
 	return c.Timeout
 }
 
+// GetToolTimeout returns the timeout for calls to the given MCP tool.
+// A per-tool entry in ToolTimeouts takes precedence over the server timeout.
+//
+// Parameters:
+//   - toolName: Original MCP name of the tool
+//
+// Returns:
+//   - time.Duration: The timeout to apply to the tool call
+func (c *ServerConfig) GetToolTimeout(toolName string) time.Duration {
+	if timeout, ok := c.ToolTimeouts[toolName]; ok && timeout > 0 {
+		return timeout
+	}
+	return c.GetTimeoutDuration()
+}
+
+// serverConfigAlias has the fields of ServerConfig without its JSON methods.
+type serverConfigAlias ServerConfig
+
+// UnmarshalJSON decodes a server configuration.
+// The timeout, idleTimeout and toolTimeouts fields accept either a number of
+// seconds or a Go duration string such as "90s". For backward compatibility, an
+// integer timeout of at least MinMCPTimeoutSeconds billion is read as the
+// nanoseconds older versions wrote; see parseLegacyTimeout.
+func (c *ServerConfig) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*serverConfigAlias
+		Timeout      json.RawMessage            `json:"timeout,omitempty"`
+		IdleTimeout  json.RawMessage            `json:"idleTimeout,omitempty"`
+		ToolTimeouts map[string]json.RawMessage `json:"toolTimeouts,omitempty"`
+	}{serverConfigAlias: (*serverConfigAlias)(c)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	if len(aux.Timeout) > 0 {
+		timeout, err := parseLegacyTimeout(aux.Timeout)
+		if err != nil {
+			return fmt.Errorf("timeout: %w", err)
+		}
+		c.Timeout = timeout
+	}
+
+	if len(aux.IdleTimeout) > 0 {
+		idleTimeout, err := parseJSONDuration(aux.IdleTimeout)
+		if err != nil {
+			return fmt.Errorf("idleTimeout: %w", err)
+		}
+		c.IdleTimeout = idleTimeout
+	}
+
+	if aux.ToolTimeouts != nil {
+		c.ToolTimeouts = make(map[string]time.Duration, len(aux.ToolTimeouts))
+		for name, raw := range aux.ToolTimeouts {
+			timeout, err := parseJSONDuration(raw)
+			if err != nil {
+				return fmt.Errorf("toolTimeouts.%s: %w", name, err)
+			}
+			c.ToolTimeouts[name] = timeout
+		}
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes a server configuration, writing timeouts as duration
+// strings so that the output can be loaded again by UnmarshalJSON.
+func (c ServerConfig) MarshalJSON() ([]byte, error) {
+	aux := struct {
+		serverConfigAlias
+		Timeout      string            `json:"timeout,omitempty"`
+		IdleTimeout  string            `json:"idleTimeout,omitempty"`
+		ToolTimeouts map[string]string `json:"toolTimeouts,omitempty"`
+	}{serverConfigAlias: serverConfigAlias(c)}
+
+	if c.Timeout != 0 {
+		aux.Timeout = c.Timeout.String()
+	}
+	if c.IdleTimeout != 0 {
+		aux.IdleTimeout = c.IdleTimeout.String()
+	}
+	if len(c.ToolTimeouts) > 0 {
+		aux.ToolTimeouts = make(map[string]string, len(c.ToolTimeouts))
+		for name, timeout := range c.ToolTimeouts {
+			aux.ToolTimeouts[name] = timeout.String()
+		}
+	}
+
+	return json.Marshal(aux)
+}
+
+// parseJSONDuration parses a JSON number of seconds or a JSON duration string.
+func parseJSONDuration(raw json.RawMessage) (time.Duration, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		text = strings.TrimSpace(text)
+		if seconds, err := strconv.ParseFloat(text, 64); err == nil {
+			return secondsToDuration(seconds)
+		}
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return 0, fmt.Errorf(errMsgInvalidDuration, strconv.Quote(text))
+		}
+		return d, nil
+	}
+
+	var seconds float64
+	if err := json.Unmarshal(raw, &seconds); err != nil {
+		return 0, fmt.Errorf(errMsgInvalidDuration, string(raw))
+	}
+	return secondsToDuration(seconds)
+}
+
+// parseLegacyTimeout parses the timeout field, which older versions decoded
+// directly into time.Duration and therefore wrote as a JSON integer of
+// nanoseconds. Such a value is told apart by its form and range rather than
+// guessed: a valid timeout is at least MinMCPTimeoutSeconds, so as nanoseconds it
+// is an integer of at least MinMCPTimeoutSeconds billion, while the same integer
+// as seconds would be over 150 years. Any other value is parsed by
+// parseJSONDuration.
+func parseLegacyTimeout(raw json.RawMessage) (time.Duration, error) {
+	const legacyMinNanoseconds = MinMCPTimeoutSeconds * int64(time.Second)
+	var nanoseconds int64
+	if err := json.Unmarshal(raw, &nanoseconds); err == nil && nanoseconds >= legacyMinNanoseconds {
+		return time.Duration(nanoseconds), nil
+	}
+	return parseJSONDuration(raw)
+}
+
+// secondsToDuration converts seconds to a duration, rejecting values that
+// overflow time.Duration.
+func secondsToDuration(seconds float64) (time.Duration, error) {
+	if math.Abs(seconds) > float64(math.MaxInt64)/float64(time.Second) {
+		return 0, fmt.Errorf(errMsgDurationOutOfRange, strconv.FormatFloat(seconds, 'g', -1, 64))
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
 // IsSSETransport returns true if the server uses SSE transport.
 // This method provides a convenient way to check the transport type
 // without string comparison throughout the codebase.
@@ -151,8 +390,10 @@ func (c *ServerConfig) IsStdioTransport() bool {
 	return c.Transport == transportStdio || c.Transport == ""
 }
 
-// LoadSettingsFromString loads MCP settings from a JSON configuration string.
-// It parses the JSON and validates the resulting configuration to ensure
+// LoadSettingsFromString loads MCP settings from a JSON, YAML or TOML configuration string.
+// The format is detected from the content: JSON starts with '{', TOML starts with a
+// table header or a "key = value" line, and anything else is read as YAML.
+// It parses the configuration and validates the result to ensure
 // all required fields are present and valid.
 //
 // The function handles empty strings gracefully by returning an empty but
@@ -173,16 +414,36 @@ func (c *ServerConfig) IsStdioTransport() bool {
 //		log.Fatal(err)
 //	}
 func LoadSettingsFromString(data string) (*MCPSettings, error) {
+	return loadSettings(data, "")
+}
+
+// loadSettings parses and validates settings in the given format, sniffing the
+// format from the content if it is empty. YAML and TOML are converted to JSON first,
+// so all formats share the JSON decoding and produce identical settings.
+func loadSettings(data string, format configFormat) (*MCPSettings, error) {
 	dataStr := strings.TrimSpace(data)
 	if dataStr == "" {
 		return &MCPSettings{MCPServers: make(map[string]*ServerConfig)}, nil
 	}
+	if format == "" {
+		format = sniffFormat(dataStr)
+	}
+
+	jsonData, err := toJSON(dataStr, format)
+	if err != nil {
+		return nil, fmt.Errorf(errMsgFailedToParseSettings, err)
+	}
 
 	var settings MCPSettings
-	if err := json.Unmarshal([]byte(dataStr), &settings); err != nil {
+	if err := json.Unmarshal(jsonData, &settings); err != nil {
 		return nil, fmt.Errorf(errMsgFailedToParseSettings, err)
 	}
 
+	// 缺少必需的环境变量视为校验错误
+	if err := ExpandSettings(&settings); err != nil {
+		return nil, fmt.Errorf(errMsgInvalidSettings, err)
+	}
+
 	if err := validateSettings(&settings); err != nil {
 		return nil, fmt.Errorf(errMsgInvalidSettings, err)
 	}
@@ -191,11 +452,13 @@ func LoadSettingsFromString(data string) (*MCPSettings, error) {
 }
 
 // LoadSettings loads MCP settings from a configuration file.
-// It reads the file and delegates to LoadSettingsFromString for parsing.
-// The function logs the file path being loaded for debugging purposes.
+// The format is chosen by the file extension: .json, .yaml/.yml or .toml.
+// Files with any other extension are detected from their content like
+// LoadSettingsFromString. The function logs the file path being loaded for
+// debugging purposes.
 //
 // Parameters:
-//   - path: Path to the configuration file (JSON format expected)
+//   - path: Path to the configuration file
 //
 // Returns:
 //   - *MCPSettings: Parsed and validated settings from the file
@@ -208,14 +471,14 @@ func LoadSettingsFromString(data string) (*MCPSettings, error) {
 //		log.Fatal(err)
 //	}
 func LoadSettings(path string) (*MCPSettings, error) {
-	log.Printf("Loading MCP settings from: %s", path)
+	logf("Loading MCP settings from: %s", path)
 
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf(errMsgFailedToReadFile, err)
 	}
 
-	return LoadSettingsFromString(string(data))
+	return loadSettings(string(data), formatFromPath(path))
 }
 
 // validateSettings validates the MCP settings configuration.
@@ -243,6 +506,10 @@ func validateSettings(settings *MCPSettings) error {
 		settings.MCPServers = make(map[string]*ServerConfig)
 	}
 
+	if err := validateToolPatterns(append(slices.Clone(settings.AllowedTools), settings.ExcludedTools...)); err != nil {
+		return fmt.Errorf(errMsgInvalidHubToolPattern, err)
+	}
+
 	// Validate each server configuration
 	for name, server := range settings.MCPServers {
 		if err := validateServerConfig(name, server); err != nil {
@@ -253,12 +520,35 @@ func validateSettings(settings *MCPSettings) error {
 	return nil
 }
 
+// validateToolOverrides rejects overrides that rename two tools of a server to the same name.
+func validateToolOverrides(name string, overrides map[string]ToolOverride) error {
+	toolNames := make([]string, 0, len(overrides))
+	for toolName := range overrides {
+		toolNames = append(toolNames, toolName)
+	}
+	sort.Strings(toolNames)
+
+	renamed := make(map[string]string)
+	for _, toolName := range toolNames {
+		newName := overrides[toolName].Name
+		if newName == "" {
+			continue
+		}
+		if previous, ok := renamed[newName]; ok {
+			return fmt.Errorf(errMsgToolOverrideConflict, name, previous, toolName, newName)
+		}
+		renamed[newName] = toolName
+	}
+	return nil
+}
+
 // validateServerConfig validates a single server configuration.
 // It performs transport-specific validation and ensures all required
 // fields are present and valid for the specified transport type.
 //
 // Validation rules:
 //   - Timeout must be at least MinMCPTimeoutSeconds if specified
+//   - Per-tool timeouts must be positive
 //   - SSE transport requires a non-empty URL
 //   - Stdio transport requires a non-empty Command
 //   - Unknown transport types are rejected
@@ -274,6 +564,17 @@ func validateServerConfig(name string, server *ServerConfig) error {
 	if server.Timeout > 0 && server.Timeout < time.Duration(MinMCPTimeoutSeconds)*time.Second {
 		return fmt.Errorf(errMsgTimeoutTooSmall, name, MinMCPTimeoutSeconds)
 	}
+	for toolName, timeout := range server.ToolTimeouts {
+		if timeout <= 0 {
+			return fmt.Errorf(errMsgToolTimeoutInvalid, name, toolName)
+		}
+	}
+	if err := validateToolOverrides(name, server.ToolOverrides); err != nil {
+		return err
+	}
+	if err := validateToolPatterns(append(slices.Clone(server.AllowedTools), server.ExcludedTools...)); err != nil {
+		return fmt.Errorf(errMsgInvalidToolPattern, name, err)
+	}
 
 	// Validate transport-specific requirements
 	switch server.Transport {
@@ -303,5 +604,27 @@ func validateServerConfig(name string, server *ServerConfig) error {
 		return fmt.Errorf(errMsgUnsupportedTransport, name, server.Transport)
 	}
 
+	if server.Transport == transportStdio && (len(server.Headers) > 0 || server.BearerToken != "") {
+		return fmt.Errorf(errMsgHeadersNotSupported, name)
+	}
+	if err := validateOAuthConfig(name, server); err != nil {
+		return err
+	}
+	if err := validateHTTPClientConfig(name, server); err != nil {
+		return err
+	}
+	if err := validateProcessConfig(name, server); err != nil {
+		return err
+	}
+	if err := validateRestartPolicy(name, server); err != nil {
+		return err
+	}
+	if err := validateLazyConfig(name, server); err != nil {
+		return err
+	}
+	if err := validateCallLimits(name, server); err != nil {
+		return err
+	}
+
 	return nil
 }
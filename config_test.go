@@ -1,10 +1,12 @@
 package einomcphost
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // 测试ServerConfig的GetTimeoutDuration方法
@@ -480,3 +482,71 @@ func TestStreamableTransportAutoDetection(t *testing.T) {
 		})
 	}
 }
+
+// TestServerConfigTimeoutJSON 测试timeout字段同时支持秒数和时长字符串
+func TestServerConfigTimeoutJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  string
+		expected time.Duration
+	}{
+		{name: "integer seconds", timeout: `90`, expected: 90 * time.Second},
+		{name: "fractional seconds", timeout: `7.5`, expected: 7500 * time.Millisecond},
+		{name: "duration string", timeout: `"2m30s"`, expected: 150 * time.Second},
+		{name: "numeric string", timeout: `"45"`, expected: 45 * time.Second},
+		{name: "legacy nanoseconds", timeout: `30000000000`, expected: 30 * time.Second},
+		{name: "legacy minimum nanoseconds", timeout: `5000000000`, expected: 5 * time.Second},
+		{name: "legacy nanoseconds below overflow", timeout: `9000000000`, expected: 9 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, err := LoadSettingsFromString(`{"mcpServers": {"s": {"command": "echo", "timeout": ` + tt.timeout + `}}}`)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, settings.MCPServers["s"].Timeout)
+		})
+	}
+
+	_, err := LoadSettingsFromString(`{"mcpServers": {"s": {"command": "echo", "timeout": "soon"}}}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid duration")
+
+	// 只有timeout字段曾以纳秒写入，其他字段的数字始终是秒数，溢出时报错
+	_, err = LoadSettingsFromString(`{"mcpServers": {"s": {"command": "echo", "toolTimeouts": {"t": 5000000000}}}}`)
+	assert.NoError(t, err)
+	_, err = LoadSettingsFromString(`{"mcpServers": {"s": {"command": "echo", "toolTimeouts": {"t": 30000000000}}}}`)
+	assert.ErrorContains(t, err, "out of range")
+	_, err = LoadSettingsFromString(`{"mcpServers": {"s": {"command": "echo", "timeout": "30000000000"}}}`)
+	assert.ErrorContains(t, err, "out of range")
+}
+
+// TestServerConfigToolTimeouts 测试按工具覆盖超时时间
+func TestServerConfigToolTimeouts(t *testing.T) {
+	settings, err := LoadSettingsFromString(`{
+		"mcpServers": {
+			"crawler": {
+				"command": "crawler",
+				"timeout": 20,
+				"toolTimeouts": {"crawl": "10m", "fetch": 120}
+			}
+		}
+	}`)
+	require.NoError(t, err)
+
+	config := settings.MCPServers["crawler"]
+	assert.Equal(t, 10*time.Minute, config.GetToolTimeout("crawl"))
+	assert.Equal(t, 2*time.Minute, config.GetToolTimeout("fetch"))
+	assert.Equal(t, 20*time.Second, config.GetToolTimeout("other"))
+
+	// 序列化后可以重新加载
+	data, err := json.Marshal(settings)
+	require.NoError(t, err)
+	reloaded, err := LoadSettingsFromString(string(data))
+	require.NoError(t, err)
+	assert.Equal(t, config.Timeout, reloaded.MCPServers["crawler"].Timeout)
+	assert.Equal(t, config.ToolTimeouts, reloaded.MCPServers["crawler"].ToolTimeouts)
+
+	_, err = LoadSettingsFromString(`{"mcpServers": {"s": {"command": "echo", "toolTimeouts": {"t": 0}}}}`)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "must be positive")
+}
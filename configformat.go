@@ -0,0 +1,140 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// configFormat is the syntax of a configuration file.
+type configFormat string
+
+// Configuration file formats
+const (
+	formatJSON configFormat = "json"
+	formatYAML configFormat = "yaml"
+	formatTOML configFormat = "toml"
+)
+
+// tomlKeyLine matches a TOML table header or key/value line such as `[mcpServers.fs]`
+// or `command = "npx"`. YAML uses ':' instead of '=' and only uses '[' for flow sequences
+// inside values.
+var tomlKeyLine = regexp.MustCompile(`^(\[|[A-Za-z0-9_."'-]+\s*=)`)
+
+// serverKeyAliases maps the yaml tags of ServerConfig that differ from its JSON names.
+var serverKeyAliases = map[string]string{
+	"auto_approve": "autoApprove",
+}
+
+// formatFromPath picks the format of a configuration file by its extension.
+// Unknown extensions return an empty format, meaning the content is sniffed.
+func formatFromPath(path string) configFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return formatJSON
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	default:
+		return ""
+	}
+}
+
+// sniffFormat guesses the format of configuration content. JSON documents start
+// with '{'; otherwise the first line that is neither blank nor a comment decides
+// between TOML and YAML.
+func sniffFormat(data string) configFormat {
+	if strings.HasPrefix(data, "{") {
+		return formatJSON
+	}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if tomlKeyLine.MatchString(line) {
+			return formatTOML
+		}
+		return formatYAML
+	}
+	return formatYAML
+}
+
+// toJSON converts a YAML or TOML document to JSON with the same structure, so every
+// format is decoded by the same JSON path, including the duration parsing of
+// ServerConfig, and produces identical settings.
+func toJSON(data string, format configFormat) ([]byte, error) {
+	var doc map[string]any
+	switch format {
+	case formatYAML:
+		if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+			return nil, err
+		}
+	case formatTOML:
+		if err := toml.Unmarshal([]byte(data), &doc); err != nil {
+			return nil, err
+		}
+	default:
+		return []byte(data), nil
+	}
+
+	if doc == nil {
+		doc = map[string]any{}
+	}
+	normalizeValue(doc)
+	if servers, ok := doc["mcpServers"].(map[string]any); ok {
+		for _, server := range servers {
+			if server, ok := server.(map[string]any); ok {
+				renameKeys(server, serverKeyAliases)
+			}
+		}
+	}
+	return json.Marshal(doc)
+}
+
+// normalizeValue converts YAML mappings with non-string keys, which encoding/json
+// cannot encode, to map[string]any. Maps and slices are updated in place.
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = normalizeValue(item)
+		}
+		return v
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalizeValue(item)
+		}
+		return m
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// renameKeys renames alias keys of a mapping to their canonical name unless the
+// canonical key is present as well.
+func renameKeys(m map[string]any, aliases map[string]string) {
+	for alias, canonical := range aliases {
+		value, ok := m[alias]
+		if !ok {
+			continue
+		}
+		delete(m, alias)
+		if _, exists := m[canonical]; !exists {
+			m[canonical] = value
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package einomcphost
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const formatTestJSON = `{
+	"allowedTools": ["fs/*", "web/*"],
+	"mcpServers": {
+		"fs": {
+			"command": "npx",
+			"args": ["-y", "@modelcontextprotocol/server-filesystem", "/tmp"],
+			"env": {"DEBUG": "1"},
+			"timeout": "90s",
+			"autoApprove": ["read_*"],
+			"toolTimeouts": {"search": 120}
+		},
+		"web": {
+			"url": "http://localhost:8080/mcp",
+			"disabled": true,
+			"toolOverrides": {"fetch": {"name": "get_page", "hiddenParams": ["raw"]}}
+		}
+	}
+}`
+
+const formatTestYAML = `# 运维统一使用YAML配置
+allowedTools: ["fs/*", "web/*"]
+mcpServers:
+  fs:
+    command: npx
+    args: [-y, "@modelcontextprotocol/server-filesystem", /tmp]
+    env:
+      DEBUG: "1"
+    timeout: 90s
+    auto_approve:   # yaml标签中的名称
+      - read_*
+    toolTimeouts:
+      search: 120
+  web:
+    url: http://localhost:8080/mcp
+    disabled: true
+    toolOverrides:
+      fetch:
+        name: get_page
+        hiddenParams: [raw]
+`
+
+const formatTestTOML = `# TOML同样支持注释
+allowedTools = ["fs/*", "web/*"]
+
+[mcpServers.fs]
+command = "npx"
+args = ["-y", "@modelcontextprotocol/server-filesystem", "/tmp"]
+env = { DEBUG = "1" }
+timeout = "90s"
+autoApprove = ["read_*"]
+toolTimeouts = { search = 120 }
+
+[mcpServers.web]
+url = "http://localhost:8080/mcp"
+disabled = true
+
+[mcpServers.web.toolOverrides.fetch]
+name = "get_page"
+hiddenParams = ["raw"]
+`
+
+// TestLoadSettingsFormats 测试JSON、YAML和TOML配置得到相同的结果
+func TestLoadSettingsFormats(t *testing.T) {
+	expected, err := LoadSettingsFromString(formatTestJSON)
+	require.NoError(t, err)
+	assert.Equal(t, 90*time.Second, expected.MCPServers["fs"].Timeout)
+	assert.Equal(t, transportHTTP1, expected.MCPServers["web"].Transport)
+
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		file    string
+		content string
+	}{
+		{"mcpservers.yaml", formatTestYAML},
+		{"mcpservers.yml", formatTestYAML},
+		{"mcpservers.toml", formatTestTOML},
+		{"mcpservers.conf", formatTestTOML}, // 未知扩展名按内容识别
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o644))
+			settings, err := LoadSettings(path)
+			require.NoError(t, err)
+			assert.Equal(t, expected, settings)
+		})
+	}
+
+	// 从字符串加载时按内容识别格式
+	for name, content := range map[string]string{"yaml": formatTestYAML, "toml": formatTestTOML} {
+		settings, err := LoadSettingsFromString(content)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, settings, name)
+	}
+}
+
+// TestLoadSettingsFormatErrors 测试各格式的语法和校验错误
+func TestLoadSettingsFormatErrors(t *testing.T) {
+	assert.Equal(t, formatJSON, sniffFormat(`{"mcpServers": {}}`))
+	assert.Equal(t, formatTOML, sniffFormat("# comment\n[mcpServers.fs]\ncommand = \"npx\""))
+	assert.Equal(t, formatYAML, sniffFormat("mcpServers:\n  fs:\n    command: npx"))
+
+	_, err := LoadSettingsFromString("mcpServers:\n  fs: [unclosed")
+	assert.ErrorContains(t, err, "failed to parse settings")
+
+	_, err = LoadSettingsFromString("[mcpServers.fs]\ncommand = ")
+	assert.ErrorContains(t, err, "failed to parse settings")
+
+	// 所有格式经过相同的校验
+	_, err = LoadSettingsFromString("mcpServers:\n  fs:\n    transport: sse\n")
+	assert.ErrorContains(t, err, "invalid settings")
+
+	// 扩展名决定格式，JSON文件中的YAML内容报错
+	path := filepath.Join(t.TempDir(), "mcpservers.json")
+	require.NoError(t, os.WriteFile(path, []byte(formatTestYAML), 0o644))
+	_, err = LoadSettings(path)
+	assert.Error(t, err)
+}
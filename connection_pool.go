@@ -3,11 +3,15 @@ package einomcphost
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/mark3labs/mcp-go/client"
 )
 
 // ConnectionPool 用于管理MCP服务器连接池，确保每个服务器只连接一次
@@ -20,6 +24,9 @@ type ConnectionPool struct {
 	isCleaning  bool                 // 是否正在执行清理
 	maxIdleTime time.Duration        // 最大空闲时间
 	serverHub   map[string]string    // 服务器名称到配置键的映射，用于快速查找
+
+	clientsMu   sync.Mutex                                // 保护clientUsers，可以在持有hub的锁时获取
+	clientUsers map[client.MCPClient]map[*MCPHub]struct{} // 被多个hub共享的客户端及使用它的hub
 }
 
 var (
@@ -45,6 +52,7 @@ func NewConnectionPool() *ConnectionPool {
 		cleanupDone: make(chan struct{}),
 		maxIdleTime: 30 * time.Minute,        // 默认30分钟无访问则清理
 		serverHub:   make(map[string]string), // 初始化服务器名称到配置键的映射
+		clientUsers: make(map[client.MCPClient]map[*MCPHub]struct{}),
 	}
 
 	// 启动清理协程
@@ -60,32 +68,22 @@ func generateConfigKey(settings *MCPSettings) string {
 		return "empty_config"
 	}
 
+	// 按名称排序，相同的配置总是生成相同的键
+	names := make([]string, 0, len(settings.MCPServers))
+	for name := range settings.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
 	var keyBuilder strings.Builder
 
 	// 构建包含所有服务器信息的键
-	for name, config := range settings.MCPServers {
+	for _, name := range names {
+		config := settings.MCPServers[name]
 		if config.Disabled {
 			continue // 忽略已禁用的服务器
 		}
-
-		// 根据传输类型构建不同的键
-		keyBuilder.WriteString(fmt.Sprintf("|%s:", name))
-
-		switch config.Transport {
-		case transportSSE:
-			keyBuilder.WriteString(fmt.Sprintf("SSE:%s", config.URL))
-		case transportHTTP1, transportHTTPStreamable:
-			keyBuilder.WriteString(fmt.Sprintf("HTTP:%s", config.URL))
-		case transportStdio, "":
-			keyBuilder.WriteString(fmt.Sprintf("STDIO:%s:", config.Command))
-			// 添加参数
-			for i, arg := range config.Args {
-				if i > 0 {
-					keyBuilder.WriteString(",")
-				}
-				keyBuilder.WriteString(arg)
-			}
-		}
+		keyBuilder.WriteString(serverConfigKey(name, config))
 	}
 
 	key := keyBuilder.String()
@@ -96,6 +94,85 @@ func generateConfigKey(settings *MCPSettings) string {
 	return key
 }
 
+// serverConfigKey 生成单个服务器的配置键，连接参数相同的服务器键相同
+func serverConfigKey(name string, config *ServerConfig) string {
+	var keyBuilder strings.Builder
+
+	// 根据传输类型构建不同的键
+	keyBuilder.WriteString(fmt.Sprintf("|%s:", name))
+
+	switch config.Transport {
+	case transportSSE:
+		keyBuilder.WriteString(fmt.Sprintf("SSE:%s", config.URL))
+		keyBuilder.WriteString(credentialsKey(config))
+		keyBuilder.WriteString(httpClientKey(config))
+	case transportHTTP1, transportHTTPStreamable:
+		keyBuilder.WriteString(fmt.Sprintf("HTTP:%s", config.URL))
+		keyBuilder.WriteString(credentialsKey(config))
+		keyBuilder.WriteString(httpClientKey(config))
+	case transportStdio, "":
+		keyBuilder.WriteString(fmt.Sprintf("STDIO:%s:", config.Command))
+		// 添加参数
+		for i, arg := range config.Args {
+			if i > 0 {
+				keyBuilder.WriteString(",")
+			}
+			keyBuilder.WriteString(arg)
+		}
+		keyBuilder.WriteString(processKey(config))
+	}
+
+	return keyBuilder.String()
+}
+
+// credentialsKey 返回请求头和OAuth客户端的摘要，凭据不同的服务器不共享连接，且键中不出现凭据本身
+func credentialsKey(config *ServerConfig) string {
+	if len(config.Headers) == 0 && config.BearerToken == "" && config.OAuth == nil {
+		return ""
+	}
+	hash := sha256.New()
+	for _, name := range sortedKeys(config.Headers) {
+		fmt.Fprintf(hash, "%s=%s\n", name, config.Headers[name])
+	}
+	fmt.Fprintf(hash, "bearer=%s", config.BearerToken)
+	if config.OAuth != nil {
+		fmt.Fprintf(hash, "\noauth=%s:%s:%s", config.OAuth.grantType(), config.OAuth.ClientID, strings.Join(config.OAuth.Scopes, " "))
+	}
+	return ":credentials=" + hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// httpClientKey 返回代理和TLS设置的摘要，安全设置不同的服务器不共享连接，代理中的密码不出现在键中
+func httpClientKey(config *ServerConfig) string {
+	if config.Proxy == "" && config.TLS == nil {
+		return ""
+	}
+	hash := sha256.New()
+	fmt.Fprintf(hash, "proxy=%s\n", config.Proxy)
+	if tls := config.TLS; tls != nil {
+		fmt.Fprintf(hash, "tls=%s:%s:%s:%s:%t", tls.CAFile, tls.CertFile, tls.KeyFile, tls.ServerName, tls.InsecureSkipVerify)
+	}
+	return ":client=" + hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// processKey 返回进程设置的摘要，工作目录、环境变量或资源限制不同的服务器不共享进程，且键中不出现环境变量的值
+func processKey(config *ServerConfig) string {
+	if config.Cwd == "" && len(config.Env) == 0 && config.InheritEnv == nil && len(config.EnvAllowlist) == 0 && config.Limits == nil {
+		return ""
+	}
+	hash := sha256.New()
+	for _, name := range sortedKeys(config.Env) {
+		fmt.Fprintf(hash, "env:%s=%s\n", name, config.Env[name])
+	}
+	fmt.Fprintf(hash, "cwd=%s\nallow=%s\n", config.Cwd, strings.Join(config.EnvAllowlist, ","))
+	if config.InheritEnv != nil {
+		fmt.Fprintf(hash, "inherit=%t\n", *config.InheritEnv)
+	}
+	if limits := config.Limits; limits != nil {
+		fmt.Fprintf(hash, "limits=%d:%s:%d:%s", limits.MemoryMB, limits.CPUTime, limits.OpenFiles, limits.Lifetime)
+	}
+	return ":process=" + hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
 // 检查连接是否健康
 func (p *ConnectionPool) checkConnectionHealth(hub *MCPHub, configKey string) bool {
 	// 简单检查是否有连接可用
@@ -110,7 +187,7 @@ func (p *ConnectionPool) checkConnectionHealth(hub *MCPHub, configKey string) bo
 	// 尝试调用GetToolsMap方法，这个方法会检查所有服务器
 	_, err := hub.GetToolsMap(ctx)
 	if err != nil {
-		log.Printf("连接池中的连接不健康: %s, 错误: %v", configKey, err)
+		logf("连接池中的连接不健康: %s, 错误: %v", configKey, err)
 		return false
 	}
 
@@ -129,7 +206,7 @@ func (p *ConnectionPool) GetHub(ctx context.Context, settings *MCPSettings) (*MC
 	if exists {
 		// 检查连接是否健康
 		if !p.checkConnectionHealth(hub, configKey) {
-			log.Printf("连接池中的连接不健康，强制关闭并重新连接")
+			logf("连接池中的连接不健康，强制关闭并重新连接")
 			// 强制关闭不健康的连接
 			p.ForceCloseHub(settings)
 			// 继续执行后面的代码创建新连接
@@ -148,7 +225,7 @@ func (p *ConnectionPool) GetHub(ctx context.Context, settings *MCPSettings) (*MC
 
 			p.mu.Unlock()
 
-			log.Printf("复用已有MCP服务器连接池，当前引用计数: %d", p.refCounts[configKey])
+			logf("复用已有MCP服务器连接池，当前引用计数: %d", p.refCounts[configKey])
 			return hub, nil
 		}
 	}
@@ -174,10 +251,132 @@ func (p *ConnectionPool) GetHub(ctx context.Context, settings *MCPSettings) (*MC
 
 	p.mu.Unlock()
 
-	log.Printf("创建新的MCP服务器连接池")
+	logf("创建新的MCP服务器连接池")
 	return newHub, nil
 }
 
+// syncHub 在hub运行时增删或修改服务器后更新其在池中的配置键和服务器映射
+func (p *ConnectionPool) syncHub(hub *MCPHub) {
+	hub.mu.RLock()
+	newKey := generateConfigKey(hub.config)
+	var names []string
+	if hub.config != nil {
+		for name, config := range hub.config.MCPServers {
+			if !config.Disabled {
+				names = append(names, name)
+			}
+		}
+	}
+	hub.mu.RUnlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	oldKey, found := "", false
+	for key, pooled := range p.hubPool {
+		if pooled == hub {
+			oldKey, found = key, true
+			break
+		}
+	}
+	if !found {
+		return // 不是通过连接池创建的hub
+	}
+
+	if newKey != oldKey {
+		if other, taken := p.hubPool[newKey]; taken && other != hub {
+			// 新配置与池中另一个hub相同，保留原有条目，只让该hub不再被按新键复用
+			logf("连接池中已存在相同配置的hub，保留原配置键")
+			newKey = oldKey
+		} else {
+			p.hubPool[newKey] = hub
+			p.refCounts[newKey] = p.refCounts[oldKey]
+			p.lastAccess[newKey] = p.lastAccess[oldKey]
+			delete(p.hubPool, oldKey)
+			delete(p.refCounts, oldKey)
+			delete(p.lastAccess, oldKey)
+		}
+	}
+
+	for name, key := range p.serverHub {
+		if key == oldKey {
+			delete(p.serverHub, name)
+		}
+	}
+	for _, name := range names {
+		p.serverHub[name] = newKey
+	}
+}
+
+// shareClient 记录user复用了owner的客户端，共享的客户端在最后一个使用它的hub释放时才关闭
+func (p *ConnectionPool) shareClient(owner, user *MCPHub, c client.MCPClient) {
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+
+	users, ok := p.clientUsers[c]
+	if !ok {
+		users = map[*MCPHub]struct{}{owner: {}}
+		p.clientUsers[c] = users
+	}
+	users[user] = struct{}{}
+}
+
+// releaseClient 记录hub不再使用客户端，返回是否应关闭它：
+// 未被共享的客户端，或hub是最后一个使用者时返回true
+func (p *ConnectionPool) releaseClient(hub *MCPHub, c client.MCPClient) bool {
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+
+	users, ok := p.clientUsers[c]
+	if !ok {
+		return true
+	}
+	delete(users, hub)
+	if len(users) > 0 {
+		return false
+	}
+	delete(p.clientUsers, c)
+	return true
+}
+
+// shareReconnectedClient 在一个hub重连成功后，让仍在使用同一个旧客户端的其他hub切换到新客户端，
+// 新客户端由切换后的hub共同使用，旧客户端不再被任何hub使用
+func (p *ConnectionPool) shareReconnectedClient(from *MCPHub, serverName string, oldClient, newClient client.MCPClient) {
+	p.clientsMu.Lock()
+	var hubs []*MCPHub
+	for hub := range p.clientUsers[oldClient] {
+		if hub != from {
+			hubs = append(hubs, hub)
+		}
+	}
+	p.clientsMu.Unlock()
+	if len(hubs) == 0 {
+		return
+	}
+
+	var adopted []*MCPHub
+	for _, hub := range hubs {
+		hub.mu.Lock()
+		if conn, ok := hub.connections[serverName]; ok && !hub.closed && conn.Client == oldClient {
+			hub.adoptClient(conn, serverName, newClient)
+			adopted = append(adopted, hub)
+			logf("共享的MCP服务器连接 %s 已重连，切换到新的客户端", serverName)
+		}
+		hub.mu.Unlock()
+	}
+
+	p.clientsMu.Lock()
+	defer p.clientsMu.Unlock()
+	delete(p.clientUsers, oldClient)
+	if len(adopted) > 0 {
+		users := map[*MCPHub]struct{}{from: {}}
+		for _, hub := range adopted {
+			users[hub] = struct{}{}
+		}
+		p.clientUsers[newClient] = users
+	}
+}
+
 // GetHubByServerName 根据服务器名称获取已有的MCPHub实例，如果不存在则返回nil和错误
 func (p *ConnectionPool) GetHubByServerName(serverName string) (*MCPHub, error) {
 	p.mu.RLock()
@@ -210,13 +409,13 @@ func (p *ConnectionPool) ReleaseHub(settings *MCPSettings) {
 		if count > 1 {
 			// 减少引用计数
 			p.refCounts[configKey]--
-			log.Printf("释放MCP服务器连接引用，剩余引用计数: %d", p.refCounts[configKey])
+			logf("释放MCP服务器连接引用，剩余引用计数: %d", p.refCounts[configKey])
 		} else {
 			// 如果引用计数归零，更新最后访问时间，但不立即关闭
 			// 由清理协程负责关闭长时间无人使用的连接
 			p.refCounts[configKey] = 0
 			p.lastAccess[configKey] = time.Now()
-			log.Printf("MCP服务器连接引用计数归零，等待清理")
+			logf("MCP服务器连接引用计数归零，等待清理")
 		}
 	}
 }
@@ -248,7 +447,7 @@ func (p *ConnectionPool) ForceCloseHub(settings *MCPSettings) error {
 		}
 	}
 
-	log.Printf("强制关闭MCP服务器连接")
+	logf("强制关闭MCP服务器连接")
 	return err
 }
 
@@ -272,7 +471,7 @@ func (p *ConnectionPool) CloseAllHubs() []error {
 	p.lastAccess = make(map[string]time.Time)
 	p.serverHub = make(map[string]string)
 
-	log.Printf("关闭所有MCP服务器连接")
+	logf("关闭所有MCP服务器连接")
 	return errors
 }
 
@@ -324,7 +523,7 @@ func (p *ConnectionPool) cleanupIdleConnections() {
 				hub := p.hubPool[key]
 				if hub != nil {
 					if err := hub.CloseServers(); err != nil {
-						log.Printf("清理空闲连接 %s 失败: %v", key, err)
+						logf("清理空闲连接 %s 失败: %v", key, err)
 					}
 				}
 
@@ -340,7 +539,7 @@ func (p *ConnectionPool) cleanupIdleConnections() {
 					}
 				}
 
-				log.Printf("清理长时间空闲的MCP服务器连接")
+				logf("清理长时间空闲的MCP服务器连接")
 			}
 		}
 	}
@@ -355,3 +355,17 @@ func TestConnectionPoolWithStreamableTransport(t *testing.T) {
 	assert.Equal(t, streamableKey, foundKey1, "找到的streamable配置键应该匹配")
 	assert.Equal(t, httpKey, foundKey2, "找到的HTTP配置键应该匹配")
 }
+
+// TestServerConfigKeyEnv 测试环境变量不同的stdio服务器不共享进程，且键中不出现环境变量的值
+func TestServerConfigKeyEnv(t *testing.T) {
+	withEnv := func(env map[string]string) string {
+		return serverConfigKey("s", &ServerConfig{Command: "server", Args: []string{"-v"}, Env: env})
+	}
+
+	base := withEnv(map[string]string{"API_TOKEN": "token-a", "MODE": "fast"})
+	assert.Equal(t, base, withEnv(map[string]string{"MODE": "fast", "API_TOKEN": "token-a"}))
+	assert.NotEqual(t, base, withEnv(map[string]string{"API_TOKEN": "token-b", "MODE": "fast"}))
+	assert.NotEqual(t, base, withEnv(map[string]string{"API_TOKEN": "secret://token-a", "MODE": "fast"}))
+	assert.NotEqual(t, base, withEnv(nil))
+	assert.NotContains(t, base, "token-a")
+}
@@ -0,0 +1,298 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/bytedance/sonic"
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MediaMode selects how binary tool output such as images and audio is returned
+// from an Eino tool, which can only hand back a string.
+type MediaMode string
+
+// Media mode constants
+const (
+	// MediaPlaceholder replaces binary content with a short description such as
+	// "[image: image/png, 1024 bytes]". This is the default.
+	MediaPlaceholder MediaMode = "placeholder"
+	// MediaDataURI inlines binary content as a base64 data URI.
+	MediaDataURI MediaMode = "data_uri"
+	// MediaParts returns a JSON array of schema.ChatMessagePart whenever the result
+	// contains anything besides text, so callers can build multimodal messages.
+	// Text-only results are still returned as plain text.
+	MediaParts MediaMode = "parts"
+)
+
+// defaultContentSeparator joins the rendered content items of a tool result.
+const defaultContentSeparator = "\n"
+
+// ContentConverter converts a single MCP content item into message parts.
+// Returning ok=false falls back to the built-in conversion for that item.
+type ContentConverter func(content mcp.Content) (parts []schema.ChatMessagePart, ok bool)
+
+// ContentPolicy controls how the content items of a CallToolResult are turned into
+// the string returned by Eino tools.
+//
+// Text items are concatenated in order. Images, audio and binary resources follow
+// Media. Embedded resources are inlined by MIME type: text resources and textual
+// blobs are inlined as text, image and audio blobs are treated like image and audio
+// content, and anything else is described by a placeholder or a file part.
+type ContentPolicy struct {
+	Media      MediaMode        // How binary content is rendered, defaults to MediaPlaceholder
+	Separator  string           // Joins rendered items, defaults to a newline
+	Converter  ContentConverter // Optional hook consulted before the built-in conversion
+	PreferText bool             // Return the content items even when the result carries structuredContent
+}
+
+// WithContentPolicy sets how the hub converts tool results that contain more than a
+// single text item, such as images, audio, embedded resources and resource links.
+func WithContentPolicy(policy ContentPolicy) MCPHubOption {
+	return func(h *MCPHub) {
+		h.contentPolicy = policy
+	}
+}
+
+// ToolResultToMessageParts converts every content item of a tool result into Eino
+// message parts using the given policy. Binary content is always returned as data
+// URIs so the parts can be passed to a multimodal model directly.
+//
+// Parameters:
+//   - result: Tool call result returned by an MCP server
+//   - policy: Content policy, only Converter is consulted
+//
+// Returns:
+//   - []schema.ChatMessagePart: Message parts in the order of the result content
+func ToolResultToMessageParts(result *mcp.CallToolResult, policy ContentPolicy) []schema.ChatMessagePart {
+	if result == nil {
+		return nil
+	}
+	policy.Media = MediaParts
+	return policy.toParts(result.Content)
+}
+
+// render converts the tool result content into the string returned by the invoker.
+func (p ContentPolicy) render(contents []mcp.Content) (string, error) {
+	parts := p.toParts(contents)
+
+	if p.Media == MediaParts && !textOnly(parts) {
+		data, err := sonic.Marshal(parts)
+		if err != nil {
+			return "", fmt.Errorf("序列化工具结果失败: %w", err)
+		}
+		return string(data), nil
+	}
+
+	separator := p.Separator
+	if separator == "" {
+		separator = defaultContentSeparator
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, separator), nil
+}
+
+// toParts converts content items into message parts. Outside MediaParts mode every
+// returned part is a text part.
+func (p ContentPolicy) toParts(contents []mcp.Content) []schema.ChatMessagePart {
+	parts := make([]schema.ChatMessagePart, 0, len(contents))
+	for _, content := range contents {
+		if p.Converter != nil {
+			if converted, ok := p.Converter(content); ok {
+				parts = append(parts, converted...)
+				continue
+			}
+		}
+		parts = append(parts, p.convert(content)...)
+	}
+	return parts
+}
+
+// convert is the built-in conversion of a single content item.
+func (p ContentPolicy) convert(content mcp.Content) []schema.ChatMessagePart {
+	switch c := content.(type) {
+	case mcp.TextContent:
+		return []schema.ChatMessagePart{textPart(c.Text)}
+	case *mcp.TextContent:
+		return []schema.ChatMessagePart{textPart(c.Text)}
+	case mcp.ImageContent:
+		return []schema.ChatMessagePart{p.binaryPart(c.MIMEType, c.Data, "", "")}
+	case *mcp.ImageContent:
+		return []schema.ChatMessagePart{p.binaryPart(c.MIMEType, c.Data, "", "")}
+	case mcp.AudioContent:
+		return []schema.ChatMessagePart{p.binaryPart(c.MIMEType, c.Data, "", "")}
+	case *mcp.AudioContent:
+		return []schema.ChatMessagePart{p.binaryPart(c.MIMEType, c.Data, "", "")}
+	case mcp.ResourceLink:
+		return []schema.ChatMessagePart{p.linkPart(c)}
+	case *mcp.ResourceLink:
+		return []schema.ChatMessagePart{p.linkPart(*c)}
+	case mcp.EmbeddedResource:
+		return []schema.ChatMessagePart{p.resourcePart(c.Resource)}
+	case *mcp.EmbeddedResource:
+		return []schema.ChatMessagePart{p.resourcePart(c.Resource)}
+	default:
+		return []schema.ChatMessagePart{textPart(fmt.Sprintf("[unsupported content: %T]", content))}
+	}
+}
+
+// resourcePart inlines an embedded resource according to its MIME type.
+func (p ContentPolicy) resourcePart(resource mcp.ResourceContents) schema.ChatMessagePart {
+	switch r := resource.(type) {
+	case mcp.TextResourceContents:
+		return textPart(r.Text)
+	case *mcp.TextResourceContents:
+		return textPart(r.Text)
+	case mcp.BlobResourceContents:
+		return p.blobPart(r)
+	case *mcp.BlobResourceContents:
+		return p.blobPart(*r)
+	default:
+		return textPart(fmt.Sprintf("[unsupported resource: %T]", resource))
+	}
+}
+
+// blobPart inlines textual blobs and treats everything else as binary content.
+func (p ContentPolicy) blobPart(blob mcp.BlobResourceContents) schema.ChatMessagePart {
+	if isTextMIMEType(blob.MIMEType) {
+		if decoded, err := base64.StdEncoding.DecodeString(blob.Blob); err == nil {
+			return textPart(string(decoded))
+		}
+	}
+	return p.binaryPart(blob.MIMEType, blob.Blob, blob.URI, resourceName(blob.URI))
+}
+
+// binaryPart renders base64 data of the given MIME type according to the media mode.
+// uri and name are only known for embedded resources.
+func (p ContentPolicy) binaryPart(mimeType, data, uri, name string) schema.ChatMessagePart {
+	kind := mediaKind(mimeType)
+
+	switch p.Media {
+	case MediaDataURI:
+		return textPart(dataURI(mimeType, data))
+	case MediaParts:
+		url := dataURI(mimeType, data)
+		switch kind {
+		case "image":
+			return schema.ChatMessagePart{
+				Type:     schema.ChatMessagePartTypeImageURL,
+				ImageURL: &schema.ChatMessageImageURL{URL: url, URI: uri, MIMEType: mimeType},
+			}
+		case "audio":
+			return schema.ChatMessagePart{
+				Type:     schema.ChatMessagePartTypeAudioURL,
+				AudioURL: &schema.ChatMessageAudioURL{URL: url, URI: uri, MIMEType: mimeType},
+			}
+		case "video":
+			return schema.ChatMessagePart{
+				Type:     schema.ChatMessagePartTypeVideoURL,
+				VideoURL: &schema.ChatMessageVideoURL{URL: url, URI: uri, MIMEType: mimeType},
+			}
+		default:
+			return schema.ChatMessagePart{
+				Type:    schema.ChatMessagePartTypeFileURL,
+				FileURL: &schema.ChatMessageFileURL{URL: url, URI: uri, MIMEType: mimeType, Name: name},
+			}
+		}
+	default:
+		if uri != "" {
+			return textPart(fmt.Sprintf("[%s: %s, %s, %d bytes]", kind, uri, mimeType, decodedLen(data)))
+		}
+		return textPart(fmt.Sprintf("[%s: %s, %d bytes]", kind, mimeType, decodedLen(data)))
+	}
+}
+
+// linkPart renders a resource link, which the hub never fetches itself.
+func (p ContentPolicy) linkPart(link mcp.ResourceLink) schema.ChatMessagePart {
+	if p.Media == MediaParts {
+		return schema.ChatMessagePart{
+			Type:    schema.ChatMessagePartTypeFileURL,
+			FileURL: &schema.ChatMessageFileURL{URI: link.URI, MIMEType: link.MIMEType, Name: link.Name},
+		}
+	}
+
+	text := "[resource link: " + link.URI
+	if link.Name != "" {
+		text += ", " + link.Name
+	}
+	if link.MIMEType != "" {
+		text += ", " + link.MIMEType
+	}
+	if link.Description != "" {
+		text += ", " + link.Description
+	}
+	return textPart(text + "]")
+}
+
+// textPart creates a text message part.
+func textPart(text string) schema.ChatMessagePart {
+	return schema.ChatMessagePart{Type: schema.ChatMessagePartTypeText, Text: text}
+}
+
+// textOnly reports whether every part is a text part.
+func textOnly(parts []schema.ChatMessagePart) bool {
+	for _, part := range parts {
+		if part.Type != schema.ChatMessagePartTypeText {
+			return false
+		}
+	}
+	return true
+}
+
+// isTextMIMEType reports whether content of the MIME type can be inlined as text.
+func isTextMIMEType(mimeType string) bool {
+	mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+	if strings.HasPrefix(mimeType, "text/") {
+		return true
+	}
+	switch mimeType {
+	case "application/json", "application/xml", "application/yaml", "application/x-yaml",
+		"application/javascript", "application/toml", "application/x-sh":
+		return true
+	}
+	return strings.HasSuffix(mimeType, "+json") || strings.HasSuffix(mimeType, "+xml")
+}
+
+// mediaKind returns "image", "audio", "video" or "file" for a MIME type.
+func mediaKind(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case strings.HasPrefix(mimeType, "audio/"):
+		return "audio"
+	case strings.HasPrefix(mimeType, "video/"):
+		return "video"
+	default:
+		return "file"
+	}
+}
+
+// dataURI builds a data URI from base64 data.
+func dataURI(mimeType, data string) string {
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	return "data:" + mimeType + ";base64," + data
+}
+
+// decodedLen returns the size of base64 data once decoded.
+func decodedLen(data string) int {
+	if decoded, err := base64.StdEncoding.DecodeString(data); err == nil {
+		return len(decoded)
+	}
+	return len(data)
+}
+
+// resourceName returns the last path element of a resource URI.
+func resourceName(uri string) string {
+	if i := strings.LastIndex(uri, "/"); i >= 0 {
+		return uri[i+1:]
+	}
+	return uri
+}
@@ -0,0 +1,110 @@
+package einomcphost
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMixedContentHub 创建一个工具返回多种内容类型的hub
+func newMixedContentHub(t *testing.T, opts ...MCPHubOption) *MCPHub {
+	t.Helper()
+
+	png := base64.StdEncoding.EncodeToString([]byte("fake-png"))
+	s := server.NewMCPServer("content-test-server", "1.0.0")
+	s.AddTool(mcp.NewTool("mixed"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return &mcp.CallToolResult{Content: []mcp.Content{
+			mcp.NewTextContent("first"),
+			mcp.NewTextContent("second"),
+			mcp.NewImageContent(png, "image/png"),
+			mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: "file:///a.txt", MIMEType: "text/plain", Text: "inline text"}),
+			mcp.NewEmbeddedResource(mcp.BlobResourceContents{
+				URI: "file:///b.json", MIMEType: "application/json",
+				Blob: base64.StdEncoding.EncodeToString([]byte(`{"ok":true}`)),
+			}),
+			mcp.NewResourceLink("file:///c.pdf", "c.pdf", "", "application/pdf"),
+		}}, nil
+	})
+	s.AddTool(mcp.NewTool("fail"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return &mcp.CallToolResult{IsError: true, Content: []mcp.Content{
+			mcp.NewTextContent("bad input"),
+			mcp.NewTextContent("try again"),
+		}}, nil
+	})
+
+	opts = append(opts, WithInprocessMCPClient("content", newTestInprocessClient(t, s)))
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{MCPServers: map[string]*ServerConfig{}}, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+	return hub
+}
+
+// TestContentPolicyPlaceholder 测试默认策略转换所有内容
+func TestContentPolicyPlaceholder(t *testing.T) {
+	hub := newMixedContentHub(t)
+
+	result, err := hub.InvokeTool(context.Background(), "content_mixed", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n[image: image/png, 8 bytes]\ninline text\n{\"ok\":true}\n[resource link: file:///c.pdf, c.pdf, application/pdf]", result)
+
+	_, err = hub.InvokeTool(context.Background(), "content_fail", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad input\ntry again")
+}
+
+// TestContentPolicyDataURI 测试以data URI内联二进制内容
+func TestContentPolicyDataURI(t *testing.T) {
+	hub := newMixedContentHub(t, WithContentPolicy(ContentPolicy{Media: MediaDataURI, Separator: " | "}))
+
+	result, err := hub.InvokeTool(context.Background(), "content_mixed", nil)
+	require.NoError(t, err)
+	assert.Contains(t, result, "first | second | data:image/png;base64,"+base64.StdEncoding.EncodeToString([]byte("fake-png")))
+}
+
+// TestContentPolicyParts 测试多模态消息片段输出
+func TestContentPolicyParts(t *testing.T) {
+	hub := newMixedContentHub(t, WithContentPolicy(ContentPolicy{Media: MediaParts}))
+
+	result, err := hub.InvokeTool(context.Background(), "content_mixed", nil)
+	require.NoError(t, err)
+
+	var parts []schema.ChatMessagePart
+	require.NoError(t, json.Unmarshal([]byte(result), &parts))
+	require.Len(t, parts, 6)
+	assert.Equal(t, "first", parts[0].Text)
+	assert.Equal(t, schema.ChatMessagePartTypeImageURL, parts[2].Type)
+	assert.Equal(t, "image/png", parts[2].ImageURL.MIMEType)
+	assert.Equal(t, schema.ChatMessagePartTypeFileURL, parts[5].Type)
+	assert.Equal(t, "file:///c.pdf", parts[5].FileURL.URI)
+
+	// 纯文本结果仍然以文本返回
+	_, err = hub.InvokeTool(context.Background(), "content_fail", nil)
+	assert.Contains(t, err.Error(), "bad input\ntry again")
+}
+
+// TestContentConverter 测试自定义内容转换
+func TestContentConverter(t *testing.T) {
+	policy := ContentPolicy{Converter: func(content mcp.Content) ([]schema.ChatMessagePart, bool) {
+		if _, ok := content.(mcp.ImageContent); ok {
+			return []schema.ChatMessagePart{{Type: schema.ChatMessagePartTypeText, Text: "<image>"}}, true
+		}
+		return nil, false
+	}}
+
+	parts := ToolResultToMessageParts(&mcp.CallToolResult{Content: []mcp.Content{
+		mcp.NewTextContent("caption"),
+		mcp.NewImageContent("AAAA", "image/jpeg"),
+		mcp.NewAudioContent("AAAA", "audio/wav"),
+	}}, policy)
+	require.Len(t, parts, 3)
+	assert.Equal(t, "<image>", parts[1].Text)
+	assert.Equal(t, schema.ChatMessagePartTypeAudioURL, parts[2].Type)
+	assert.Equal(t, "data:audio/wav;base64,AAAA", parts[2].AudioURL.URL)
+}
@@ -501,8 +501,8 @@ func TestNewMCPHubInitializationFailure(t *testing.T) {
 		},
 	}
 
-	// 这应该失败，因为命令不存在
-	hub, err := NewMCPHubFromSettings(ctx, settings)
+	// 严格模式下这应该失败，因为命令不存在
+	hub, err := NewMCPHubFromSettings(ctx, settings, WithStrictStartup())
 	assert.Error(t, err)
 	assert.Nil(t, hub)
 	assert.Contains(t, err.Error(), "初始化服务器失败")
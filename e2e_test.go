@@ -58,7 +58,7 @@ func TestE2EStdioTransport(t *testing.T) {
 	for _, tool := range tools {
 		info, err := tool.Info(ctx)
 		require.NoError(t, err)
-		toolNames[info.Name] = true
+		toolNames[info.Extra[ToolInfoExtraToolName].(string)] = true
 		t.Logf("Found tool: %s", info.Name) // 打印工具名称以便调试
 	}
 
@@ -137,7 +137,7 @@ func TestE2EHTTPTransport(t *testing.T) {
 	for _, tool := range tools {
 		info, err := tool.Info(ctx)
 		require.NoError(t, err)
-		toolNames[info.Name] = true
+		toolNames[info.Extra[ToolInfoExtraToolName].(string)] = true
 	}
 
 	assert.True(t, toolNames["sum"], "Should have sum tool")
@@ -204,7 +204,7 @@ func TestE2EStreamableTransport(t *testing.T) {
 	for _, tool := range tools {
 		info, err := tool.Info(ctx)
 		require.NoError(t, err)
-		toolNames[info.Name] = true
+		toolNames[info.Extra[ToolInfoExtraToolName].(string)] = true
 	}
 
 	assert.True(t, toolNames["multiply"], "Should have multiply tool")
@@ -271,7 +271,7 @@ func TestE2ESSETransport(t *testing.T) {
 	for _, tool := range tools {
 		info, err := tool.Info(ctx)
 		require.NoError(t, err)
-		toolNames[info.Name] = true
+		toolNames[info.Extra[ToolInfoExtraToolName].(string)] = true
 	}
 
 	assert.True(t, toolNames["echo"], "Should have echo tool")
@@ -478,7 +478,7 @@ func TestE2EAllowedTools(t *testing.T) {
 		for _, tool := range tools {
 			info, err := tool.Info(ctx)
 			require.NoError(t, err)
-			toolNames[info.Name] = true
+			toolNames[info.Extra[ToolInfoExtraToolName].(string)] = true
 		}
 
 		// 应该有sum和echo工具
@@ -527,7 +527,7 @@ func TestE2EAllowedTools(t *testing.T) {
 		for _, tool := range tools {
 			info, err := tool.Info(ctx)
 			require.NoError(t, err)
-			toolNames[info.Name] = true
+			toolNames[info.Extra[ToolInfoExtraToolName].(string)] = true
 		}
 
 		assert.True(t, toolNames["sum"], "Should have sum tool")
@@ -578,7 +578,7 @@ func TestE2EExcludedTools(t *testing.T) {
 		for _, tool := range tools {
 			info, err := tool.Info(ctx)
 			require.NoError(t, err)
-			toolNames[info.Name] = true
+			toolNames[info.Extra[ToolInfoExtraToolName].(string)] = true
 		}
 
 		// 应该有sum和echo工具
@@ -627,7 +627,7 @@ func TestE2EExcludedTools(t *testing.T) {
 		for _, tool := range tools {
 			info, err := tool.Info(ctx)
 			require.NoError(t, err)
-			toolNames[info.Name] = true
+			toolNames[info.Extra[ToolInfoExtraToolName].(string)] = true
 		}
 
 		assert.False(t, toolNames["sum"], "Should not have sum tool")
@@ -680,7 +680,7 @@ func TestE2EToolsFilteringCombination(t *testing.T) {
 		for _, tool := range tools {
 			info, err := tool.Info(ctx)
 			require.NoError(t, err)
-			toolNames[info.Name] = true
+			toolNames[info.Extra[ToolInfoExtraToolName].(string)] = true
 		}
 
 		// 应该只有sum工具（在allowedTools中但不在excludedTools中）
@@ -750,7 +750,7 @@ func TestE2EToolsFilteringWithHTTPTransport(t *testing.T) {
 	for _, tool := range tools {
 		info, err := tool.Info(ctx)
 		require.NoError(t, err)
-		toolNames[info.Name] = true
+		toolNames[info.Extra[ToolInfoExtraToolName].(string)] = true
 	}
 
 	assert.True(t, toolNames["echo"], "Should have echo tool")
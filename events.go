@@ -0,0 +1,53 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import "time"
+
+// HubEventType identifies the kind of lifecycle event emitted by an MCPHub.
+type HubEventType string
+
+// Hub event type constants
+const (
+	EventReconnecting    HubEventType = "reconnecting"     // 正在尝试重新连接服务器
+	EventReconnected     HubEventType = "reconnected"      // 重新连接成功，客户端已替换
+	EventReconnectFailed HubEventType = "reconnect_failed" // 重试次数耗尽，服务器不可用
+	EventServerStopped   HubEventType = "server_stopped"   // stdio服务器进程退出后按重启策略不再重启
+	EventToolsChanged    HubEventType = "tools_changed"    // 服务器的工具列表变化，需要重新获取Eino工具
+	EventConfigReloaded  HubEventType = "config_reloaded"  // 配置文件已重新加载，Err记录部分服务器的失败
+	EventConfigRejected  HubEventType = "config_rejected"  // 配置文件无效，继续使用当前配置
+)
+
+// HubEvent describes something that happened to a server managed by the hub.
+type HubEvent struct {
+	Type    HubEventType // Kind of event
+	Server  string       // Server the event refers to
+	Attempt int          // Reconnect attempt number, starting at 1 (reconnect events only)
+	Err     error        // Cause of the event, if any
+	Time    time.Time    // When the event was emitted
+
+	// Exposed tool names affected by the change (EventToolsChanged only)
+	Added   []string
+	Removed []string
+	Updated []string
+}
+
+// WithEventHandler registers a function that receives hub lifecycle events such as
+// reconnect attempts and their outcome. Handlers are called synchronously from the
+// goroutine that produced the event, never while the hub lock is held, so they may
+// call back into the hub but should return quickly.
+// The option can be given multiple times; handlers run in registration order.
+func WithEventHandler(handler func(HubEvent)) MCPHubOption {
+	return func(h *MCPHub) {
+		h.eventHandlers = append(h.eventHandlers, handler)
+	}
+}
+
+// emitEvent delivers an event to every registered handler. Callers must not hold h.mu.
+func (h *MCPHub) emitEvent(event HubEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	for _, handler := range h.eventHandlers {
+		handler(event)
+	}
+}
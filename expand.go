@@ -0,0 +1,307 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// envPattern matches ${VAR}, ${VAR:-default} and ${VAR:?message}.
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?:(:-|:\?)([^}]*))?\}`)
+
+// minRedactLength is the length below which expanded values are not redacted, so
+// values such as "1" or "on" do not blank out every matching character of a log line.
+const minRedactLength = 4
+
+// ExpandSettings expands environment variable references in the command, args, env
+// values, URL, header values, bearer token, OAuth client credentials, proxy, TLS
+// file paths and working directory of every server, in place:
+//   - ${VAR} is replaced by the value of VAR, or by an empty string if it is unset
+//   - ${VAR:-default} uses default if VAR is unset or empty
+//   - ${VAR:?message} reports an error with message if VAR is unset or empty
+//
+// Values taken from the environment into header values, the bearer token, the OAuth
+// client secret and env values with a secret-looking name, and values of variables
+// with a secret-looking name (see secretName) are redacted from the package's log
+// output. LoadSettings and LoadSettingsFromString call ExpandSettings
+// before validation. Settings built in code are expanded when each client is
+// created, without modifying the config; call ExpandSettings yourself to report
+// missing variables up front.
+//
+// Parameters:
+//   - settings: Settings to expand
+//
+// Returns:
+//   - error: Joined errors of all missing required variables
+func ExpandSettings(settings *MCPSettings) error {
+	if settings == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, name := range sortedServerNames(settings.MCPServers) {
+		if err := expandServerConfig(settings.MCPServers[name]); err != nil {
+			errs = append(errs, fmt.Errorf(errMsgEnvExpansion, name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// expandServerConfig expands the fields of a single server config in place.
+func expandServerConfig(config *ServerConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	var errs []error
+	expandField := func(s string, credential bool) string {
+		expanded, err := expandEnv(s, credential)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return expanded
+	}
+	expand := func(s string) string { return expandField(s, false) }
+	expandCredential := func(s string) string { return expandField(s, true) }
+
+	config.Command = expand(config.Command)
+	config.URL = expand(config.URL)
+	for i, arg := range config.Args {
+		config.Args[i] = expand(arg)
+	}
+	for _, key := range sortedKeys(config.Env) {
+		config.Env[key] = expandField(config.Env[key], secretName(key))
+	}
+	for _, key := range sortedKeys(config.Headers) {
+		config.Headers[key] = expandCredential(config.Headers[key])
+	}
+	config.BearerToken = expandCredential(config.BearerToken)
+	if config.OAuth != nil {
+		config.OAuth.ClientID = expand(config.OAuth.ClientID)
+		config.OAuth.ClientSecret = expandCredential(config.OAuth.ClientSecret)
+	}
+	// 代理地址只有密码需要脱敏
+	config.Proxy = expand(config.Proxy)
+	if proxyURL, err := url.Parse(config.Proxy); err == nil && proxyURL.User != nil {
+		if password, ok := proxyURL.User.Password(); ok {
+			secrets.add(password)
+		}
+	}
+	config.Cwd = expand(config.Cwd)
+	if config.TLS != nil {
+		config.TLS.CAFile = expand(config.TLS.CAFile)
+		config.TLS.CertFile = expand(config.TLS.CertFile)
+		config.TLS.KeyFile = expand(config.TLS.KeyFile)
+	}
+	config.expanded = true
+	return errors.Join(errs...)
+}
+
+// expandedConfig returns config with its variable references expanded. Configs that
+// were expanded already are returned as is; others are expanded on a copy, so the
+// shared config keeps its ${VAR} references.
+func expandedConfig(config *ServerConfig) (*ServerConfig, error) {
+	if config.expanded {
+		return config, nil
+	}
+	c := *config
+	c.Args = slices.Clone(config.Args)
+	c.Env = maps.Clone(config.Env)
+	c.Headers = maps.Clone(config.Headers)
+	c.OAuth = config.OAuth.clone()
+	c.TLS = config.TLS.clone()
+	c.Limits = config.Limits.clone()
+	c.Restart = config.Restart.clone()
+	if err := expandServerConfig(&c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// secretName reports whether a variable name looks like it holds a secret, such as
+// GITHUB_TOKEN, API_KEY, CLIENT_SECRET or DB_PASSWORD.
+func secretName(name string) bool {
+	name = strings.ToUpper(name)
+	return strings.HasSuffix(name, "_TOKEN") || strings.HasSuffix(name, "_KEY") ||
+		strings.Contains(name, "SECRET") || strings.Contains(name, "PASSWORD")
+}
+
+// expandEnv expands the variable references in s. Substituted values are
+// registered for redaction if s is a credential setting or the variable name looks
+// like a secret.
+func expandEnv(s string, credential bool) (string, error) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var errs []error
+	expanded := envPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		match := envPattern.FindStringSubmatch(ref)
+		name, op, arg := match[1], match[2], match[3]
+
+		if value := os.Getenv(name); value != "" {
+			if credential || secretName(name) {
+				secrets.add(value)
+			}
+			return value
+		}
+		switch op {
+		case ":-":
+			return arg
+		case ":?":
+			if arg == "" {
+				arg = "required"
+			}
+			errs = append(errs, fmt.Errorf(errMsgEnvRequired, name, arg))
+		}
+		return ""
+	})
+	return expanded, errors.Join(errs...)
+}
+
+// sortedKeys returns the keys of a string map in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// secrets holds the credentials known to the package: values substituted from the
+// environment into credential settings, resolved secret references and tokens.
+var secrets = &redactor{values: make(map[string]struct{}), current: make(map[secretSlot]string)}
+
+// secretSlot names a rotating credential of a server in a hub, such as its OAuth
+// access token. Only the current value of a slot is redacted.
+type secretSlot struct {
+	hub    *MCPHub
+	server string
+	name   string // Credential, such as "access_token" or a header name
+}
+
+// redactor replaces known secret values in text.
+type redactor struct {
+	mu       sync.RWMutex
+	values   map[string]struct{}   // Secrets from the settings, kept until the program exits
+	current  map[secretSlot]string // Rotating credentials, replaced when they change
+	replacer *strings.Replacer
+	stale    bool // replacer must be rebuilt before the next redaction
+}
+
+// add registers a secret value.
+func (r *redactor) add(value string) {
+	if len(value) < minRedactLength {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.values[value]; ok {
+		return
+	}
+	r.values[value] = struct{}{}
+	r.stale = true
+}
+
+// set registers the current value of a rotating credential, replacing the value
+// previously registered for slot, so refreshed tokens do not accumulate.
+func (r *redactor) set(slot secretSlot, value string) {
+	if len(value) < minRedactLength {
+		value = ""
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current[slot] == value {
+		return
+	}
+	if value == "" {
+		delete(r.current, slot)
+	} else {
+		r.current[slot] = value
+	}
+	r.stale = true
+}
+
+// forget removes the rotating credentials of a closed hub.
+func (r *redactor) forget(hub *MCPHub) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for slot := range r.current {
+		if slot.hub == hub {
+			delete(r.current, slot)
+			r.stale = true
+		}
+	}
+}
+
+// redact replaces every registered secret in s with "***".
+func (r *redactor) redact(s string) string {
+	r.mu.RLock()
+	replacer, stale := r.replacer, r.stale
+	r.mu.RUnlock()
+	if stale {
+		replacer = r.rebuild()
+	}
+	if replacer == nil {
+		return s
+	}
+	return replacer.Replace(s)
+}
+
+// rebuild builds the replacer from the registered secrets if they changed since it
+// was last built.
+func (r *redactor) rebuild() *strings.Replacer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.stale {
+		return r.replacer
+	}
+	r.stale = false
+
+	unique := make(map[string]struct{}, len(r.values)+len(r.current))
+	for v := range r.values {
+		unique[v] = struct{}{}
+	}
+	for _, v := range r.current {
+		unique[v] = struct{}{}
+	}
+	if len(unique) == 0 {
+		r.replacer = nil
+		return nil
+	}
+
+	// 较长的值优先替换，避免一个秘密是另一个的前缀时只替换一部分
+	values := make([]string, 0, len(unique))
+	for v := range unique {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, "***")
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+	return r.replacer
+}
+
+// logf is log.Printf with secrets redacted. Every log line of the package goes through it.
+func logf(format string, args ...any) {
+	log.Print(secrets.redact(fmt.Sprintf(format, args...)))
+}
+
+// fatalf is log.Fatalf with secrets redacted.
+func fatalf(format string, args ...any) {
+	log.Fatal(secrets.redact(fmt.Sprintf(format, args...)))
+}
@@ -0,0 +1,118 @@
+package einomcphost
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExpandSettings 测试环境变量展开的三种语法和作用的字段
+func TestExpandSettings(t *testing.T) {
+	t.Setenv("EXPAND_TEST_TOKEN", "tok-secret-123")
+	t.Setenv("EXPAND_TEST_HOST", "example.com")
+	t.Setenv("EXPAND_TEST_EMPTY", "")
+
+	settings, err := LoadSettingsFromString(`{"mcpServers": {
+		"remote": {"url": "https://${EXPAND_TEST_HOST}/mcp?token=${EXPAND_TEST_TOKEN}&a=${EXPAND_TEST_UNSET}"},
+		"local": {
+			"command": "${EXPAND_TEST_BIN:-npx}",
+			"args": ["--token", "${EXPAND_TEST_TOKEN}", "${EXPAND_TEST_EMPTY:-fallback}"],
+			"env": {"TOKEN": "Bearer ${EXPAND_TEST_TOKEN}", "PLAIN": "$HOME"}
+		}
+	}}`)
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://example.com/mcp?token=tok-secret-123&a=", settings.MCPServers["remote"].URL)
+	local := settings.MCPServers["local"]
+	assert.Equal(t, "npx", local.Command)
+	assert.Equal(t, []string{"--token", "tok-secret-123", "fallback"}, local.Args)
+	assert.Equal(t, map[string]string{"TOKEN": "Bearer tok-secret-123", "PLAIN": "$HOME"}, local.Env)
+}
+
+// TestExpandSettingsRequired 测试缺少必需变量时报告为校验错误
+func TestExpandSettingsRequired(t *testing.T) {
+	t.Setenv("EXPAND_TEST_EMPTY", "")
+
+	_, err := LoadSettingsFromString(`{"mcpServers": {
+		"a": {"url": "https://${EXPAND_TEST_MISSING:?set it to the API host}/mcp"},
+		"b": {"command": "run", "env": {"KEY": "${EXPAND_TEST_EMPTY:?}"}}
+	}}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid settings")
+	assert.Contains(t, err.Error(), "server a: environment variable EXPAND_TEST_MISSING: set it to the API host")
+	assert.Contains(t, err.Error(), "server b: environment variable EXPAND_TEST_EMPTY: required")
+}
+
+// TestSecretRedaction 测试展开的秘密不出现在日志中
+func TestSecretRedaction(t *testing.T) {
+	t.Setenv("EXPAND_TEST_API_KEY", "sk-live-abcdef")
+	t.Setenv("EXPAND_TEST_SHORT", "on")
+
+	settings, err := LoadSettingsFromString(`{"mcpServers": {
+		"remote": {"url": "https://api.redaction.test/mcp?key=${EXPAND_TEST_API_KEY}&debug=${EXPAND_TEST_SHORT}"}
+	}}`)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	output := log.Writer()
+	log.SetOutput(&buf)
+	logf("连接失败: %s", settings.MCPServers["remote"].URL)
+	log.SetOutput(output)
+
+	// 过短的值不脱敏
+	assert.Contains(t, buf.String(), "https://api.redaction.test/mcp?key=***&debug=on")
+	assert.NotContains(t, buf.String(), "sk-live-abcdef")
+}
+
+// TestSecretRedactionScope 测试只脱敏凭据设置和名称像秘密的变量，普通设置保持可读
+func TestSecretRedactionScope(t *testing.T) {
+	t.Setenv("EXPAND_TEST_DATA_DIR", "/srv/scope-data")
+	t.Setenv("EXPAND_TEST_PORT", "18443")
+	t.Setenv("EXPAND_TEST_AUTH", "scope-header-value")
+	t.Setenv("EXPAND_TEST_PLAIN", "scope-env-value")
+	t.Setenv("EXPAND_TEST_DB_PASSWORD", "scope-db-password")
+
+	settings, err := LoadSettingsFromString(`{"mcpServers": {
+		"local": {
+			"command": "run",
+			"args": ["--data", "${EXPAND_TEST_DATA_DIR}", "--port", "${EXPAND_TEST_PORT}", "--db", "${EXPAND_TEST_DB_PASSWORD}"],
+			"env": {"SERVICE_TOKEN": "${EXPAND_TEST_PLAIN}"}
+		},
+		"remote": {"url": "https://scope.test:${EXPAND_TEST_PORT}/mcp", "headers": {"X-Auth": "${EXPAND_TEST_AUTH}"}}
+	}}`)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	output := log.Writer()
+	log.SetOutput(&buf)
+	logf("启动: %v %v %s %s", settings.MCPServers["local"].Args, settings.MCPServers["local"].Env,
+		settings.MCPServers["remote"].URL, settings.MCPServers["remote"].Headers["X-Auth"])
+	log.SetOutput(output)
+
+	assert.Contains(t, buf.String(), "--data /srv/scope-data --port 18443 --db ***")
+	assert.Contains(t, buf.String(), "SERVICE_TOKEN:***")
+	assert.Contains(t, buf.String(), "https://scope.test:18443/mcp ***")
+}
+
+// TestSecretRotation 测试轮换的凭据只脱敏当前值，旧值不会累积
+func TestSecretRotation(t *testing.T) {
+	r := &redactor{values: make(map[string]struct{}), current: make(map[secretSlot]string)}
+	hub := &MCPHub{}
+	slot := secretSlot{hub: hub, server: "remote", name: "access_token"}
+
+	r.add("static-secret")
+	r.set(slot, "token-one")
+	assert.Equal(t, "*** ***", r.redact("static-secret token-one"))
+
+	r.set(slot, "token-two")
+	assert.Equal(t, "*** token-one ***", r.redact("static-secret token-one token-two"))
+	assert.Len(t, r.current, 1)
+
+	r.set(secretSlot{hub: hub, server: "other", name: "Authorization"}, "Bearer other-token")
+	r.forget(hub)
+	assert.Empty(t, r.current)
+	assert.Equal(t, "*** token-two Bearer other-token", r.redact("static-secret token-two Bearer other-token"))
+}
@@ -0,0 +1,196 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// regexPatternPrefix marks a tool pattern as a regular expression instead of a glob.
+const regexPatternPrefix = "re:"
+
+// matchToolPattern reports whether a tool name matches an allowedTools/excludedTools
+// pattern. Patterns are globs as understood by path.Match ("github_*", "get_?ssue")
+// or, with the "re:" prefix, regular expressions that must match the whole name.
+// A plain tool name is a glob that only matches itself.
+func matchToolPattern(pattern, name string) (bool, error) {
+	if expr, ok := strings.CutPrefix(pattern, regexPatternPrefix); ok {
+		re, err := regexp.Compile("^(?:" + expr + ")$")
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(name), nil
+	}
+	return path.Match(pattern, name)
+}
+
+// validateToolPatterns checks that every pattern is a valid glob or regular expression.
+func validateToolPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := matchToolPattern(pattern, ""); err != nil {
+			return fmt.Errorf("%q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// hubPatternSubject returns what a hub-level pattern is matched against: patterns
+// containing a '/' match "serverName/toolName", all others the tool name alone.
+func hubPatternSubject(pattern, serverName, toolName string) string {
+	if strings.Contains(pattern, "/") {
+		return serverName + "/" + toolName
+	}
+	return toolName
+}
+
+// matchesAny reports whether any pattern matches. Invalid patterns never match;
+// they are rejected when the settings are validated.
+func matchesAny(patterns []string, subject func(pattern string) string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := matchToolPattern(pattern, subject(pattern)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// filterTools applies the allowedTools/excludedTools patterns of the server and
+// of the hub settings to the tools listed by a server. A tool is kept if it passes
+// both the server and the hub-level filters. Callers must not hold h.mu.
+func (h *MCPHub) filterTools(serverName string, config *ServerConfig, tools []mcp.Tool) []mcp.Tool {
+	var hubAllowed, hubExcluded []string
+	h.mu.RLock()
+	if h.config != nil {
+		hubAllowed, hubExcluded = h.config.AllowedTools, h.config.ExcludedTools
+	}
+	h.mu.RUnlock()
+
+	var filtered []mcp.Tool
+	for _, mcpTool := range tools {
+		toolName := func(string) string { return mcpTool.Name }
+		qualified := func(pattern string) string { return hubPatternSubject(pattern, serverName, mcpTool.Name) }
+
+		// 如果配置了allowedTools，则只注册匹配allowedTools的工具
+		if config != nil && len(config.AllowedTools) > 0 && !matchesAny(config.AllowedTools, toolName) {
+			continue
+		}
+		// 如果配置了excludedTools，则不注册匹配excludedTools的工具
+		if config != nil && matchesAny(config.ExcludedTools, toolName) {
+			continue
+		}
+		if len(hubAllowed) > 0 && !matchesAny(hubAllowed, qualified) {
+			continue
+		}
+		if matchesAny(hubExcluded, qualified) {
+			continue
+		}
+		filtered = append(filtered, mcpTool)
+	}
+	return filtered
+}
+
+// toolNames returns the names of the given tools.
+func toolNames(tools []mcp.Tool) []string {
+	names := make([]string, 0, len(tools))
+	for _, mcpTool := range tools {
+		names = append(names, mcpTool.Name)
+	}
+	return names
+}
+
+// UnmatchedToolPatterns returns the allowedTools/excludedTools patterns that did not
+// match any tool, which usually means a typo in the configuration. Server patterns
+// are checked against the tools the server listed; servers that are not connected
+// are skipped. Hub-level patterns are listed under the empty server name and are
+// reported only if they match no tool of any connected server.
+//
+// Returns:
+//   - map[string][]string: Unmatched patterns indexed by server name, empty if every pattern matched
+func (h *MCPHub) UnmatchedToolPatterns() map[string][]string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.unmatchedToolPatterns()
+}
+
+// unmatchedToolPatterns implements UnmatchedToolPatterns. Callers must hold h.mu.
+func (h *MCPHub) unmatchedToolPatterns() map[string][]string {
+	result := make(map[string][]string)
+	if h.config == nil {
+		return result
+	}
+
+	hubPatterns := append(slices.Clone(h.config.AllowedTools), h.config.ExcludedTools...)
+	hubMatched := make(map[string]bool)
+
+	for name, status := range h.status {
+		if status.State != ServerStateConnected {
+			continue
+		}
+
+		var unmatched []string
+		if config := h.config.MCPServers[name]; config != nil {
+			for _, pattern := range append(slices.Clone(config.AllowedTools), config.ExcludedTools...) {
+				if !anyToolMatches(pattern, status.ListedTools) {
+					unmatched = append(unmatched, pattern)
+				}
+			}
+		}
+		if len(unmatched) > 0 {
+			result[name] = unmatched
+		}
+
+		for _, pattern := range hubPatterns {
+			for _, toolName := range status.ListedTools {
+				if ok, _ := matchToolPattern(pattern, hubPatternSubject(pattern, name, toolName)); ok {
+					hubMatched[pattern] = true
+					break
+				}
+			}
+		}
+	}
+
+	var hubUnmatched []string
+	for _, pattern := range hubPatterns {
+		if !hubMatched[pattern] {
+			hubUnmatched = append(hubUnmatched, pattern)
+		}
+	}
+	if len(hubUnmatched) > 0 {
+		result[""] = hubUnmatched
+	}
+	return result
+}
+
+// anyToolMatches reports whether pattern matches any of the tool names.
+func anyToolMatches(pattern string, names []string) bool {
+	for _, name := range names {
+		if ok, _ := matchToolPattern(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// logUnmatchedToolPatterns warns about filter patterns that matched no tool. Callers must hold h.mu.
+func (h *MCPHub) logUnmatchedToolPatterns() {
+	unmatched := h.unmatchedToolPatterns()
+	servers := make([]string, 0, len(unmatched))
+	for name := range unmatched {
+		servers = append(servers, name)
+	}
+	sort.Strings(servers)
+
+	for _, name := range servers {
+		if name == "" {
+			logf("全局工具过滤模式未匹配任何工具: %s", strings.Join(unmatched[name], ", "))
+			continue
+		}
+		logf("服务器 %s 的工具过滤模式未匹配任何工具: %s", name, strings.Join(unmatched[name], ", "))
+	}
+}
@@ -0,0 +1,100 @@
+package einomcphost
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newMultiToolServer 创建一个提供多个指定名称工具的服务器
+func newMultiToolServer(names ...string) *server.MCPServer {
+	s := server.NewMCPServer("filter-test-server", "1.0.0")
+	for _, name := range names {
+		s.AddTool(mcp.NewTool(name), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText(request.Params.Name), nil
+		})
+	}
+	return s
+}
+
+// TestMatchToolPattern 测试glob和正则模式匹配
+func TestMatchToolPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"sum", "sum", true},
+		{"sum", "summary", false},
+		{"github_*", "github_search", true},
+		{"github_*", "gitlab_search", false},
+		{"get_?ssue", "get_issue", true},
+		{"re:(get|list)_.*", "list_issues", true},
+		{"re:get", "get_issue", false}, // 正则需要匹配完整名称
+	}
+	for _, tt := range tests {
+		got, err := matchToolPattern(tt.pattern, tt.name)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%s ~ %s", tt.pattern, tt.name)
+	}
+
+	_, err := matchToolPattern("re:(", "x")
+	assert.Error(t, err)
+}
+
+// TestToolFilterPatterns 测试服务器级和全局过滤模式以及未匹配模式报告
+func TestToolFilterPatterns(t *testing.T) {
+	ctx := context.Background()
+
+	url := newTestHTTPServer(t, newMultiToolServer("github_search", "github_issue", "github_delete_repo", "fs_read"))
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"dev": {
+				Transport:     transportHTTP1,
+				URL:           url,
+				AllowedTools:  []string{"github_*", "re:fs_.*", "jira_*"},
+				ExcludedTools: []string{"*_delete_*"},
+			},
+		},
+		ExcludedTools: []string{"dev/fs_*", "slack_*"},
+	}
+	hub, err := NewMCPHubFromSettings(ctx, settings)
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	toolsMap, err := hub.GetToolsMap(ctx)
+	require.NoError(t, err)
+	var names []string
+	for name := range toolsMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"dev_github_issue", "dev_github_search"}, names)
+
+	status := hub.ServerStatuses()["dev"]
+	assert.Len(t, status.Tools, 2)
+	assert.Len(t, status.ListedTools, 4)
+
+	assert.Equal(t, map[string][]string{
+		"dev": {"jira_*"},
+		"":    {"slack_*"},
+	}, hub.UnmatchedToolPatterns())
+}
+
+// TestInvalidToolPattern 测试无效的模式在校验阶段被拒绝
+func TestInvalidToolPattern(t *testing.T) {
+	_, err := NewMCPHubFromString(context.Background(), `{
+		"mcpServers": {"dev": {"url": "http://localhost/mcp", "allowedTools": ["re:("]}}
+	}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "server dev: invalid tool pattern")
+
+	_, err = NewMCPHubFromString(context.Background(), `{"mcpServers": {}, "excludedTools": ["[a-"]}`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid tool pattern")
+}
@@ -0,0 +1,74 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+	"maps"
+	"strings"
+
+	mcptransport "github.com/mark3labs/mcp-go/client/transport"
+)
+
+// HeaderProvider returns HTTP headers for a request to an SSE or streamable HTTP
+// server. It is called before every request, so it can hand out short-lived tokens
+// that are refreshed in the background or on demand. The returned headers are
+// added after the server's configured headers and bearerToken, replacing entries
+// with the same name.
+type HeaderProvider func(ctx context.Context, serverName string) (map[string]string, error)
+
+// WithHeaderProvider sets a function that supplies HTTP headers for every request to
+// SSE and streamable HTTP servers. Stdio and in-process servers are not affected.
+// If the provider fails the error is logged and the request is sent with the
+// configured headers only, so the server reports the authentication failure.
+//
+// Parameters:
+//   - provider: Function returning the headers of a server's next request
+func WithHeaderProvider(provider HeaderProvider) MCPHubOption {
+	return func(h *MCPHub) {
+		h.headerProvider = provider
+	}
+}
+
+// requestHeaders returns the static headers of an HTTP server: Headers plus an
+// Authorization header built from BearerToken.
+func (c *ServerConfig) requestHeaders() map[string]string {
+	if len(c.Headers) == 0 && c.BearerToken == "" {
+		return nil
+	}
+	headers := maps.Clone(c.Headers)
+	if headers == nil {
+		headers = make(map[string]string, 1)
+	}
+	if c.BearerToken != "" {
+		for name := range headers {
+			// 请求头名称不区分大小写，bearerToken 覆盖任何写法的 Authorization
+			if strings.EqualFold(name, "Authorization") {
+				delete(headers, name)
+			}
+		}
+		headers["Authorization"] = "Bearer " + c.BearerToken
+	}
+	return headers
+}
+
+// headerFunc adapts the hub's HeaderProvider to the per-request header hook of the
+// mcp-go transports. It returns nil if no provider is set.
+func (h *MCPHub) headerFunc(serverName string) mcptransport.HTTPHeaderFunc {
+	provider := h.headerProvider
+	if provider == nil {
+		return nil
+	}
+	return func(ctx context.Context) map[string]string {
+		headers, err := provider(ctx, serverName)
+		if err != nil {
+			logf("获取服务器 %s 的请求头失败: %v", serverName, err)
+			return nil
+		}
+		for name, value := range headers {
+			if strings.EqualFold(name, "Authorization") {
+				secrets.set(secretSlot{hub: h, server: serverName, name: "Authorization"}, value)
+			}
+		}
+		return headers
+	}
+}
@@ -0,0 +1,170 @@
+package einomcphost
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// headerRecorder 记录每个请求的请求头，缺少正确的令牌时返回401
+type headerRecorder struct {
+	mu      sync.Mutex
+	token   string
+	headers []http.Header
+}
+
+func (r *headerRecorder) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.mu.Lock()
+		r.headers = append(r.headers, req.Header.Clone())
+		r.mu.Unlock()
+		if req.Header.Get("Authorization") != "Bearer "+r.token {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (r *headerRecorder) values(name string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var values []string
+	for _, h := range r.headers {
+		values = append(values, h.Get(name))
+	}
+	return values
+}
+
+// TestServerHeaders 测试sse和streamable传输发送配置的请求头和bearerToken
+func TestServerHeaders(t *testing.T) {
+	t.Setenv("HEADERS_TEST_TOKEN", "header-test-token")
+
+	tests := []struct {
+		name      string
+		transport transport
+		handler   func(*server.MCPServer) http.Handler
+		path      string
+	}{
+		{"streamable", transportHTTP1, func(s *server.MCPServer) http.Handler { return server.NewStreamableHTTPServer(s) }, "/mcp"},
+		{"sse", transportSSE, func(s *server.MCPServer) http.Handler { return server.NewSSEServer(s) }, "/sse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &headerRecorder{token: "header-test-token"}
+			httpServer := httptest.NewServer(recorder.wrap(tt.handler(newMultiToolServer("read"))))
+			t.Cleanup(httpServer.Close)
+
+			settings := &MCPSettings{
+				MCPServers: map[string]*ServerConfig{
+					"remote": {
+						Transport:   tt.transport,
+						URL:         httpServer.URL + tt.path,
+						Headers:     map[string]string{"X-Tenant": "acme", "authorization": "Basic ignored"},
+						BearerToken: "${HEADERS_TEST_TOKEN}",
+					},
+				},
+			}
+			hub, err := NewMCPHubFromSettings(context.Background(), settings)
+			require.NoError(t, err)
+			t.Cleanup(func() { hub.CloseServers() })
+
+			assert.Equal(t, []string{"remote_read"}, hubToolNames(t, hub))
+			for _, tenant := range recorder.values("X-Tenant") {
+				assert.Equal(t, "acme", tenant)
+			}
+			assert.Equal(t, "${HEADERS_TEST_TOKEN}", settings.MCPServers["remote"].BearerToken, "配置保留变量引用")
+		})
+	}
+
+	// 缺少令牌时连接失败
+	recorder := &headerRecorder{token: "header-test-token"}
+	httpServer := httptest.NewServer(recorder.wrap(server.NewStreamableHTTPServer(newMultiToolServer("read"))))
+	t.Cleanup(httpServer.Close)
+	_, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"remote": {Transport: transportHTTP1, URL: httpServer.URL + "/mcp"},
+		},
+	}, WithStrictStartup())
+	assert.Error(t, err)
+}
+
+// TestHeaderProvider 测试每个请求都从HeaderProvider获取新的令牌
+func TestHeaderProvider(t *testing.T) {
+	ctx := context.Background()
+	recorder := &headerRecorder{token: "provided-token"}
+	httpServer := httptest.NewServer(recorder.wrap(server.NewStreamableHTTPServer(newMultiToolServer("read"))))
+	t.Cleanup(httpServer.Close)
+
+	var calls atomic.Int32
+	var servers sync.Map
+	provider := func(ctx context.Context, serverName string) (map[string]string, error) {
+		servers.Store(serverName, true)
+		n := calls.Add(1)
+		return map[string]string{
+			"Authorization": "Bearer provided-token",
+			"X-Request-Id":  fmt.Sprint(n),
+		}, nil
+	}
+
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			// 提供者的Authorization覆盖静态令牌
+			"remote": {Transport: transportHTTP1, URL: httpServer.URL + "/mcp", BearerToken: "stale-token"},
+		},
+	}
+	hub, err := NewMCPHubFromSettings(ctx, settings, WithHeaderProvider(provider))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	before := calls.Load()
+	result, err := hub.InvokeTool(ctx, "remote_read", nil)
+	require.NoError(t, err)
+	assert.Contains(t, result, "read")
+	assert.Greater(t, calls.Load(), before, "每次请求都调用提供者")
+
+	_, ok := servers.Load("remote")
+	assert.True(t, ok)
+	ids := recorder.values("X-Request-Id")
+	require.NotEmpty(t, ids)
+	assert.NotEqual(t, ids[0], ids[len(ids)-1])
+
+	// 提供者失败时只发送静态请求头，服务器拒绝请求
+	failing := func(ctx context.Context, serverName string) (map[string]string, error) {
+		return nil, fmt.Errorf("token service unavailable")
+	}
+	_, err = NewMCPHubFromSettings(ctx, &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"remote": {Transport: transportHTTP1, URL: httpServer.URL + "/mcp"},
+		},
+	}, WithHeaderProvider(failing), WithStrictStartup())
+	assert.Error(t, err)
+}
+
+// TestServerHeadersValidation 测试stdio服务器不能配置请求头
+func TestServerHeadersValidation(t *testing.T) {
+	_, err := LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "headers": {"X-Tenant": "acme"}}}}`)
+	assert.ErrorContains(t, err, "headers and bearerToken")
+	_, err = LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "bearerToken": "token"}}}`)
+	assert.ErrorContains(t, err, "headers and bearerToken")
+
+	settings, err := LoadSettingsFromString(`{"mcpServers": {"remote": {"url": "https://example.org/mcp", "bearerToken": "token", "headers": {"X-Tenant": "acme"}}}}`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Tenant": "acme", "Authorization": "Bearer token"},
+		settings.MCPServers["remote"].requestHeaders())
+
+	// 凭据不同的服务器不共享连接池中的连接
+	other := *settings.MCPServers["remote"]
+	other.BearerToken = "other"
+	assert.NotEqual(t, serverConfigKey("remote", settings.MCPServers["remote"]), serverConfigKey("remote", &other))
+	assert.NotContains(t, serverConfigKey("remote", &other), "other")
+}
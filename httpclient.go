@@ -0,0 +1,165 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// TLSConfig configures TLS for an sse, http or streamable server.
+type TLSConfig struct {
+	CAFile             string `json:"caFile,omitempty" yaml:"caFile,omitempty"`                         // PEM bundle of CAs trusted in addition to the system roots
+	CertFile           string `json:"certFile,omitempty" yaml:"certFile,omitempty"`                     // PEM client certificate for mTLS, requires KeyFile
+	KeyFile            string `json:"keyFile,omitempty" yaml:"keyFile,omitempty"`                       // PEM private key of CertFile
+	ServerName         string `json:"serverName,omitempty" yaml:"serverName,omitempty"`                 // Name to verify the server certificate against, defaults to the URL host
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty"` // Accept any server certificate, for test labs only
+}
+
+// clone returns a copy of c, or nil if c is nil.
+func (c *TLSConfig) clone() *TLSConfig {
+	if c == nil {
+		return nil
+	}
+	t := *c
+	return &t
+}
+
+// WithHTTPClient makes the hub use client for all HTTP requests to the named server,
+// including OAuth metadata and token requests. The server's proxy and tls settings
+// are ignored; OAuth tokens are still added to the requests. Servers that use a
+// custom client never share a pooled connection with another hub unless both hubs
+// use the same client.
+//
+// Parameters:
+//   - serverName: Name of the sse, http or streamable server
+//   - client: HTTP client for the server's requests
+func WithHTTPClient(serverName string, client *http.Client) MCPHubOption {
+	return func(h *MCPHub) {
+		if h.httpClients == nil {
+			h.httpClients = make(map[string]*http.Client)
+		}
+		h.httpClients[serverName] = client
+	}
+}
+
+// validateHTTPClientConfig checks the proxy and tls settings of a server.
+func validateHTTPClientConfig(name string, server *ServerConfig) error {
+	if server.Proxy == "" && server.TLS == nil {
+		return nil
+	}
+	if server.Transport == transportStdio {
+		return fmt.Errorf(errMsgHTTPClientNotSupported, name)
+	}
+	if server.Proxy != "" && !strings.Contains(server.Proxy, "${") {
+		if _, err := parseProxyURL(server.Proxy); err != nil {
+			return fmt.Errorf(errMsgInvalidProxy, name, err)
+		}
+	}
+	if server.TLS != nil && (server.TLS.CertFile == "") != (server.TLS.KeyFile == "") {
+		return fmt.Errorf(errMsgTLSKeyPair, name)
+	}
+	return nil
+}
+
+// parseProxyURL parses a proxy URL with the http, https, socks5 or socks5h scheme.
+func parseProxyURL(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy host is empty")
+	}
+	return u, nil
+}
+
+// httpClient returns the HTTP client for a resolved server config: the client passed
+// to WithHTTPClient, a client built from the proxy and tls settings, or nil if the
+// server uses neither and the transports' default client applies.
+func (h *MCPHub) httpClient(serverName string, config *ServerConfig) (*http.Client, error) {
+	if client, ok := h.httpClients[serverName]; ok {
+		return client, nil
+	}
+	if config.Proxy == "" && config.TLS == nil {
+		return nil, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.Proxy != "" {
+		proxyURL, err := parseProxyURL(config.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("解析代理地址失败: %w", err)
+		}
+		if password, ok := proxyURL.User.Password(); ok {
+			secrets.add(password)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if config.TLS != nil {
+		tlsConfig, err := config.TLS.build()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// build loads the certificates of c into a tls.Config.
+func (c *TLSConfig) build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取CA证书失败: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA证书 %s 中没有PEM格式的证书", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("加载客户端证书失败: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// transportClient returns the HTTP client of the MCP transport: the server's client,
+// wrapped in an oauthTransport if the server uses OAuth. nil means the transport's
+// default client.
+func (c *ServerConfig) transportClient() *http.Client {
+	if c.oauth == nil {
+		return c.client
+	}
+	var wrapped http.Client
+	base := http.DefaultTransport
+	if c.client != nil {
+		wrapped = *c.client
+		if c.client.Transport != nil {
+			base = c.client.Transport
+		}
+	}
+	wrapped.Transport = &oauthTransport{session: c.oauth, base: base}
+	return &wrapped
+}
@@ -0,0 +1,49 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolCall describes a call to an MCP tool passing through the interceptor chain.
+type ToolCall struct {
+	Server    string         // Server providing the tool
+	Tool      string         // Original MCP tool name
+	Arguments map[string]any // Arguments sent to the server, after overrides and approval
+}
+
+// ToolHandler performs a tool call, either by calling the next interceptor or the server.
+type ToolHandler func(ctx context.Context, call ToolCall) (*mcp.CallToolResult, error)
+
+// ToolInterceptor wraps every MCP tool call made by the hub. It may inspect or
+// rewrite the call before passing it to next, inspect or replace the result and
+// error afterwards, or return without calling next to short-circuit the call.
+type ToolInterceptor func(ctx context.Context, call ToolCall, next ToolHandler) (*mcp.CallToolResult, error)
+
+// WithToolInterceptors appends interceptors to the chain wrapping every tool call,
+// both through InvokeTool and through the Eino tools returned by GetEinoTools.
+// Interceptors run in registration order: the first one is the outermost and sees
+// the call first and the result last. The option can be given multiple times.
+func WithToolInterceptors(interceptors ...ToolInterceptor) MCPHubOption {
+	return func(h *MCPHub) {
+		h.interceptors = append(h.interceptors, interceptors...)
+	}
+}
+
+// invokeChain runs the call through the interceptors and finally sends it to the server.
+func (h *MCPHub) invokeChain(ctx context.Context, call ToolCall) (*mcp.CallToolResult, error) {
+	handler := ToolHandler(func(ctx context.Context, call ToolCall) (*mcp.CallToolResult, error) {
+		return h.callTool(ctx, call.Server, call.Tool, call.Arguments)
+	})
+
+	for i := len(h.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := h.interceptors[i], handler
+		handler = func(ctx context.Context, call ToolCall) (*mcp.CallToolResult, error) {
+			return interceptor(ctx, call, next)
+		}
+	}
+
+	return handler(ctx, call)
+}
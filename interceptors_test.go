@@ -0,0 +1,57 @@
+package einomcphost
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestToolInterceptors 测试拦截器链的顺序、参数改写、结果后处理和短路
+func TestToolInterceptors(t *testing.T) {
+	ctx := context.Background()
+
+	var trace []string
+	logging := func(ctx context.Context, call ToolCall, next ToolHandler) (*mcp.CallToolResult, error) {
+		trace = append(trace, "log:before:"+call.Server+"/"+call.Tool)
+		result, err := next(ctx, call)
+		trace = append(trace, "log:after")
+		return result, err
+	}
+	rewrite := func(ctx context.Context, call ToolCall, next ToolHandler) (*mcp.CallToolResult, error) {
+		trace = append(trace, "rewrite")
+		if call.Arguments["message"] == "secret" {
+			return mcp.NewToolResultText("blocked"), nil
+		}
+		call.Arguments = map[string]any{"message": "[" + call.Arguments["message"].(string) + "]"}
+		result, err := next(ctx, call)
+		if err == nil {
+			result.Content = append(result.Content, mcp.NewTextContent("(checked)"))
+		}
+		return result, err
+	}
+
+	cli := newTestInprocessClient(t, newTestInprocessServer(t))
+	hub, err := NewMCPHubFromSettings(ctx, &MCPSettings{MCPServers: map[string]*ServerConfig{}},
+		WithInprocessMCPClient("local", cli),
+		WithToolInterceptors(logging),
+		WithToolInterceptors(rewrite),
+	)
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	result, err := hub.InvokeTool(ctx, "local_echo", map[string]any{"message": "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "Echo: [hi]\n(checked)", result)
+	assert.Equal(t, []string{"log:before:local/echo", "rewrite", "log:after"}, trace)
+
+	// 通过GetEinoTools取得的工具同样经过拦截器，且可以被短路
+	tools, err := hub.GetEinoTools(ctx, []string{"local_echo"})
+	require.NoError(t, err)
+	result, err = tools[0].(tool.InvokableTool).InvokableRun(ctx, `{"message":"secret"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "blocked", result)
+}
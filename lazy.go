@@ -0,0 +1,466 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolManifest lists the tools of MCP servers, indexed by server name. Lazy servers
+// register their tools from it without connecting. Get one from a running hub with
+// ToolManifest and pass it to the next hub with WithToolManifest.
+type ToolManifest map[string][]mcp.Tool
+
+// manifestEntry is the tool list of a server and the config it was discovered with.
+type manifestEntry struct {
+	config *ServerConfig // Config the tools were listed with, nil if they were given by WithToolManifest
+	tools  []mcp.Tool    // All tools of the server, before filtering
+}
+
+// idleTracker counts the calls in flight to a lazy server and closes its connection
+// once it has been idle for the idle timeout.
+type idleTracker struct {
+	active     int         // Calls in flight
+	timer      *time.Timer // Closes the connection when it fires
+	generation int         // Incremented whenever timer is replaced, to ignore stale timers
+}
+
+// WithLazyConnect makes servers without a lazy setting connect lazily: a server whose
+// tools are known from its tools setting or the tool manifest registers them without
+// connecting and connects when one of its tools is first called. Servers whose tools
+// are not known yet are connected at startup to discover them.
+func WithLazyConnect() MCPHubOption {
+	return func(h *MCPHub) {
+		h.lazyConnect = true
+	}
+}
+
+// WithIdleTimeout closes the connection to a lazy server once none of its tools has
+// been called for timeout, for servers without their own idleTimeout. The server's
+// tools stay registered and the next call connects again. 0 keeps idle servers
+// connected.
+func WithIdleTimeout(timeout time.Duration) MCPHubOption {
+	return func(h *MCPHub) {
+		h.idleTimeout = timeout
+	}
+}
+
+// WithToolManifest provides the tools of servers, typically saved from ToolManifest
+// of an earlier hub, so lazy servers can register them without connecting. A tools
+// setting in a server's config takes precedence.
+func WithToolManifest(manifest ToolManifest) MCPHubOption {
+	return func(h *MCPHub) {
+		if h.manifest == nil {
+			h.manifest = make(map[string]manifestEntry)
+		}
+		for name, tools := range manifest {
+			h.manifest[name] = manifestEntry{tools: tools}
+		}
+	}
+}
+
+// ToolManifest returns all tools of every server whose tools are known, before
+// filtering, for use with WithToolManifest.
+//
+// Returns:
+//   - ToolManifest: Tools indexed by server name
+func (h *MCPHub) ToolManifest() ToolManifest {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	manifest := make(ToolManifest, len(h.manifest))
+	for name, entry := range h.manifest {
+		manifest[name] = append([]mcp.Tool(nil), entry.tools...)
+	}
+	return manifest
+}
+
+// validateLazyConfig checks the lazy connection settings of a server.
+func validateLazyConfig(name string, server *ServerConfig) error {
+	if server.IdleTimeout < 0 {
+		return fmt.Errorf(errMsgIdleTimeoutInvalid, name)
+	}
+	for _, tool := range server.Tools {
+		if tool.Name == "" {
+			return fmt.Errorf(errMsgManifestToolName, name)
+		}
+	}
+	return nil
+}
+
+// isLazy reports whether a server connects lazily. In-process servers always
+// connect, since their client cannot be started again once closed.
+func (h *MCPHub) isLazy(config *ServerConfig) bool {
+	if config.Transport == transportInprocess {
+		return false
+	}
+	if config.Lazy != nil {
+		return *config.Lazy
+	}
+	return h.lazyConnect
+}
+
+// serverIdleTimeout returns the idle timeout of a lazy server, 0 if it stays connected.
+func (h *MCPHub) serverIdleTimeout(config *ServerConfig) time.Duration {
+	if config.IdleTimeout > 0 {
+		return config.IdleTimeout
+	}
+	return h.idleTimeout
+}
+
+// rememberTools records all tools listed by a server for ToolManifest, later lazy
+// registrations and the tool cache. initResult is nil if the tools were listed again
+// on an existing connection. Callers must hold h.mu.
+func (h *MCPHub) rememberTools(serverName string, config *ServerConfig, initResult *mcp.InitializeResult, tools []mcp.Tool) {
+	if h.manifest == nil {
+		h.manifest = make(map[string]manifestEntry)
+	}
+	h.manifest[serverName] = manifestEntry{config: config, tools: tools}
+	h.toolCache.store(serverName, config, initResult, tools)
+}
+
+// lazyManifest returns the tools a lazy server registers without connecting: the
+// tools setting of its config, the manifest entry unless it was discovered with a
+// config that connects differently, or the tool cache entry.
+//
+// Returns:
+//   - []mcp.Tool: All tools of the server, before filtering
+//   - bool: false if the server is not lazy or its tools are not known
+func (h *MCPHub) lazyManifest(serverName string, config *ServerConfig) ([]mcp.Tool, bool) {
+	if !h.isLazy(config) {
+		return nil, false
+	}
+	if len(config.Tools) > 0 {
+		return config.Tools, true
+	}
+
+	h.mu.RLock()
+	entry, ok := h.manifest[serverName]
+	h.mu.RUnlock()
+	if ok && (entry.config == nil || !connectionChanged(entry.config, config)) {
+		return entry.tools, true
+	}
+	if cached, ok := h.toolCache.lookup(config); ok {
+		return cached.Tools, true
+	}
+	return nil, false
+}
+
+// cachedTools returns the tools of a server that is connected in the background from
+// the tool cache. In-process servers are not cached, since they connect instantly.
+// With strict startup servers are connected right away, so a server that cannot
+// start fails hub creation.
+//
+// Returns:
+//   - []mcp.Tool: All tools of the server, before filtering
+//   - bool: false if the server is not in the cache
+func (h *MCPHub) cachedTools(config *ServerConfig) ([]mcp.Tool, bool) {
+	if config.Transport == transportInprocess || h.strictStartup {
+		return nil, false
+	}
+	cached, ok := h.toolCache.lookup(config)
+	return cached.Tools, ok
+}
+
+// startServer brings up a configured server: a lazy server whose tools are known
+// registers them and stays disconnected, a server found in the tool cache registers
+// the cached tools and is connected in the background, every other server is
+// connected.
+//
+// Returns:
+//   - error: *ServerError describing the failed stage
+func (h *MCPHub) startServer(ctx context.Context, serverName string, config *ServerConfig) error {
+	if tools, ok := h.lazyManifest(serverName, config); ok {
+		return h.registerKnownTools(serverName, config, tools, ServerStateIdle)
+	}
+	if tools, ok := h.cachedTools(config); ok {
+		if err := h.registerKnownTools(serverName, config, tools, ServerStateConnecting); err != nil {
+			return err
+		}
+		// 后台连接并重新发现工具，调用会等待连接完成
+		h.startConnect(serverName, config)
+		return nil
+	}
+	if err := h.connectToServer(ctx, serverName, config); err != nil {
+		return err
+	}
+	h.scheduleIdle(serverName)
+	return nil
+}
+
+// registerKnownTools registers the tools of a server from its manifest or the tool
+// cache without connecting it and reports it in state.
+func (h *MCPHub) registerKnownTools(serverName string, config *ServerConfig, allTools []mcp.Tool, state ServerState) error {
+	tools := h.filterTools(serverName, config, allTools)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return &ServerError{Server: serverName, Stage: StageDiscover, Err: fmt.Errorf("MCPHub已关闭")}
+	}
+	if err := h.registerTools(serverName, config, tools); err != nil {
+		h.removeServerTools(serverName)
+		err = &ServerError{Server: serverName, Stage: StageDiscover, Err: fmt.Errorf("注册工具清单失败: %w", err)}
+		h.setServerStatus(ServerStatus{MCPTools: MCPTools{Name: serverName, Err: err}, State: ServerStateFailed})
+		return err
+	}
+	if len(config.Tools) == 0 {
+		if entry, ok := h.manifest[serverName]; !ok || entry.config == nil {
+			// 来自WithToolManifest的清单或工具缓存，记录当前配置
+			if h.manifest == nil {
+				h.manifest = make(map[string]manifestEntry)
+			}
+			h.manifest[serverName] = manifestEntry{config: config, tools: allTools}
+		}
+	}
+	h.setServerStatus(ServerStatus{MCPTools: MCPTools{Name: serverName, Tools: tools}, State: state, ListedTools: toolNames(allTools)})
+	if state == ServerStateIdle {
+		logf("延迟连接MCP服务器 %s，已从工具清单注册 %d 个工具", serverName, len(tools))
+	} else {
+		logf("已从工具缓存注册MCP服务器 %s 的 %d 个工具，正在后台连接", serverName, len(tools))
+	}
+	return nil
+}
+
+// refreshIdleServer registers the tools of a disconnected lazy server again, so new
+// filters and tool overrides take effect. Servers that are connected or whose tools
+// are not known are left alone.
+func (h *MCPHub) refreshIdleServer(serverName string) error {
+	h.mu.RLock()
+	config, ok := h.config.MCPServers[serverName]
+	_, connected := h.connections[serverName]
+	h.mu.RUnlock()
+	if !ok || connected || config.Disabled {
+		return nil
+	}
+	tools, ok := h.lazyManifest(serverName, config)
+	if !ok {
+		return nil
+	}
+
+	before := h.snapshotTools()
+	if err := h.registerKnownTools(serverName, config, tools, ServerStateIdle); err != nil {
+		return err
+	}
+	h.emitToolsChanged(serverName, diffTools(before, h.snapshotTools()))
+	return nil
+}
+
+// connectLazily connects a server whose tools were registered without connecting,
+// from its manifest or the tool cache, and returns its connection. Concurrent callers
+// share a single connection attempt, including the background connection of a
+// server started from the tool cache.
+//
+// Parameters:
+//   - ctx: Context of the caller, only bounds the wait
+//   - serverName: Name of the server
+//   - cause: Error returned if the server's tools are not registered
+//
+// Returns:
+//   - Connection: Snapshot of the new connection
+//   - error: cause if the server cannot be connected on demand, or why connecting failed
+func (h *MCPHub) connectLazily(ctx context.Context, serverName string, cause error) (Connection, error) {
+	h.mu.RLock()
+	config, ok := h.config.MCPServers[serverName]
+	registered := len(h.serverTools[serverName]) > 0
+	closed := h.closed
+	h.mu.RUnlock()
+	if !ok || closed || config.Disabled || !registered {
+		return Connection{}, cause
+	}
+
+	flight := h.startConnect(serverName, config)
+	select {
+	case <-flight.done:
+		if flight.err != nil {
+			return Connection{}, flight.err
+		}
+		return h.getConnection(serverName)
+	case <-ctx.Done():
+		return Connection{}, ctx.Err()
+	}
+}
+
+// startConnect connects a server whose tools are registered in the background unless
+// a connection attempt is already in flight.
+func (h *MCPHub) startConnect(serverName string, config *ServerConfig) *reconnectFlight {
+	h.reconnectMu.Lock()
+	defer h.reconnectMu.Unlock()
+
+	if flight, ok := h.connecting[serverName]; ok {
+		return flight
+	}
+	if h.connecting == nil {
+		h.connecting = make(map[string]*reconnectFlight)
+	}
+	flight := &reconnectFlight{done: make(chan struct{})}
+	h.connecting[serverName] = flight
+
+	go func() {
+		flight.err = h.connectIdleServer(serverName, config)
+		if flight.err != nil {
+			logf("连接服务器 %s 失败，下次调用时重试: %v", serverName, flight.err)
+		}
+
+		h.reconnectMu.Lock()
+		delete(h.connecting, serverName)
+		h.reconnectMu.Unlock()
+		close(flight.done)
+	}()
+
+	return flight
+}
+
+// connectIdleServer connects a server whose tools are registered on the hub lifetime
+// context and replaces the tools registered from its manifest or the tool cache with
+// the tools it lists. If connecting fails the server is reported as failed, keeps its
+// tools and is tried again on the next call.
+func (h *MCPHub) connectIdleServer(serverName string, config *ServerConfig) error {
+	h.adminMu.Lock()
+	defer h.adminMu.Unlock()
+
+	h.mu.RLock()
+	current := h.config.MCPServers[serverName]
+	_, connected := h.connections[serverName]
+	h.mu.RUnlock()
+	if connected {
+		return nil
+	}
+	if current != config || config.Disabled {
+		return fmt.Errorf("服务器 %s 的配置已变化", serverName)
+	}
+
+	logf("首次使用，正在连接延迟连接的MCP服务器: %s", serverName)
+	before := h.snapshotTools()
+	if err := h.connectToServer(h.lifetimeContext(), serverName, config); err != nil {
+		return err
+	}
+	GetConnectionPool().syncHub(h)
+	// 服务器实际的工具可能与清单不同
+	h.emitToolsChanged(serverName, diffTools(before, h.snapshotTools()))
+	return nil
+}
+
+// beginUse marks a call to the server as in flight, which keeps an idle lazy server
+// connected until endUse.
+func (h *MCPHub) beginUse(serverName string) {
+	h.idleMu.Lock()
+	defer h.idleMu.Unlock()
+
+	tracker := h.idleTrackerOf(serverName)
+	tracker.active++
+	if tracker.timer != nil {
+		tracker.timer.Stop()
+		tracker.timer = nil
+	}
+}
+
+// endUse marks a call to the server as finished and starts the idle timer once no
+// call is in flight.
+func (h *MCPHub) endUse(serverName string) {
+	h.idleMu.Lock()
+	tracker := h.idleTrackerOf(serverName)
+	tracker.active--
+	idle := tracker.active == 0
+	h.idleMu.Unlock()
+
+	if idle {
+		h.scheduleIdle(serverName)
+	}
+}
+
+// idleTrackerOf returns the tracker of a server, creating it if needed. Callers must
+// hold h.idleMu.
+func (h *MCPHub) idleTrackerOf(serverName string) *idleTracker {
+	if h.idle == nil {
+		h.idle = make(map[string]*idleTracker)
+	}
+	tracker, ok := h.idle[serverName]
+	if !ok {
+		tracker = &idleTracker{}
+		h.idle[serverName] = tracker
+	}
+	return tracker
+}
+
+// forgetIdle stops the idle timer of a removed server.
+func (h *MCPHub) forgetIdle(serverName string) {
+	h.idleMu.Lock()
+	defer h.idleMu.Unlock()
+
+	if tracker, ok := h.idle[serverName]; ok && tracker.timer != nil {
+		tracker.timer.Stop()
+	}
+	delete(h.idle, serverName)
+}
+
+// scheduleIdle starts the idle timer of a connected lazy server with an idle timeout.
+func (h *MCPHub) scheduleIdle(serverName string) {
+	h.mu.RLock()
+	config, ok := h.config.MCPServers[serverName]
+	_, connected := h.connections[serverName]
+	h.mu.RUnlock()
+	if !ok || !connected || !h.isLazy(config) {
+		return
+	}
+	timeout := h.serverIdleTimeout(config)
+	if timeout <= 0 {
+		return
+	}
+
+	h.idleMu.Lock()
+	defer h.idleMu.Unlock()
+
+	tracker := h.idleTrackerOf(serverName)
+	if tracker.active > 0 {
+		return
+	}
+	if tracker.timer != nil {
+		tracker.timer.Stop()
+	}
+	tracker.generation++
+	generation := tracker.generation
+	tracker.timer = time.AfterFunc(timeout, func() {
+		h.closeIdle(serverName, generation, timeout)
+	})
+}
+
+// closeIdle closes the connection of a lazy server whose idle timer fired, unless a
+// call started in the meantime. The server's tools stay registered.
+func (h *MCPHub) closeIdle(serverName string, generation int, timeout time.Duration) {
+	h.mu.Lock()
+	conn, ok := h.connections[serverName]
+	if !ok || h.closed {
+		h.mu.Unlock()
+		return
+	}
+	h.idleMu.Lock()
+	tracker := h.idleTrackerOf(serverName)
+	busy := tracker.active > 0 || tracker.generation != generation
+	if !busy {
+		tracker.timer = nil
+	}
+	h.idleMu.Unlock()
+	if busy {
+		h.mu.Unlock()
+		return
+	}
+
+	delete(h.connections, serverName)
+	status := ServerStatus{MCPTools: MCPTools{Name: serverName}, State: ServerStateIdle}
+	if previous, ok := h.status[serverName]; ok {
+		status.Tools, status.ListedTools = previous.Tools, previous.ListedTools
+	}
+	h.setServerStatus(status)
+	h.mu.Unlock()
+
+	GetConnectionPool().syncHub(h)
+	logf("MCP服务器 %s 空闲超过 %s，已断开连接", serverName, timeout)
+	if err := h.closeClient(conn.Client); err != nil {
+		logf("关闭空闲服务器 %s 失败: %v", serverName, err)
+	}
+}
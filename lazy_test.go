@@ -0,0 +1,229 @@
+package einomcphost
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// toolMapNames 返回hub中已注册的工具名
+func toolMapNames(t *testing.T, hub *MCPHub) []string {
+	t.Helper()
+	tools, err := hub.GetToolsMap(context.Background())
+	require.NoError(t, err)
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	return names
+}
+
+// TestLazyConnectOnFirstUse 测试延迟连接的服务器在首次调用时连接，空闲后断开
+func TestLazyConnectOnFirstUse(t *testing.T) {
+	serverPath := buildTestServer(t)
+	lazy := true
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"proc": {
+				Transport:   transportStdio,
+				Command:     serverPath,
+				Lazy:        &lazy,
+				IdleTimeout: 300 * time.Millisecond,
+				Tools: []mcp.Tool{
+					mcp.NewTool("sum", mcp.WithNumber("a"), mcp.WithNumber("b")),
+				},
+			},
+		},
+	}, WithStrictStartup())
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	// 启动时只从工具清单注册工具，不启动进程
+	status := hub.ServerStatuses()["proc"]
+	assert.Equal(t, ServerStateIdle, status.State)
+	assert.Nil(t, status.Process)
+	assert.ElementsMatch(t, []string{"proc_sum"}, toolMapNames(t, hub))
+	_, err = hub.GetClient("proc")
+	assert.Error(t, err)
+
+	// 首次调用时连接，注册服务器实际的工具
+	assert.Equal(t, "4", invokeText(t, hub, "proc_sum", map[string]any{"a": 2, "b": 2}))
+	status = hub.ServerStatuses()["proc"]
+	assert.Equal(t, ServerStateConnected, status.State)
+	require.NotNil(t, status.Process)
+	assert.Positive(t, status.Process.PID)
+	assert.ElementsMatch(t, []string{"proc_sum", "proc_multiply", "proc_echo"}, toolMapNames(t, hub))
+
+	// 空闲超时后断开连接，工具保持注册
+	require.Eventually(t, func() bool {
+		status := hub.ServerStatuses()["proc"]
+		return status.State == ServerStateIdle && status.Process.PID == 0
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.ElementsMatch(t, []string{"proc_sum", "proc_multiply", "proc_echo"}, toolMapNames(t, hub))
+
+	// 再次调用时重新连接
+	assert.Equal(t, "6", invokeText(t, hub, "proc_multiply", map[string]any{"a": 2, "b": 3}))
+	assert.Equal(t, ServerStateConnected, hub.ServerStatuses()["proc"].State)
+}
+
+// TestLazyConnectWithApprover 测试审批在延迟连接的服务器连接之前进行
+func TestLazyConnectWithApprover(t *testing.T) {
+	serverPath := buildTestServer(t)
+	var (
+		mu       sync.Mutex
+		requests []ApprovalRequest
+	)
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"proc": {
+				Transport:   transportStdio,
+				Command:     serverPath,
+				AutoApprove: []string{"multiply"},
+				Tools: []mcp.Tool{
+					mcp.NewTool("sum", mcp.WithNumber("a"), mcp.WithNumber("b")),
+					mcp.NewTool("multiply", mcp.WithNumber("a"), mcp.WithNumber("b")),
+				},
+			},
+		},
+	}, WithStrictStartup(), WithLazyConnect(), WithToolApprover(ToolApproverFunc(
+		func(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			requests = append(requests, request)
+			return ApprovalDecision{Approved: true}, nil
+		})))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+	require.Equal(t, ServerStateIdle, hub.ServerStatuses()["proc"].State)
+
+	// 自动批准的工具直接连接并调用
+	assert.Equal(t, "6", invokeText(t, hub, "proc_multiply", map[string]any{"a": 2, "b": 3}))
+	mu.Lock()
+	assert.Empty(t, requests)
+	mu.Unlock()
+	require.NoError(t, hub.DisableServer("proc"))
+	require.NoError(t, hub.EnableServer(context.Background(), "proc"))
+	require.Equal(t, ServerStateIdle, hub.ServerStatuses()["proc"].State)
+
+	// 需要审批的工具先审批，再连接服务器
+	assert.Equal(t, "4", invokeText(t, hub, "proc_sum", map[string]any{"a": 2, "b": 2}))
+	mu.Lock()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "sum", requests[0].Tool)
+	mu.Unlock()
+	assert.Equal(t, ServerStateConnected, hub.ServerStatuses()["proc"].State)
+}
+
+// TestToolManifestRoundTrip 测试保存的工具清单让下一个hub中的服务器延迟连接
+func TestToolManifestRoundTrip(t *testing.T) {
+	serverPath := buildTestServer(t)
+	settings := func() *MCPSettings {
+		return &MCPSettings{
+			MCPServers: map[string]*ServerConfig{
+				"known": {Transport: transportStdio, Command: serverPath},
+			},
+		}
+	}
+
+	first, err := NewMCPHubFromSettings(context.Background(), settings(), WithStrictStartup())
+	require.NoError(t, err)
+	manifest := first.ToolManifest()
+	require.NoError(t, first.CloseServers())
+	require.Len(t, manifest["known"], 3)
+
+	next := settings()
+	next.MCPServers["unknown"] = &ServerConfig{Transport: transportStdio, Command: serverPath}
+	hub, err := NewMCPHubFromSettings(context.Background(), next,
+		WithStrictStartup(), WithLazyConnect(), WithToolManifest(manifest))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	statuses := hub.ServerStatuses()
+	assert.Equal(t, ServerStateIdle, statuses["known"].State)
+	assert.Len(t, statuses["known"].Tools, 3)
+	// 工具未知的服务器在启动时连接以发现工具
+	assert.Equal(t, ServerStateConnected, statuses["unknown"].State)
+	assert.Len(t, hub.ToolManifest(), 2)
+
+	assert.Equal(t, "Echo: hi", invokeText(t, hub, "known_echo", map[string]any{"message": "hi"}))
+	assert.Equal(t, ServerStateConnected, hub.ServerStatuses()["known"].State)
+}
+
+// TestLazyConfig 测试延迟连接配置的解析和校验
+func TestLazyConfig(t *testing.T) {
+	settings, err := LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "lazy": true,
+		"idleTimeout": "5m", "tools": [{"name": "sum", "inputSchema": {"type": "object"}}]}}}`)
+	require.NoError(t, err)
+	config := settings.MCPServers["local"]
+	require.NotNil(t, config.Lazy)
+	assert.True(t, *config.Lazy)
+	assert.Equal(t, 5*time.Minute, config.IdleTimeout)
+	require.Len(t, config.Tools, 1)
+	assert.Equal(t, "sum", config.Tools[0].Name)
+
+	data, err := json.Marshal(config)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"idleTimeout":"5m0s"`)
+
+	_, err = LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "idleTimeout": "-1s"}}}`)
+	assert.ErrorContains(t, err, "idleTimeout must not be negative")
+	_, err = LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "tools": [{"description": "no name"}]}}}`)
+	assert.ErrorContains(t, err, "tools entries require a name")
+
+	// 修改延迟连接设置不需要重新连接
+	changed := *config
+	changed.Lazy = nil
+	changed.IdleTimeout = time.Minute
+	changed.Tools = nil
+	assert.False(t, connectionChanged(config, &changed))
+}
+
+// TestIdleDisconnectKeepsSharedConnection 测试空闲断开时不关闭共享池中连接的其他hub仍在使用的客户端
+func TestIdleDisconnectKeepsSharedConnection(t *testing.T) {
+	ctx := context.Background()
+	pool := GetConnectionPool()
+	url := newTestHTTPServer(t, newMultiToolServer("read"))
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"pool_idle_shared": {Transport: transportHTTP1, URL: url},
+		},
+	}
+	pooled, err := pool.GetHub(ctx, settings)
+	require.NoError(t, err)
+	t.Cleanup(func() { pool.ForceCloseHub(settings) })
+
+	lazy := true
+	sharing, err := NewMCPHubFromSettings(ctx, &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"pool_idle_shared": {Transport: transportHTTP1, URL: url, Lazy: &lazy, IdleTimeout: 200 * time.Millisecond, Tools: []mcp.Tool{mcp.NewTool("read")}},
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { sharing.CloseServers() })
+
+	result, err := sharing.InvokeTool(ctx, "pool_idle_shared_read", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "read", result)
+	pooledClient, err := pooled.GetClient("pool_idle_shared")
+	require.NoError(t, err)
+	sharedClient, err := sharing.GetClient("pool_idle_shared")
+	require.NoError(t, err)
+	require.Same(t, pooledClient, sharedClient, "延迟连接应复用池中的连接")
+
+	require.Eventually(t, func() bool {
+		return sharing.ServerStatuses()["pool_idle_shared"].State == ServerStateIdle
+	}, 5*time.Second, 20*time.Millisecond)
+
+	result, err = pooled.InvokeTool(ctx, "pool_idle_shared_read", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "read", result)
+	client, err := pooled.GetClient("pool_idle_shared")
+	require.NoError(t, err)
+	assert.Same(t, pooledClient, client, "共享的客户端未被关闭，不需要重连")
+}
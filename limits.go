@@ -0,0 +1,161 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrServerOverloaded is returned, wrapped, for a tool call rejected because the
+// server has maxConcurrentCalls calls in flight and maxQueueLength calls waiting.
+var ErrServerOverloaded = errors.New("MCP服务器过载")
+
+// callLimiter bounds the calls in flight to a server. Calls beyond the limit wait
+// in a queue and are admitted in arrival order.
+type callLimiter struct {
+	mu            sync.Mutex
+	maxConcurrent int             // Calls in flight at once
+	maxQueue      int             // Calls waiting for a slot, 0 for no limit
+	active        int             // Calls in flight
+	waiters       []chan struct{} // Calls waiting for a slot, oldest first; closed when admitted
+}
+
+// newCallLimiter creates a limiter with the limits of a server config.
+func newCallLimiter(config *ServerConfig) *callLimiter {
+	return &callLimiter{
+		maxConcurrent: config.MaxConcurrentCalls,
+		maxQueue:      config.MaxQueueLength,
+	}
+}
+
+// validateCallLimits checks the call limits of a server.
+func validateCallLimits(name string, server *ServerConfig) error {
+	if server.MaxConcurrentCalls < 0 || server.MaxQueueLength < 0 {
+		return fmt.Errorf(errMsgCallLimitsInvalid, name)
+	}
+	if server.MaxQueueLength > 0 && server.MaxConcurrentCalls == 0 {
+		return fmt.Errorf(errMsgQueueWithoutLimit, name)
+	}
+	return nil
+}
+
+// acquire waits for a free slot and returns the function that frees it again.
+//
+// Returns:
+//   - func(): Frees the slot, to be called once the call is done
+//   - error: ErrServerOverloaded if the queue is full, or the context error
+func (l *callLimiter) acquire(ctx context.Context, serverName string) (func(), error) {
+	l.mu.Lock()
+	if l.active < l.maxConcurrent && len(l.waiters) == 0 {
+		l.active++
+		l.mu.Unlock()
+		return l.release, nil
+	}
+	if l.maxQueue > 0 && len(l.waiters) >= l.maxQueue {
+		active, queued := l.active, len(l.waiters)
+		l.mu.Unlock()
+		return nil, fmt.Errorf("服务器 %s 已有 %d 个调用进行中、%d 个调用排队: %w", serverName, active, queued, ErrServerOverloaded)
+	}
+	ready := make(chan struct{})
+	l.waiters = append(l.waiters, ready)
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return l.release, nil
+	case <-ctx.Done():
+	}
+	err := fmt.Errorf("等待服务器 %s 的空闲调用槽位时取消: %w", serverName, ctx.Err())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, waiter := range l.waiters {
+		if waiter == ready {
+			l.waiters = append(l.waiters[:i], l.waiters[i+1:]...)
+			return nil, err
+		}
+	}
+	// 取消的同时得到了槽位，交给下一个等待的调用
+	l.active--
+	l.admit()
+	return nil, err
+}
+
+// release frees a slot taken by acquire.
+func (l *callLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.admit()
+}
+
+// admit hands free slots to the oldest waiting calls. Callers must hold l.mu.
+func (l *callLimiter) admit() {
+	for l.active < l.maxConcurrent && len(l.waiters) > 0 {
+		l.active++
+		close(l.waiters[0])
+		l.waiters = l.waiters[1:]
+	}
+}
+
+// resize changes the limits. Calls in flight and waiting are kept and count against
+// the new limits; a lower maxQueueLength only rejects new calls.
+func (l *callLimiter) resize(maxConcurrent, maxQueue int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxConcurrent = maxConcurrent
+	l.maxQueue = maxQueue
+	l.admit()
+}
+
+// load returns the calls in flight and the calls waiting.
+func (l *callLimiter) load() (active, queued int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active, len(l.waiters)
+}
+
+// acquireCall applies the call limits of a server before a tool call. The limits
+// are read from the current config; a reload resizes the server's limiter, so calls
+// already in flight keep counting against the new limits.
+//
+// Returns:
+//   - func(): Frees the call's slot, to be called once the call is done
+//   - error: ErrServerOverloaded if the server's queue is full, or the context error
+func (h *MCPHub) acquireCall(ctx context.Context, serverName string) (func(), error) {
+	limiter := h.callLimiter(serverName)
+	if limiter == nil {
+		return func() {}, nil
+	}
+	return limiter.acquire(ctx, serverName)
+}
+
+// callLimiter returns the limiter of a server with the server's current limits, nil
+// if its calls are not limited.
+func (h *MCPHub) callLimiter(serverName string) *callLimiter {
+	h.mu.RLock()
+	config, ok := h.config.MCPServers[serverName]
+	limiter := h.limiters[serverName]
+	h.mu.RUnlock()
+	if !ok || config.MaxConcurrentCalls == 0 {
+		return nil
+	}
+
+	if limiter == nil {
+		h.mu.Lock()
+		// 其他调用可能已经创建了限制器
+		limiter = h.limiters[serverName]
+		if limiter == nil {
+			if h.limiters == nil {
+				h.limiters = make(map[string]*callLimiter)
+			}
+			limiter = newCallLimiter(config)
+			h.limiters[serverName] = limiter
+		}
+		h.mu.Unlock()
+	}
+	limiter.resize(config.MaxConcurrentCalls, config.MaxQueueLength)
+	return limiter
+}
@@ -0,0 +1,198 @@
+package einomcphost
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLimitedHub 创建调用数受限的测试hub，服务器名为 "proc"
+func newLimitedHub(t *testing.T, serverPath string, maxConcurrent, maxQueue int) *MCPHub {
+	t.Helper()
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"proc": {
+				Transport:          transportStdio,
+				Command:            serverPath,
+				Env:                map[string]string{processToolsEnv: "1"},
+				MaxConcurrentCalls: maxConcurrent,
+				MaxQueueLength:     maxQueue,
+			},
+		},
+	}, WithStrictStartup())
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+	return hub
+}
+
+// sleepAsync 在后台调用sleep工具，返回的通道传递调用结果
+func sleepAsync(hub *MCPHub, ms int) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := hub.InvokeTool(context.Background(), "proc_sleep", map[string]any{"ms": ms})
+		done <- err
+	}()
+	return done
+}
+
+// waitCalls 等待服务器的进行中和排队调用数达到预期
+func waitCalls(t *testing.T, hub *MCPHub, active, queued int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		status := hub.ServerStatuses()["proc"]
+		return status.ActiveCalls == active && status.QueuedCalls == queued
+	}, 5*time.Second, 5*time.Millisecond)
+}
+
+// TestCallLimitsQueue 测试超过并发上限的调用排队等待
+func TestCallLimitsQueue(t *testing.T) {
+	serverPath := buildTestServer(t)
+	hub := newLimitedHub(t, serverPath, 2, 0)
+
+	const calls = 6
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		peaks []int
+		errs  []error
+	)
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := hub.InvokeTool(context.Background(), "proc_sleep", map[string]any{"ms": 200})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			peak, err := strconv.Atoi(strings.Trim(result, `"`))
+			assert.NoError(t, err)
+			peaks = append(peaks, peak)
+		}()
+	}
+	waitCalls(t, hub, 2, calls-2)
+	wg.Wait()
+
+	assert.Empty(t, errs)
+	require.Len(t, peaks, calls)
+	for _, peak := range peaks {
+		assert.LessOrEqual(t, peak, 2, "服务器同时收到的调用数不超过上限")
+	}
+	waitCalls(t, hub, 0, 0)
+}
+
+// TestCallLimitsOverload 测试队列已满时调用立即失败，排队的调用随上下文取消
+func TestCallLimitsOverload(t *testing.T) {
+	serverPath := buildTestServer(t)
+
+	t.Run("queue full", func(t *testing.T) {
+		hub := newLimitedHub(t, serverPath, 1, 1)
+		first := sleepAsync(hub, 500)
+		waitCalls(t, hub, 1, 0)
+		second := sleepAsync(hub, 10)
+		waitCalls(t, hub, 1, 1)
+
+		start := time.Now()
+		_, err := hub.InvokeTool(context.Background(), "proc_sleep", map[string]any{"ms": 10})
+		assert.True(t, errors.Is(err, ErrServerOverloaded))
+		assert.ErrorContains(t, err, "1 个调用排队")
+		assert.Less(t, time.Since(start), 200*time.Millisecond)
+
+		assert.NoError(t, <-first)
+		assert.NoError(t, <-second)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		hub := newLimitedHub(t, serverPath, 1, 0)
+		first := sleepAsync(hub, 500)
+		waitCalls(t, hub, 1, 0)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := hub.InvokeTool(ctx, "proc_sleep", map[string]any{"ms": 10})
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+		waitCalls(t, hub, 1, 0)
+		assert.NoError(t, <-first)
+	})
+}
+
+// TestCallLimitsConfig 测试调用限制的解析、校验和热更新
+func TestCallLimitsConfig(t *testing.T) {
+	settings, err := LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "maxConcurrentCalls": 2, "maxQueueLength": 10}}}`)
+	require.NoError(t, err)
+	config := settings.MCPServers["local"]
+	assert.Equal(t, 2, config.MaxConcurrentCalls)
+	assert.Equal(t, 10, config.MaxQueueLength)
+
+	_, err = LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "maxConcurrentCalls": -1}}}`)
+	assert.ErrorContains(t, err, "must not be negative")
+	_, err = LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "maxQueueLength": 5}}}`)
+	assert.ErrorContains(t, err, "maxQueueLength requires maxConcurrentCalls")
+
+	// 修改调用限制不需要重新连接
+	changed := *config
+	changed.MaxConcurrentCalls = 4
+	changed.MaxQueueLength = 0
+	assert.False(t, connectionChanged(config, &changed))
+}
+
+// TestCallLimitsOrder 测试排队的调用按到达顺序得到槽位
+func TestCallLimitsOrder(t *testing.T) {
+	limiter := newCallLimiter(&ServerConfig{MaxConcurrentCalls: 1})
+	release, err := limiter.acquire(context.Background(), "proc")
+	require.NoError(t, err)
+
+	const waiters = 5
+	order := make(chan int, waiters)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			release, err := limiter.acquire(context.Background(), "proc")
+			if !assert.NoError(t, err) {
+				return
+			}
+			order <- i
+			release()
+		}()
+		require.Eventually(t, func() bool {
+			_, queued := limiter.load()
+			return queued == i+1
+		}, time.Second, time.Millisecond)
+	}
+
+	release()
+	for i := 0; i < waiters; i++ {
+		assert.Equal(t, i, <-order)
+	}
+}
+
+// TestCallLimitsReload 测试热更新调用限制时进行中的调用计入新的上限
+func TestCallLimitsReload(t *testing.T) {
+	serverPath := buildTestServer(t)
+	hub := newLimitedHub(t, serverPath, 2, 0)
+	first := sleepAsync(hub, 300)
+	second := sleepAsync(hub, 300)
+	waitCalls(t, hub, 2, 0)
+
+	// 与配置热更新一样替换服务器配置
+	hub.mu.Lock()
+	changed := *hub.config.MCPServers["proc"]
+	changed.MaxConcurrentCalls = 1
+	hub.config.MCPServers["proc"] = &changed
+	hub.mu.Unlock()
+
+	third := sleepAsync(hub, 10)
+	waitCalls(t, hub, 2, 1)
+	assert.NoError(t, <-first)
+	assert.NoError(t, <-second)
+	assert.NoError(t, <-third)
+	waitCalls(t, hub, 0, 0)
+}
@@ -31,26 +31,29 @@ package einomcphost
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
-	"os"
-	"slices"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/bytedance/sonic"
 	"github.com/cloudwego/eino/components/tool"
-	"github.com/cloudwego/eino/components/tool/utils"
 	"github.com/cloudwego/eino/schema"
 	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/mark3labs/mcp-go/client"
+	mcptransport "github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/pkg/errors"
 )
 
+// DefaultStartupConcurrency is the default number of servers connected in parallel
+// while the hub starts.
+const DefaultStartupConcurrency = 8
+
 // Error message constants
 const (
 	errMsgUnknownError = "unknown error"
@@ -70,10 +73,60 @@ type MCPTools struct {
 // The hub automatically discovers tools from connected servers and makes them available
 // through the Eino framework.
 type MCPHub struct {
-	mu          sync.RWMutex                  // Protects concurrent access to connections and tools
-	connections map[string]*Connection        // Active server connections indexed by server name
-	tools       map[string]tool.InvokableTool // Available tools from all servers indexed by tool key
-	config      *MCPSettings                  // Configuration settings for all servers
+	mu            sync.RWMutex                  // Protects concurrent access to connections and tools
+	connections   map[string]*Connection        // Active server connections indexed by server name
+	tools         map[string]tool.InvokableTool // Available tools from all servers indexed by tool key
+	config        *MCPSettings                  // Configuration settings for all servers
+	status        map[string]*ServerStatus      // Per-server startup outcome indexed by server name
+	strictStartup bool                          // Fail hub creation if any enabled server fails to start
+
+	startupConcurrency int           // Maximum number of servers connected in parallel during startup
+	startupTimeout     time.Duration // Overall deadline for connecting all servers, 0 means no deadline
+
+	reconnectPolicy ReconnectPolicy              // Backoff policy for re-establishing dropped connections
+	restartPolicy   RestartPolicy                // Restart policy of stdio servers without their own
+	stderrLines     int                          // Number of stderr lines kept per stdio server
+	supervisors     map[string]*serverSupervisor // Process info and stderr of stdio servers indexed by server name
+	reconnectMu     sync.Mutex                   // Protects reconnects and connecting
+	reconnects      map[string]*reconnectFlight  // In-flight reconnect attempts indexed by server name
+	connecting      map[string]*reconnectFlight  // In-flight first connections of lazy servers indexed by server name
+	eventHandlers   []func(HubEvent)             // Receivers of hub lifecycle events
+
+	lazyConnect bool                     // Connect servers without a lazy setting on first use
+	idleTimeout time.Duration            // Idle timeout of lazy servers without their own, 0 keeps them connected
+	manifest    map[string]manifestEntry // Known tools of each server indexed by server name
+	idleMu      sync.Mutex               // Protects idle
+	idle        map[string]*idleTracker  // Calls in flight and idle timers indexed by server name
+	toolCache   *toolCache               // Persisted tools of servers, nil without WithToolCache
+	limiters    map[string]*callLimiter  // Call limits of servers with maxConcurrentCalls, protected by mu
+
+	contentPolicy ContentPolicy // Conversion of tool result content into tool output
+
+	serverTools    map[string][]*preparedTool // Registered tools of each server, source of tools
+	toolIndex      map[string]*preparedTool   // Prepared tool behind each entry of tools
+	namingStrategy NamingStrategy             // How exposed tool names are built
+	toolNameFunc   ToolNameFunc               // Custom tool naming, overrides namingStrategy
+
+	adminMu sync.Mutex // Serializes AddServer, RemoveServer and the other runtime server changes
+
+	configPath          string            // File the settings were loaded from, empty unless created by NewMCPHub
+	configDigest        [sha256.Size]byte // SHA-256 of the configuration file when it was loaded
+	configWatchInterval time.Duration     // Polling interval of the configuration file, 0 disables watching
+	reloadMu            sync.Mutex        // Serializes ReloadConfig
+
+	secretResolvers map[string]SecretResolver // Resolvers of secret references indexed by URL scheme
+	secretsAnywhere bool                      // Resolve secret references in command, args, env and url too
+	headerProvider  HeaderProvider            // Per-request HTTP headers of SSE and streamable HTTP servers
+	tokenStore      TokenStore                // OAuth tokens indexed by server name
+	httpClients     map[string]*http.Client   // Custom HTTP clients indexed by server name
+	oauthAuthorizer OAuthAuthorizer           // Interactive step of the OAuth authorization code grant
+
+	approver     ToolApprover      // Confirms calls to tools that are not auto-approved, nil runs every tool
+	interceptors []ToolInterceptor // Chain wrapping every tool call, outermost first
+
+	ctx    context.Context    // Lifetime context, cancelled by CloseServers
+	cancel context.CancelFunc // Cancels ctx
+	closed bool               // Set once CloseServers has been called
 }
 
 // Connection represents a connection to a single MCP server.
@@ -91,16 +144,33 @@ func newMCPHub(ctx context.Context, settings *MCPSettings, opts ...MCPHubOption)
 		connections: make(map[string]*Connection),
 		tools:       make(map[string]tool.InvokableTool),
 		config:      settings,
+		status:      make(map[string]*ServerStatus),
+
+		reconnectPolicy: DefaultReconnectPolicy,
+		restartPolicy:   DefaultRestartPolicy,
+		stderrLines:     DefaultStderrLines,
+		secretResolvers: defaultSecretResolvers(),
+		tokenStore:      NewMemoryTokenStore(),
 	}
+	h.ctx, h.cancel = context.WithCancel(context.WithoutCancel(ctx))
 
 	for _, o := range opts {
 		o(h)
 	}
 
 	if err := h.initializeServers(ctx); err != nil {
+		h.CloseServers()
 		return nil, fmt.Errorf("初始化服务器失败: %w", err)
 	}
 
+	if h.configWatchInterval > 0 {
+		if h.configPath == "" {
+			logf("WithConfigWatch 只对 NewMCPHub 从配置文件创建的hub生效")
+		} else {
+			go h.watchConfig(h.configWatchInterval)
+		}
+	}
+
 	return h, nil
 }
 
@@ -124,6 +194,35 @@ func NewMCPHubFromString(ctx context.Context, config string, opts ...MCPHubOptio
 
 type MCPHubOption func(*MCPHub)
 
+// WithStrictStartup makes hub creation all-or-nothing.
+// By default the hub starts in degraded mode: servers that fail to connect,
+// initialize or list their tools are recorded in ServerStatuses and skipped.
+// With strict startup the first failure aborts NewMCPHub and closes every
+// connection that was already established.
+func WithStrictStartup() MCPHubOption {
+	return func(h *MCPHub) {
+		h.strictStartup = true
+	}
+}
+
+// WithStartupConcurrency limits how many servers are connected, initialized and
+// queried for tools in parallel while the hub starts.
+// Values less than 1 fall back to DefaultStartupConcurrency.
+func WithStartupConcurrency(n int) MCPHubOption {
+	return func(h *MCPHub) {
+		h.startupConcurrency = n
+	}
+}
+
+// WithStartupTimeout sets an overall deadline for hub startup.
+// Servers that have not finished connecting when the deadline expires are
+// recorded as failed; in strict mode hub creation fails.
+func WithStartupTimeout(timeout time.Duration) MCPHubOption {
+	return func(h *MCPHub) {
+		h.startupTimeout = timeout
+	}
+}
+
 // WithInprocessMCPClient adds a custom MCP client to the hub.
 // It allows users to provide their own MCP client implementation
 // for specific servers, overriding the default client creation logic.
@@ -158,11 +257,13 @@ func WithInprocessMCPClient(name string, client *client.Client) MCPHubOption {
 //   - *MCPHub: Initialized MCPHub instance
 //   - error: Error if initialization fails
 func NewMCPHub(ctx context.Context, configPath string, opts ...MCPHubOption) (*MCPHub, error) {
+	// 在加载前记录文件摘要，加载后发生的修改会被配置监听发现
+	digest, _ := fileDigest(configPath)
 	settings, err := LoadSettings(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("加载配置文件失败: %w", err)
 	}
-	return newMCPHub(ctx, settings, opts...)
+	return newMCPHub(ctx, settings, append(opts, withConfigPath(configPath, digest))...)
 }
 
 // NewMCPHubFromSettings creates a new MCPHub directly from MCPSettings.
@@ -180,24 +281,91 @@ func NewMCPHubFromSettings(ctx context.Context, settings *MCPSettings, opts ...M
 }
 
 // initializeServers initializes all enabled MCP servers.
-// It iterates through the configuration and establishes connections to each enabled server.
+// Servers are connected concurrently, bounded by the startup concurrency and,
+// if configured, by the overall startup timeout. Failures are recorded per server;
+// unless strict startup is enabled the hub keeps going with the servers that did connect.
 func (h *MCPHub) initializeServers(ctx context.Context) error {
+	if h.startupTimeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, h.startupTimeout)
+		defer cancelTimeout()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := h.startupConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultStartupConcurrency
+	}
+	sem := make(chan struct{}, concurrency)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+
 	for name, config := range h.config.MCPServers {
-		if name == "inner" {
-			log.Printf("跳过内置工具服务器: %s", name)
+		if name == innerServerName {
+			logf("跳过内置工具服务器: %s", name)
 			continue
 		}
 
 		if config.Disabled {
-			log.Printf("跳过已禁用的服务器: %s", name)
+			logf("跳过已禁用的服务器: %s", name)
+			h.mu.Lock()
+			h.setServerStatus(ServerStatus{MCPTools: MCPTools{Name: name}, State: ServerStateDisabled})
+			h.mu.Unlock()
 			continue
 		}
 
-		if err := h.connectToServer(ctx, name, config); err != nil {
-			return fmt.Errorf("连接服务器 %s 失败: %w", name, err)
-		}
+		wg.Add(1)
+		go func(name string, config *ServerConfig) {
+			defer wg.Done()
+
+			var err error
+			select {
+			case sem <- struct{}{}:
+				err = h.startServer(ctx, name, config)
+				<-sem
+			case <-ctx.Done():
+				// 启动超时或严格模式下已有服务器失败，未开始的服务器直接记为失败
+				err = &ServerError{Server: name, Stage: StageConnect, Err: ctx.Err()}
+				h.mu.Lock()
+				h.setServerStatus(ServerStatus{MCPTools: MCPTools{Name: name, Err: err}, State: ServerStateFailed})
+				h.mu.Unlock()
+			}
+
+			if err != nil {
+				if h.strictStartup {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("连接服务器 %s 失败: %w", name, err)
+						cancel()
+					})
+					return
+				}
+				logf("连接服务器 %s 失败，已跳过: %v", name, err)
+			}
+		}(name, config)
+	}
+
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
 	}
 
+	h.mu.RLock()
+	failed := h.failedServerNames()
+	h.mu.RUnlock()
+	if len(failed) > 0 {
+		logf("部分MCP服务器启动失败，以降级模式运行: %s", strings.Join(failed, ", "))
+	}
+
+	h.mu.RLock()
+	h.logUnmatchedToolPatterns()
+	h.mu.RUnlock()
+
 	return nil
 }
 
@@ -211,140 +379,244 @@ func (h *MCPHub) initializeServers(ctx context.Context) error {
 //   - client.MCPClient: MCP client for the server
 //   - error: Error if server not found or disabled
 func (h *MCPHub) GetClient(serverName string) (client.MCPClient, error) {
+	conn, err := h.getConnection(serverName)
+	if err != nil {
+		return nil, err
+	}
+	return conn.Client, nil
+}
+
+// getConnection returns a snapshot of the live connection of a server.
+// The copy stays valid if the connection's client is swapped later.
+func (h *MCPHub) getConnection(serverName string) (Connection, error) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
 	conn, exists := h.connections[serverName]
 	if !exists {
-		return nil, fmt.Errorf("未找到服务器连接: %s", serverName)
+		return Connection{}, fmt.Errorf("未找到服务器连接: %s", serverName)
 	}
 
-	if conn.Config.Disabled {
-		return nil, fmt.Errorf("服务器已禁用: %s", serverName)
+	if conn.Config != nil && conn.Config.Disabled {
+		return Connection{}, fmt.Errorf("服务器已禁用: %s", serverName)
 	}
 
-	return conn.Client, nil
+	return *conn, nil
 }
 
 // createToolInvoker creates a tool invocation function for a specific server and tool.
 // This function encapsulates the logic for calling MCP tools and handling responses.
 // It returns a function that can be used by the Eino framework to invoke the tool.
-func createToolInvoker(serverName, toolName string, cli *client.Client) func(ctx context.Context, params map[string]interface{}) (string, error) {
+// Every content item of the result is converted according to the hub's ContentPolicy.
+// Results carrying structuredContent are returned as canonical JSON instead, after
+// validation against outputSchema when the tool declared one. Configured tool
+// overrides are applied to the arguments before the call, and tools that are not
+// auto-approved are confirmed by the hub's ToolApprover first. Approved calls pass
+// through the hub's interceptor chain.
+//
+// The invoker resolves the server's client on every call instead of capturing it,
+// so a connection replaced by reconnectServer is picked up by tools that were
+// already handed out through GetEinoTools.
+func (h *MCPHub) createToolInvoker(prepared *preparedTool) func(ctx context.Context, params map[string]interface{}) (string, error) {
+	serverName, toolName, outputSchema := prepared.server, prepared.mcpTool.Name, prepared.outputSchema
 	return func(ctx context.Context, params map[string]interface{}) (string, error) {
-		// 添加健康检查
-		if cli == nil {
-			return "", fmt.Errorf("MCP服务器客户端为空: %s", serverName)
+		args, denied, err := h.approveCall(ctx, prepared, prepared.override.applyArgs(params))
+		if err != nil {
+			return "", err
+		}
+		if denied != "" {
+			// 拒绝信息作为工具输出返回给模型，而不是中断调用链
+			invocationOutcome(ctx).denied = true
+			return denied, nil
 		}
 
-		// 使用ping检查连接状态
-		pingCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
-		defer cancel()
-
-		err := cli.Ping(pingCtx)
+		callToolResult, err := h.invokeChain(ctx, ToolCall{Server: serverName, Tool: toolName, Arguments: args})
 		if err != nil {
-			log.Printf("MCP服务器连接不可用: %s, 错误: %v", serverName, err)
-			return "", fmt.Errorf("MCP服务器连接不可用: %s, 错误: %v", serverName, err)
+			return "", err
+		}
+		if callToolResult == nil {
+			return "", fmt.Errorf("MCP: 工具调用 %s 返回空结果", toolName)
+		}
+
+		if callToolResult.StructuredContent != nil && !callToolResult.IsError && !h.contentPolicy.PreferText {
+			invocationOutcome(ctx).structured = true
+			return renderStructuredContent(toolName, outputSchema, callToolResult.StructuredContent)
+		}
+
+		if len(callToolResult.Content) == 0 {
+			if callToolResult.IsError {
+				return "", fmt.Errorf("MCP: 工具调用错误: %s", errMsgUnknownError)
+			}
+			return "", fmt.Errorf("MCP: 工具调用 %s 返回空内容", toolName)
 		}
 
-		req := mcp.CallToolRequest{}
-		req.Params.Name = toolName
-		req.Params.Arguments = params
+		output, err := h.contentPolicy.render(callToolResult.Content)
+		if err != nil {
+			return "", fmt.Errorf("MCP: 转换工具 %s 的返回内容失败: %w", toolName, err)
+		}
 
-		// 尝试调用工具，最多重试一次
-		var callToolResult *mcp.CallToolResult
-		var retryCount int = 1
+		if callToolResult.IsError {
+			return "", fmt.Errorf("MCP: 工具调用错误: %s", output)
+		}
 
-		for i := 0; i <= retryCount; i++ {
-			// 创建一个带超时的上下文，确保工具调用不会无限期阻塞
-			toolCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-			defer cancel()
+		return output, nil
+	}
+}
 
-			callToolResult, err = cli.CallTool(toolCtx, req)
-			if err == nil {
-				break // 调用成功，跳出重试循环
-			}
+// callTool sends a CallTool request to the given server.
+// The health check uses the server timeout and the call uses the tool timeout
+// from the server configuration. A failed health check or a transport error
+// triggers a reconnect according to the hub's ReconnectPolicy, after which the
+// call is retried once on the new client.
+func (h *MCPHub) callTool(ctx context.Context, serverName, toolName string, params map[string]interface{}) (*mcp.CallToolResult, error) {
+	h.beginUse(serverName)
+	defer h.endUse(serverName)
 
-			// 检查是否为连接已关闭错误
-			if i < retryCount && (strings.Contains(err.Error(), "file already closed") ||
-				strings.Contains(err.Error(), "transport error") ||
-				strings.Contains(err.Error(), "failed to write request")) {
-				log.Printf("工具调用出错 %s/%s: %v, 正在重试...", serverName, toolName, err)
-				time.Sleep(100 * time.Millisecond) // 短暂延迟后重试
-				continue
-			}
+	release, err := h.acquireCall(ctx, serverName)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
 
-			// 其他错误或已达到最大重试次数，返回错误
-			return "", errors.Wrapf(err, "调用工具 %s/%s 失败", serverName, toolName)
+	conn, err := h.getConnection(serverName)
+	if err != nil {
+		// 延迟连接的服务器在首次调用时连接
+		if conn, err = h.connectLazily(ctx, serverName, err); err != nil {
+			return nil, fmt.Errorf("MCP服务器客户端不可用: %w", err)
 		}
+	}
+	cli := conn.Client
+	config := conn.Config
+	if config == nil {
+		config = &ServerConfig{}
+	}
 
-		if callToolResult.IsError {
-			errMsg := errMsgUnknownError
-			if len(callToolResult.Content) > 0 {
-				errMsg = fmt.Sprintf("%v", callToolResult.Content[0])
-			}
-			return "", fmt.Errorf("MCP: 工具调用错误: %s", errMsg)
+	// 使用ping检查连接状态
+	pingCtx, cancel := context.WithTimeout(ctx, config.GetTimeoutDuration())
+	err = cli.Ping(pingCtx)
+	cancel()
+	if err != nil && ctx.Err() == nil {
+		logf("MCP服务器连接不可用: %s, 错误: %v", serverName, err)
+		if !h.reconnectEnabled() {
+			return nil, fmt.Errorf("MCP服务器连接不可用: %s, 错误: %v", serverName, err)
+		}
+		if cli, err = h.awaitReconnect(ctx, serverName, err); err != nil {
+			return nil, fmt.Errorf("MCP服务器连接不可用: %s, 错误: %w", serverName, err)
 		}
+	}
 
-		if len(callToolResult.Content) == 0 {
-			return "", fmt.Errorf("MCP: 工具调用 %s 返回空内容", toolName)
+	req := mcp.CallToolRequest{}
+	req.Params.Name = toolName
+	req.Params.Arguments = params
+
+	// 尝试调用工具，最多重试一次
+	var callToolResult *mcp.CallToolResult
+	var retryCount int = 1
+
+	for i := 0; i <= retryCount; i++ {
+		// 创建一个带超时的上下文，确保工具调用不会无限期阻塞
+		toolCtx, cancel := context.WithTimeout(ctx, config.GetToolTimeout(toolName))
+		callToolResult, err = cli.CallTool(toolCtx, req)
+		cancel()
+		if err == nil {
+			break // 调用成功，跳出重试循环
 		}
 
-		textContent, ok := callToolResult.Content[0].(mcp.TextContent)
-		if !ok {
-			return "", fmt.Errorf("MCP: 工具调用 %s 返回不支持的内容类型: %T", toolName, callToolResult.Content[0])
+		// 检查是否为连接已断开错误
+		if i < retryCount && ctx.Err() == nil && isConnectionError(err) {
+			logf("工具调用出错 %s/%s: %v, 正在重试...", serverName, toolName, err)
+			if !h.reconnectEnabled() {
+				time.Sleep(100 * time.Millisecond) // 短暂延迟后重试
+				continue
+			}
+			var reconnectErr error
+			if cli, reconnectErr = h.awaitReconnect(ctx, serverName, err); reconnectErr != nil {
+				return nil, errors.Wrapf(reconnectErr, "调用工具 %s/%s 失败", serverName, toolName)
+			}
+			continue
 		}
 
-		return textContent.Text, nil
+		// 其他错误或已达到最大重试次数，返回错误
+		return nil, errors.Wrapf(err, "调用工具 %s/%s 失败", serverName, toolName)
 	}
+
+	return callToolResult, nil
 }
 
-// discoverTools discovers and registers tools from a specific MCP server.
-// It converts MCP tool definitions to Eino tool format and registers them in the hub.
-func (h *MCPHub) discoverTools(ctx context.Context, serverName string, cli *client.Client) error {
+// discoverTools lists all tools of a specific MCP server. The allowedTools and
+// excludedTools filters are applied afterwards by filterTools, so the unfiltered
+// list can be used to report patterns that matched nothing.
+// It performs network I/O and therefore must be called without holding h.mu;
+// the result is registered afterwards with registerTools.
+func (h *MCPHub) discoverTools(ctx context.Context, cli client.MCPClient) ([]mcp.Tool, error) {
 	listResults, err := cli.ListTools(ctx, mcp.ListToolsRequest{})
 	if err != nil {
-		return fmt.Errorf("列出MCP工具失败: %w", err)
+		return nil, fmt.Errorf("列出MCP工具失败: %w", err)
 	}
 
-	for _, mcpTool := range listResults.Tools {
-		// 如果配置了allowedTools，则只注册allowedTools中的工具
-		if len(h.config.MCPServers[serverName].AllowedTools) > 0 && !slices.Contains(h.config.MCPServers[serverName].AllowedTools, mcpTool.Name) {
-			continue
-		}
-		// 如果配置了excludedTools，则不注册excludedTools中的工具
-		if len(h.config.MCPServers[serverName].ExcludedTools) > 0 && slices.Contains(h.config.MCPServers[serverName].ExcludedTools, mcpTool.Name) {
-			continue
-		}
-		if err := h.registerTool(serverName, mcpTool, cli); err != nil {
+	return listResults.Tools, nil
+}
+
+// registerTools converts discovered MCP tools to Eino tools and registers them in the hub,
+// replacing any tools previously registered for the server. Tool names are assigned
+// by the hub's naming strategy and the server's tool overrides. Callers must hold h.mu.
+func (h *MCPHub) registerTools(serverName string, config *ServerConfig, mcpTools []mcp.Tool) error {
+	prepared := make([]*preparedTool, 0, len(mcpTools))
+	for _, mcpTool := range mcpTools {
+		p, err := h.prepareTool(serverName, config, mcpTool)
+		if err != nil {
 			return fmt.Errorf("注册工具 %s 失败: %w", mcpTool.Name, err)
 		}
+		prepared = append(prepared, p)
 	}
 
+	if h.serverTools == nil {
+		h.serverTools = make(map[string][]*preparedTool)
+	}
+	h.serverTools[serverName] = prepared
+	h.rebuildTools()
 	return nil
 }
 
-// registerTool registers a single MCP tool as an Eino tool
-func (h *MCPHub) registerTool(serverName string, mcpTool mcp.Tool, cli *client.Client) error {
+// removeServerTools removes every registered tool that belongs to the given server.
+// Callers must hold h.mu.
+func (h *MCPHub) removeServerTools(serverName string) {
+	if _, ok := h.serverTools[serverName]; !ok {
+		return
+	}
+	delete(h.serverTools, serverName)
+	h.rebuildTools()
+}
+
+// prepareTool converts the schemas of a single MCP tool so it can be exposed as an Eino tool.
+// Hidden and fixed parameters from the tool override are removed from the input schema.
+func (h *MCPHub) prepareTool(serverName string, config *ServerConfig, mcpTool mcp.Tool) (*preparedTool, error) {
 	// Convert MCP tool schema to OpenAPI schema
 	inputSchema, err := h.convertToolSchema(mcpTool)
 	if err != nil {
-		return fmt.Errorf("转换工具模式失败: %w", err)
+		return nil, fmt.Errorf("转换工具模式失败: %w", err)
 	}
 
-	// Create tool key with server prefix
-	toolKey := serverName + "_" + mcpTool.Name
+	var override ToolOverride
+	if config != nil {
+		override = config.ToolOverrides[mcpTool.Name]
+	}
+	override.applySchema(inputSchema)
 
-	// Register the tool
-	h.tools[toolKey] = utils.NewTool(
-		&schema.ToolInfo{
-			Name:        mcpTool.Name,
-			Desc:        mcpTool.Description,
-			ParamsOneOf: schema.NewParamsOneOfByOpenAPIV3(inputSchema),
-		},
-		createToolInvoker(serverName, mcpTool.Name, cli),
-	)
+	// 输出模式无法转换时仍然注册工具，只是不校验结构化结果
+	outputSchema, err := h.convertOutputSchema(mcpTool)
+	if err != nil {
+		logf("工具 %s/%s 的输出模式无法使用，跳过结构化结果校验: %v", serverName, mcpTool.Name, err)
+		outputSchema = nil
+	}
 
-	return nil
+	return &preparedTool{
+		server:       serverName,
+		mcpTool:      mcpTool,
+		inputSchema:  inputSchema,
+		outputSchema: outputSchema,
+		override:     override,
+	}, nil
 }
 
 // convertToolSchema converts MCP tool input schema to OpenAPI v3 schema.
@@ -360,13 +632,7 @@ func (h *MCPHub) registerTool(serverName string, mcpTool mcp.Tool, cli *client.C
 func (h *MCPHub) convertToolSchema(mcpTool mcp.Tool) (*openapi3.Schema, error) {
 	// fetch mcp 的bug：https://github.com/modelcontextprotocol/servers/issues/1817
 	// 标准中exclusiveMaximum和exclusiveMinimum应该是bool，实际上设置成了integer，导致报错，需要处理
-	for _, v := range mcpTool.InputSchema.Properties {
-		switch values := v.(type) {
-		case map[string]any:
-			delete(values, "exclusiveMaximum")
-			delete(values, "exclusiveMinimum")
-		}
-	}
+	stripExclusiveBounds(mcpTool.InputSchema.Properties)
 
 	marshaledInputSchema, err := sonic.Marshal(mcpTool.InputSchema)
 	if err != nil {
@@ -398,57 +664,135 @@ func (h *MCPHub) convertToolSchema(mcpTool mcp.Tool) (*openapi3.Schema, error) {
 //   - config: Server configuration including transport and connection details
 //
 // Returns:
-//   - error: Error if connection establishment fails at any step
-func (h *MCPHub) connectToServer(ctx context.Context, serverName string, config *ServerConfig) error {
-	h.mu.Lock()
-	defer h.mu.Unlock()
+//   - error: *ServerError describing the failed stage if connection establishment fails
+func (h *MCPHub) connectToServer(ctx context.Context, serverName string, config *ServerConfig) (err error) {
+	// 记录每个服务器的启动结果，供 ServerStatuses 查询
+	var (
+		discovered []mcp.Tool
+		listed     []string
+	)
+	defer func() {
+		status := ServerStatus{
+			MCPTools:    MCPTools{Name: serverName, Tools: discovered, Err: err},
+			State:       ServerStateConnected,
+			ListedTools: listed,
+		}
+		if err != nil {
+			status.State = ServerStateFailed
+		}
+		h.mu.Lock()
+		h.setServerStatus(status)
+		h.mu.Unlock()
+	}()
 
 	// 先检查连接池中是否已有此服务器的连接
-	pool := GetConnectionPool()
-	existingHub, err := pool.GetHubByServerName(serverName)
+	if tools, names, ok := h.reusePooledConnection(serverName, config); ok {
+		discovered, listed = tools, names
+		logf("复用已有MCP服务器连接: %s", serverName)
+		return nil
+	}
 
-	// 如果找到已有连接，则复用该连接的客户端
-	if err == nil && existingHub != nil {
-		existingClient, err := existingHub.GetClient(serverName)
-		if err == nil && existingClient != nil {
-			// 存储复用的连接
-			h.connections[serverName] = &Connection{
-				Client: existingClient,
-				Config: config,
-			}
+	// 如果没有找到已有连接或复用失败，则创建新连接
+	logf("正在连接到MCP服务器: %s, %s", serverName, config.Transport)
 
-			// 复制相关工具
-			for toolKey, tool := range existingHub.tools {
-				if strings.HasPrefix(toolKey, serverName+"_") {
-					h.tools[toolKey] = tool
-				}
-			}
+	// Close existing connection if any
+	h.mu.Lock()
+	err = h.closeExistingConnection(serverName)
+	h.mu.Unlock()
+	if err != nil {
+		return &ServerError{Server: serverName, Stage: StageConnect, Err: fmt.Errorf("关闭现有连接失败: %w", err)}
+	}
 
-			log.Printf("复用已有MCP服务器连接: %s", serverName)
-			return nil
-		}
+	// 以下网络操作不持有锁，多个服务器可以并行连接
+	mcpClient, initResult, allTools, err := h.dialServer(ctx, serverName, config)
+	if err != nil {
+		return err
 	}
+	tools := h.filterTools(serverName, config, allTools)
 
-	// 如果没有找到已有连接或复用失败，则创建新连接
-	log.Printf("正在连接到MCP服务器: %s, %s", serverName, config.Transport)
+	// 合并结果到hub
+	h.mu.Lock()
+	defer h.mu.Unlock()
 
-	// Close existing connection if any
-	if err := h.closeExistingConnection(serverName); err != nil {
-		return fmt.Errorf("关闭现有连接失败: %w", err)
+	if h.closed {
+		mcpClient.Close()
+		return &ServerError{Server: serverName, Stage: StageConnect, Err: fmt.Errorf("MCPHub已关闭")}
+	}
+	if err := h.registerTools(serverName, config, tools); err != nil {
+		// 工具注册失败时不保留半注册状态
+		h.removeServerTools(serverName)
+		mcpClient.Close()
+		return &ServerError{Server: serverName, Stage: StageDiscover, Err: fmt.Errorf("发现工具失败: %w", err)}
+	}
+
+	// Store the connection
+	h.connections[serverName] = &Connection{
+		Client: mcpClient,
+		Config: config,
+	}
+	h.rememberTools(serverName, config, initResult, allTools)
+	if supervisor, ok := h.supervisors[serverName]; ok {
+		// 重新连接的服务器重新计算重启次数
+		supervisor.reset()
+	}
+	discovered, listed = tools, toolNames(allTools)
+
+	logf("成功连接到MCP服务器: %s", serverName)
+	return nil
+}
+
+// dialServer creates, starts and initializes a client for the server and lists its tools.
+// Initialize and ListTools are each bounded by the server timeout.
+// It performs network I/O and touches only the supervisor of stdio servers, so it is safe to
+// call without holding h.mu, both at startup and when reconnecting.
+//
+// Returns:
+//   - *client.Client: Initialized client, owned by the caller
+//   - *mcp.InitializeResult: Server info and capabilities from the handshake
+//   - []mcp.Tool: All tools listed by the server, before filtering
+//   - error: *ServerError describing the failed stage
+func (h *MCPHub) dialServer(ctx context.Context, serverName string, config *ServerConfig) (*client.Client, *mcp.InitializeResult, []mcp.Tool, error) {
+	// 秘密只在连接时解析到副本中，不写回配置
+	resolved, err := h.resolveConfig(ctx, config)
+	if err != nil {
+		return nil, nil, nil, &ServerError{Server: serverName, Stage: StageConnect, Err: err}
+	}
+	resolved.headerFunc = h.headerFunc(serverName)
+	resolved.client, err = h.httpClient(serverName, resolved)
+	if err != nil {
+		return nil, nil, nil, &ServerError{Server: serverName, Stage: StageConnect, Err: fmt.Errorf("创建HTTP客户端失败: %w", err)}
+	}
+	resolved.oauth = h.newOAuthSession(serverName, resolved)
+	if resolved.Transport == transportStdio {
+		resolved.process = newStdioProcess(serverName, resolved, h.supervisor(serverName))
+	}
+	if resolved.oauth != nil {
+		// 连接前完成授权，授权失败时报告清楚的原因而不是服务器的401
+		if _, err := resolved.oauth.accessToken(ctx, ""); err != nil {
+			return nil, nil, nil, &ServerError{Server: serverName, Stage: StageConnect, Err: fmt.Errorf("OAuth授权失败: %w", err)}
+		}
 	}
 
 	// Create new client based on transport type
-	mcpClient, err := h.createMCPClient(config)
+	mcpClient, err := h.createMCPClient(resolved)
 	if err != nil {
-		return fmt.Errorf("创建MCP客户端失败: %w", err)
+		return nil, nil, nil, &ServerError{Server: serverName, Stage: StageConnect, Err: fmt.Errorf("创建MCP客户端失败: %w", err)}
+	}
+	if resolved.process != nil {
+		if err := resolved.process.started(); err != nil {
+			mcpClient.Close()
+			return nil, nil, nil, &ServerError{Server: serverName, Stage: StageConnect, Err: err}
+		}
 	}
 
-	if err := mcpClient.Start(ctx); err != nil {
-		return fmt.Errorf("启动MCP客户端失败: %w", err)
+	if err := startMCPClient(ctx, mcpClient); err != nil {
+		return nil, nil, nil, &ServerError{Server: serverName, Stage: StageConnect, Err: resolved.process.withStderr(fmt.Errorf("启动MCP客户端失败: %w", err))}
 	}
 
 	// Setup logging for server stderr
-	h.setupServerLogging(mcpClient, serverName)
+	h.setupServerLogging(mcpClient, serverName, resolved.process)
+	h.watchConnection(mcpClient, serverName)
+	h.watchToolList(mcpClient, serverName)
 
 	// Initialize the client
 	initRequest := mcp.InitializeRequest{}
@@ -459,24 +803,119 @@ func (h *MCPHub) connectToServer(ctx context.Context, serverName string, config
 		Version: "0.1.0",
 	}
 
-	if _, err := mcpClient.Initialize(ctx, initRequest); err != nil {
+	initCtx, cancel := context.WithTimeout(ctx, config.GetTimeoutDuration())
+	initResult, err := mcpClient.Initialize(initCtx, initRequest)
+	cancel()
+	if err != nil {
 		mcpClient.Close()
-		return fmt.Errorf("初始化MCP客户端失败: %w", err)
+		return nil, nil, nil, &ServerError{Server: serverName, Stage: StageInitialize, Err: resolved.process.withStderr(fmt.Errorf("初始化MCP客户端失败: %w", err))}
 	}
 
-	// Store the connection
+	// Discover tools
+	listCtx, cancel := context.WithTimeout(ctx, config.GetTimeoutDuration())
+	tools, err := h.discoverTools(listCtx, mcpClient)
+	cancel()
+	if err != nil {
+		mcpClient.Close()
+		return nil, nil, nil, &ServerError{Server: serverName, Stage: StageDiscover, Err: resolved.process.withStderr(fmt.Errorf("发现工具失败: %w", err))}
+	}
+
+	return mcpClient, initResult, tools, nil
+}
+
+// reusePooledConnection tries to reuse a connection for the server from another
+// hub registered in the global connection pool. On success the connection and the
+// server's tools are copied into this hub.
+//
+// Returns:
+//   - []mcp.Tool: Tools registered in this hub after applying its filters
+//   - []string: Names of all tools listed by the server
+//   - bool: true if an existing connection was reused
+func (h *MCPHub) reusePooledConnection(serverName string, config *ServerConfig) ([]mcp.Tool, []string, bool) {
+	pool := GetConnectionPool()
+	existingHub, err := pool.GetHubByServerName(serverName)
+	if err != nil || existingHub == nil || existingHub == h {
+		return nil, nil, false
+	}
+
+	existing, err := existingHub.getConnection(serverName)
+	if err != nil || existing.Client == nil {
+		return nil, nil, false
+	}
+	// 同名服务器的连接参数不同时（例如被UpdateServer修改过）不能复用
+	if existing.Config != nil && serverConfigKey(serverName, existing.Config) != serverConfigKey(serverName, config) {
+		return nil, nil, false
+	}
+	// 自定义的HTTP客户端不在配置键中，只有使用同一个客户端的hub才能共享连接
+	if existingHub.httpClients[serverName] != h.httpClients[serverName] {
+		return nil, nil, false
+	}
+	existingClient := existing.Client
+
+	existingHub.mu.RLock()
+	var (
+		discovered []mcp.Tool
+		listed     []string
+	)
+	if existingStatus, ok := existingHub.status[serverName]; ok {
+		discovered, listed = existingStatus.Tools, existingStatus.ListedTools
+	}
+	existingHub.mu.RUnlock()
+	discovered = h.filterTools(serverName, config, discovered)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// 存储复用的连接
 	h.connections[serverName] = &Connection{
-		Client: mcpClient,
+		Client: existingClient,
 		Config: config,
 	}
+	// 按本hub的配置和命名策略重新注册工具，调用时使用本hub的连接
+	if err := h.registerTools(serverName, config, discovered); err != nil {
+		logf("注册复用连接 %s 的工具失败: %v", serverName, err)
+		delete(h.connections, serverName)
+		return nil, nil, false
+	}
+	// 共享的客户端在最后一个使用它的hub释放时才关闭，任一hub重连后其他hub随之切换
+	pool.shareClient(existingHub, h, existingClient)
 
-	// Discover and register tools
-	if err := h.discoverTools(ctx, serverName, mcpClient); err != nil {
-		return fmt.Errorf("发现工具失败: %w", err)
+	return discovered, listed, true
+}
+
+// closeClient closes a client of this hub, unless other hubs that reused it through
+// the connection pool still use it; then it is only released by this hub.
+func (h *MCPHub) closeClient(c client.MCPClient) error {
+	if !GetConnectionPool().releaseClient(h, c) {
+		return nil
 	}
+	return c.Close()
+}
 
-	log.Printf("成功连接到MCP服务器: %s", serverName)
-	return nil
+// startMCPClient starts the client transport.
+// SSE transports tie the lifetime of their event stream to the context passed to
+// Start, so the transport is started with a context that is not cancelled when
+// the startup deadline expires. The startup context still bounds how long we wait.
+func startMCPClient(ctx context.Context, mcpClient *client.Client) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- mcpClient.Start(context.WithoutCancel(ctx))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			mcpClient.Close()
+		}
+		return err
+	case <-ctx.Done():
+		// 等待启动结束后再关闭，避免泄漏
+		go func() {
+			<-done
+			mcpClient.Close()
+		}()
+		return ctx.Err()
+	}
 }
 
 // closeExistingConnection closes an existing connection if it exists.
@@ -490,7 +929,7 @@ func (h *MCPHub) connectToServer(ctx context.Context, serverName string, config
 //   - error: Error if connection closure fails
 func (h *MCPHub) closeExistingConnection(serverName string) error {
 	if existing, exists := h.connections[serverName]; exists {
-		if err := existing.Client.Close(); err != nil {
+		if err := h.closeClient(existing.Client); err != nil {
 			return fmt.Errorf("关闭现有连接失败: %w", err)
 		}
 		delete(h.connections, serverName)
@@ -513,36 +952,44 @@ func (h *MCPHub) closeExistingConnection(serverName string) error {
 //   - *client.Client: Configured MCP client ready for initialization
 //   - error: Error if client creation fails or transport type is unsupported
 func (h *MCPHub) createMCPClient(config *ServerConfig) (*client.Client, error) {
+	config, err := expandedConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("展开环境变量失败: %w", err)
+	}
+
 	switch config.Transport {
 	case transportSSE, transportHTTP1, transportHTTPStreamable:
-		// replace $(ENV) with os.Getenv(ENV)
-		if strings.Contains(config.URL, "${") {
-			keyStart := strings.Index(config.URL, "${")
-			keyEnd := strings.Index(config.URL[keyStart:], "}")
-			if keyStart != -1 && keyEnd != -1 {
-				key := config.URL[keyStart+2 : keyStart+keyEnd]
-				config.URL = strings.Replace(config.URL, "${"+key+"}", os.Getenv(key), 1)
-				log.Printf("替换环境变量: %s -> %s", "${"+key+"}", os.Getenv(key))
+		headers := config.requestHeaders()
+		if config.Transport == transportSSE {
+			var opts []mcptransport.ClientOption
+			if len(headers) > 0 {
+				opts = append(opts, mcptransport.WithHeaders(headers))
 			}
+			if config.headerFunc != nil {
+				opts = append(opts, mcptransport.WithHeaderFunc(config.headerFunc))
+			}
+			if httpClient := config.transportClient(); httpClient != nil {
+				opts = append(opts, mcptransport.WithHTTPClient(httpClient))
+			}
+			return client.NewSSEMCPClient(config.URL, opts...)
 		}
-		// proxyURL, err := url.Parse("http://127.0.0.1:18080")
-		// if err != nil {
-		// 	return nil, fmt.Errorf("解析代理URL失败: %w", err)
-		// }
-
-		// httpClient := &http.Client{
-		// 	Timeout: 30 * time.Second,
-		// 	Transport: &http.Transport{
-		// 		Proxy: http.ProxyURL(proxyURL),
-		// 	},
-		// }
-		// transport.WithHTTPClient(httpClient)
-		if config.Transport == transportSSE {
-			return client.NewSSEMCPClient(config.URL)
+		// 持续监听服务器推送，才能收到 tools/list_changed 等通知
+		opts := []mcptransport.StreamableHTTPCOption{mcptransport.WithContinuousListening()}
+		if len(headers) > 0 {
+			opts = append(opts, mcptransport.WithHTTPHeaders(headers))
 		}
-		return client.NewStreamableHttpClient(config.URL)
+		if config.headerFunc != nil {
+			opts = append(opts, mcptransport.WithHTTPHeaderFunc(config.headerFunc))
+		}
+		if httpClient := config.transportClient(); httpClient != nil {
+			opts = append(opts, mcptransport.WithHTTPBasicClient(httpClient))
+		}
+		return client.NewStreamableHttpClient(config.URL, opts...)
 	case transportStdio:
 		env := h.buildEnvironment(config.Env)
+		if config.process != nil {
+			return config.process.newClient(config.Command, env, config.Args)
+		}
 		return client.NewStdioMCPClient(config.Command, env, config.Args...)
 	case transportInprocess:
 		if config.inProcessClient == nil {
@@ -577,21 +1024,52 @@ func (h *MCPHub) buildEnvironment(envMap map[string]string) []string {
 // This is particularly useful for stdio-based MCP servers that may output
 // diagnostic information to stderr.
 //
+// The lines are also kept in the server's supervisor for error messages and
+// Diagnostics. When stderr is closed the process has exited; how it ended is
+// recorded in the server's status and the restart policy decides whether the
+// server is reconnected.
+//
 // Parameters:
 //   - mcpClient: MCP client instance to get stderr from
 //   - serverName: Server name used as prefix in log messages
-func (h *MCPHub) setupServerLogging(mcpClient *client.Client, serverName string) {
+//   - process: Controller of the server process, nil if it is not known
+func (h *MCPHub) setupServerLogging(mcpClient *client.Client, serverName string, process *stdioProcess) {
 	stderr, _ := client.GetStderr(mcpClient)
+	if process != nil {
+		stderr = process.transport.Stderr()
+	}
 
 	if stderr != nil {
 		go func() {
 			scanner := bufio.NewScanner(stderr)
 			for scanner.Scan() {
-				log.Printf("[%s] %s", serverName, scanner.Text())
+				line := scanner.Text()
+				logf("[%s] %s", serverName, line)
+				if process != nil {
+					process.supervisor.appendLine(line)
+					process.stderrLines.Add(1)
+				}
 			}
 			if err := scanner.Err(); err != nil && errors.Is(err, io.EOF) {
-				log.Printf("读取服务器 %s 的stderr时出错: %v", serverName, err)
+				logf("读取服务器 %s 的stderr时出错: %v", serverName, err)
+			}
+			if process != nil {
+				close(process.stderrDone)
+				// 由传输层唯一一次等待进程退出，exitResult读取它记录的退出状态
+				go process.transport.Close()
 			}
+			// stderr关闭意味着子进程已经退出
+			var cause error = errServerProcessExited
+			if process != nil {
+				if exit := process.exitResult(); exit != nil {
+					cause = exit
+					h.recordProcessExit(mcpClient, serverName, exit)
+					if err := h.superviseExit(serverName, process, exit); err != nil {
+						return
+					}
+				}
+			}
+			h.handleConnectionLost(mcpClient, serverName, cause)
 		}()
 	}
 }
@@ -610,16 +1088,31 @@ func (h *MCPHub) CloseServers() error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	// 停止后台重连
+	h.closed = true
+	if h.cancel != nil {
+		h.cancel()
+	}
+
 	var errors []error
 	for name, conn := range h.connections {
-		if err := conn.Client.Close(); err != nil {
+		if err := h.closeClient(conn.Client); err != nil {
 			errors = append(errors, fmt.Errorf("关闭服务器 %s 失败: %w", name, err))
 		}
 	}
 
+	secrets.forget(h)
+
+	// 写入尚未保存的工具缓存，短时运行的进程退出前也能保存
+	if err := h.toolCache.save(); err != nil {
+		logf("写入工具缓存失败: %v", err)
+	}
+
 	// Clear connections and tools
 	h.connections = make(map[string]*Connection)
 	h.tools = make(map[string]tool.InvokableTool)
+	h.serverTools = make(map[string][]*preparedTool)
+	h.toolIndex = make(map[string]*preparedTool)
 
 	if len(errors) > 0 {
 		return fmt.Errorf("关闭服务器时发生错误: %v", errors)
@@ -663,7 +1156,7 @@ func (h *MCPHub) GetEinoTools(ctx context.Context, toolNameList []string) ([]too
 		if t, exists := h.tools[toolName]; exists {
 			result = append(result, t)
 		} else {
-			log.Printf("工具不存在: %s\n", toolName)
+			logf("工具不存在: %s\n", toolName)
 			missingTools = append(missingTools, toolName)
 		}
 	}
@@ -719,10 +1212,10 @@ func (h *MCPHub) GetToolsMap(ctx context.Context) (map[string]*schema.ToolInfo,
 //   - string: Tool execution result as returned by the MCP server
 //   - error: Error if tool is not found, argument serialization fails, or invocation fails
 func (h *MCPHub) InvokeTool(ctx context.Context, toolName string, arguments map[string]any) (string, error) {
+	// 只在查找工具时持有锁，调用期间可能需要重连并修改hub状态
 	h.mu.RLock()
-	defer h.mu.RUnlock()
-
 	t, exists := h.tools[toolName]
+	h.mu.RUnlock()
 	if !exists {
 		return "", fmt.Errorf("工具不存在: %s", toolName)
 	}
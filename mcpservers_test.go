@@ -10,6 +10,7 @@ import (
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -497,3 +498,109 @@ func TestMCPHubCreateClientWithStreamableTransport(t *testing.T) {
 		})
 	}
 }
+
+// silentServerConfig 返回一个读取stdin但从不响应的stdio服务器配置，用于模拟启动缓慢的服务器
+func silentServerConfig() *ServerConfig {
+	return &ServerConfig{
+		Transport: transportStdio,
+		Command:   "sh",
+		Args:      []string{"-c", "while read line; do :; done"},
+	}
+}
+
+// TestParallelStartupWithTimeout 测试并行启动和全局启动超时
+func TestParallelStartupWithTimeout(t *testing.T) {
+	ctx := context.Background()
+
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"silent1": silentServerConfig(),
+			"silent2": silentServerConfig(),
+			"silent3": silentServerConfig(),
+		},
+	}
+
+	cli := newTestInprocessClient(t, newTestInprocessServer(t))
+
+	start := time.Now()
+	hub, err := NewMCPHubFromSettings(ctx, settings,
+		WithInprocessMCPClient("healthy", cli),
+		WithStartupConcurrency(4),
+		WithStartupTimeout(time.Second),
+	)
+	elapsed := time.Since(start)
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	// 三个慢服务器并行等待同一个截止时间，而不是依次超时
+	assert.Less(t, elapsed, 3*time.Second)
+
+	failed := hub.FailedServers()
+	assert.Len(t, failed, 3)
+	for name, err := range failed {
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "server %s", name)
+	}
+
+	tools, err := hub.GetEinoTools(ctx, []string{"healthy_echo"})
+	require.NoError(t, err)
+	assert.Len(t, tools, 1)
+}
+
+// TestStartupConcurrencyLimit 测试启动并发数限制下排队的服务器在超时后被记为失败
+func TestStartupConcurrencyLimit(t *testing.T) {
+	ctx := context.Background()
+
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"silent1": silentServerConfig(),
+			"silent2": silentServerConfig(),
+		},
+	}
+
+	hub, err := NewMCPHubFromSettings(ctx, settings,
+		WithStartupConcurrency(1),
+		WithStartupTimeout(500*time.Millisecond),
+	)
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	statuses := hub.ServerStatuses()
+	require.Len(t, statuses, 2)
+	for _, status := range statuses {
+		assert.Equal(t, ServerStateFailed, status.State)
+		assert.ErrorIs(t, status.Err, context.DeadlineExceeded)
+	}
+}
+
+// TestToolTimeoutOverride 测试工具调用使用配置中的按工具超时
+func TestToolTimeoutOverride(t *testing.T) {
+	ctx := context.Background()
+
+	s := newTestInprocessServer(t)
+	s.AddTool(
+		mcp.NewTool("slow", mcp.WithDescription("never finishes in time")),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(10 * time.Second):
+				return mcp.NewToolResultText("done"), nil
+			}
+		},
+	)
+	cli := newTestInprocessClient(t, s)
+
+	hub, err := NewMCPHubFromSettings(ctx, &MCPSettings{MCPServers: map[string]*ServerConfig{}},
+		WithInprocessMCPClient("local", cli), WithReconnectPolicy(ReconnectPolicy{}))
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	conn, err := hub.getConnection("local")
+	require.NoError(t, err)
+	conn.Config.ToolTimeouts = map[string]time.Duration{"slow": 200 * time.Millisecond}
+
+	start := time.Now()
+	_, err = hub.InvokeTool(ctx, "local_slow", nil)
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
@@ -7,7 +7,6 @@ package einomcphost
 import (
 	"context"
 	"errors"
-	"log"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/mark3labs/mcp-go/client"
@@ -51,7 +50,7 @@ func (mcpToolsCollection *MCPToolsCollection) GetTools(names []string) []tool.Ba
 		if tool, ok := mcpToolsCollection.toolsMap[name]; ok {
 			tools = append(tools, tool)
 		} else {
-			log.Printf("[WARNING] tool %s not found", name)
+			logf("[WARNING] tool %s not found", name)
 		}
 	}
 	return tools
@@ -94,7 +93,7 @@ func GetMcpTools(ctx context.Context, configPath string, toolNames []string, opt
 	if mcpToolFnOptions.clientMcpServer != nil {
 		inProcessClient, err := client.NewInProcessClient(mcpToolFnOptions.clientMcpServer)
 		if err != nil {
-			log.Fatal(err)
+			fatalf("%v", err)
 		}
 		// defer inProcessClient.Close()
 		mcpToolsCollection.deferFuncs = append(mcpToolsCollection.deferFuncs, inProcessClient.Close)
@@ -104,7 +103,7 @@ func GetMcpTools(ctx context.Context, configPath string, toolNames []string, opt
 	// 创建MCPHub
 	hub, err := NewMCPHub(ctx, configPath, hubOptions...)
 	if err != nil {
-		log.Fatal(err)
+		fatalf("%v", err)
 	}
 	// defer hub.CloseServers()
 	mcpToolsCollection.deferFuncs = append(mcpToolsCollection.deferFuncs, hub.CloseServers)
@@ -112,9 +111,9 @@ func GetMcpTools(ctx context.Context, configPath string, toolNames []string, opt
 	// 获取工具map
 	toolsMap, err := hub.GetToolsMap(ctx)
 	if err != nil {
-		log.Fatal(err)
+		fatalf("%v", err)
 	}
-	log.Println(toolsMap)
+	logf("%v", toolsMap)
 
 	// 获取工具
 	mcpToolsCollection.tools, err = hub.GetEinoTools(ctx, toolNames)
@@ -125,7 +124,7 @@ func GetMcpTools(ctx context.Context, configPath string, toolNames []string, opt
 	for _, tool := range mcpToolsCollection.tools {
 		toolInfo, err := tool.Info(ctx)
 		if err != nil {
-			log.Fatal(err)
+			fatalf("%v", err)
 		}
 		mcpToolsCollection.toolsMap[toolInfo.Name] = tool
 	}
@@ -0,0 +1,193 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/schema"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// MaxToolNameLength is the longest tool name accepted by OpenAI-compatible APIs.
+const MaxToolNameLength = 64
+
+// ToolInfo Extra keys identifying where an Eino tool comes from.
+const (
+	ToolInfoExtraServer   = "mcpServer" // Name of the MCP server that provides the tool
+	ToolInfoExtraToolName = "mcpTool"   // Original tool name on the MCP server
+)
+
+// NamingStrategy selects how MCP tools are named when exposed to Eino and the model.
+type NamingStrategy string
+
+// Naming strategy constants
+const (
+	// NamingPrefixed names every tool serverName_toolName. This is the default.
+	NamingPrefixed NamingStrategy = "prefixed"
+	// NamingBare uses the tool name as reported by the server. Tools whose name is
+	// provided by more than one server fall back to the prefixed name.
+	NamingBare NamingStrategy = "bare"
+)
+
+// ToolNameFunc returns the name under which a server's tool is exposed.
+// The result is sanitized like every other tool name.
+type ToolNameFunc func(serverName, toolName string) string
+
+// WithNamingStrategy selects how tool names are built. The same name is used as the
+// key for InvokeTool and GetEinoTools and as schema.ToolInfo.Name, so the model
+// sees exactly the names the hub resolves.
+func WithNamingStrategy(strategy NamingStrategy) MCPHubOption {
+	return func(h *MCPHub) {
+		h.namingStrategy = strategy
+		h.toolNameFunc = nil
+	}
+}
+
+// WithToolNameFunc names tools with a custom function instead of a NamingStrategy.
+// Names that still collide after sanitizing get a numeric suffix.
+func WithToolNameFunc(fn ToolNameFunc) MCPHubOption {
+	return func(h *MCPHub) {
+		h.toolNameFunc = fn
+	}
+}
+
+// SanitizeToolName maps a name to the character set and length accepted by
+// OpenAI-compatible APIs: ^[a-zA-Z0-9_-]{1,64}$. Other characters are replaced by
+// '_', and names that are too long are truncated and given a short hash suffix so
+// distinct long names stay distinct.
+//
+// Parameters:
+//   - name: Tool name to sanitize
+//
+// Returns:
+//   - string: Valid tool name
+func SanitizeToolName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+
+	sanitized := b.String()
+	if sanitized == "" {
+		return "tool"
+	}
+	if len(sanitized) > MaxToolNameLength {
+		sum := sha256.Sum256([]byte(name))
+		suffix := "_" + hex.EncodeToString(sum[:4])
+		sanitized = sanitized[:MaxToolNameLength-len(suffix)] + suffix
+	}
+	return sanitized
+}
+
+// preparedTool is an MCP tool whose schemas have been converted and that is ready
+// to be exposed to Eino under whatever name the naming strategy assigns.
+type preparedTool struct {
+	server       string           // Server providing the tool
+	mcpTool      mcp.Tool         // Tool definition as reported by the server
+	inputSchema  *openapi3.Schema // Converted input schema
+	outputSchema *openapi3.Schema // Converted output schema, nil if none was declared
+	override     ToolOverride     // Configured override, zero if none
+}
+
+// name returns the tool name before the naming strategy is applied.
+func (p *preparedTool) name() string {
+	return p.override.exposedName(p.mcpTool.Name)
+}
+
+// baseToolName returns the unsanitized name of a tool before collision handling.
+func (h *MCPHub) baseToolName(serverName, toolName string) string {
+	if h.toolNameFunc != nil {
+		return h.toolNameFunc(serverName, toolName)
+	}
+	if h.namingStrategy == NamingBare {
+		return toolName
+	}
+	return serverName + "_" + toolName
+}
+
+// rebuildTools assigns names to the prepared tools of every server and rebuilds the
+// Eino tool map. Servers are processed in name order so collisions are resolved the
+// same way no matter in which order servers connected. Callers must hold h.mu.
+func (h *MCPHub) rebuildTools() {
+	servers := make([]string, 0, len(h.serverTools))
+	for name := range h.serverTools {
+		servers = append(servers, name)
+	}
+	sort.Strings(servers)
+
+	// 裸名称模式下，多个服务器提供同名工具时退回带前缀的名称
+	bareOwners := make(map[string]int)
+	if h.toolNameFunc == nil && h.namingStrategy == NamingBare {
+		for _, server := range servers {
+			for _, prepared := range h.serverTools[server] {
+				bareOwners[prepared.name()]++
+			}
+		}
+	}
+
+	tools := make(map[string]tool.InvokableTool)
+	index := make(map[string]*preparedTool)
+	for _, server := range servers {
+		for _, prepared := range h.serverTools[server] {
+			name := h.baseToolName(server, prepared.name())
+			if bareOwners[prepared.name()] > 1 {
+				logf("多个服务器提供同名工具 %s，使用带前缀的名称: %s_%s", prepared.name(), server, prepared.name())
+				name = server + "_" + prepared.name()
+			}
+			name = uniqueToolName(SanitizeToolName(name), tools)
+			tools[name] = h.buildTool(name, prepared)
+			index[name] = prepared
+		}
+	}
+	h.tools = tools
+	h.toolIndex = index
+}
+
+// uniqueToolName appends a numeric suffix to name until it is not used in tools.
+func uniqueToolName(name string, tools map[string]tool.InvokableTool) string {
+	if _, taken := tools[name]; !taken {
+		return name
+	}
+	for i := 2; ; i++ {
+		suffix := fmt.Sprintf("_%d", i)
+		candidate := name
+		if len(candidate)+len(suffix) > MaxToolNameLength {
+			candidate = candidate[:MaxToolNameLength-len(suffix)]
+		}
+		candidate += suffix
+		if _, taken := tools[candidate]; !taken {
+			logf("工具名称 %s 冲突，重命名为 %s", name, candidate)
+			return candidate
+		}
+	}
+}
+
+// buildTool creates the Eino tool exposed under name. The invoker still calls the
+// tool by its original MCP name, even when the tool was renamed by an override.
+func (h *MCPHub) buildTool(name string, prepared *preparedTool) tool.InvokableTool {
+	toolInfo := &schema.ToolInfo{
+		Name:        name,
+		Desc:        prepared.override.description(prepared.mcpTool.Description),
+		ParamsOneOf: schema.NewParamsOneOfByOpenAPIV3(prepared.inputSchema),
+		Extra: map[string]any{
+			ToolInfoExtraServer:   prepared.server,
+			ToolInfoExtraToolName: prepared.mcpTool.Name,
+		},
+	}
+	if prepared.outputSchema != nil {
+		toolInfo.Extra[ToolInfoExtraOutputSchema] = prepared.outputSchema
+	}
+
+	return utils.NewTool(toolInfo, h.createToolInvoker(prepared))
+}
@@ -0,0 +1,90 @@
+package einomcphost
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newNamingTestHub 创建两个都提供echo工具的进程内服务器，其中beta额外提供ping工具
+func newNamingTestHub(t *testing.T, opts ...MCPHubOption) *MCPHub {
+	t.Helper()
+
+	beta := newTestInprocessServer(t)
+	beta.AddTool(mcp.NewTool("ping"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText("pong"), nil
+	})
+
+	opts = append(opts,
+		WithInprocessMCPClient("alpha", newTestInprocessClient(t, newTestInprocessServer(t))),
+		WithInprocessMCPClient("beta", newTestInprocessClient(t, beta)),
+	)
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{MCPServers: map[string]*ServerConfig{}}, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+	return hub
+}
+
+// exposedToolNames 返回hub中的工具key，并校验ToolInfo.Name与key一致
+func exposedToolNames(t *testing.T, hub *MCPHub) []string {
+	t.Helper()
+
+	toolsMap, err := hub.GetToolsMap(context.Background())
+	require.NoError(t, err)
+
+	var names []string
+	for key, info := range toolsMap {
+		assert.Equal(t, key, info.Name)
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TestNamingStrategies 测试不同命名策略下的工具名称
+func TestNamingStrategies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("默认带前缀", func(t *testing.T) {
+		hub := newNamingTestHub(t)
+		assert.Equal(t, []string{"alpha_echo", "beta_echo", "beta_ping"}, exposedToolNames(t, hub))
+	})
+
+	t.Run("裸名称冲突时退回前缀", func(t *testing.T) {
+		hub := newNamingTestHub(t, WithNamingStrategy(NamingBare))
+		assert.Equal(t, []string{"alpha_echo", "beta_echo", "ping"}, exposedToolNames(t, hub))
+
+		result, err := hub.InvokeTool(ctx, "ping", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "pong", result)
+	})
+
+	t.Run("自定义命名函数", func(t *testing.T) {
+		hub := newNamingTestHub(t, WithToolNameFunc(func(serverName, toolName string) string {
+			return "mcp." + toolName
+		}))
+		assert.Equal(t, []string{"mcp_echo", "mcp_echo_2", "mcp_ping"}, exposedToolNames(t, hub))
+
+		// 调用时仍使用服务器上的原始工具名
+		result, err := hub.InvokeTool(ctx, "mcp_echo_2", map[string]any{"message": "hi"})
+		require.NoError(t, err)
+		assert.Equal(t, "Echo: hi", result)
+	})
+}
+
+// TestSanitizeToolName 测试工具名称清理
+func TestSanitizeToolName(t *testing.T) {
+	assert.Equal(t, "github_search-repos", SanitizeToolName("github_search-repos"))
+	assert.Equal(t, "fs_read_file", SanitizeToolName("fs.read file"))
+	assert.Equal(t, "tool", SanitizeToolName(""))
+
+	long := strings.Repeat("a", 80)
+	sanitized := SanitizeToolName(long)
+	assert.Len(t, sanitized, MaxToolNameLength)
+	assert.NotEqual(t, sanitized, SanitizeToolName(long+"b"))
+}
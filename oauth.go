@@ -0,0 +1,600 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OAuth grant types supported in OAuthConfig.GrantType
+const (
+	// OAuthAuthorizationCode is the authorization code grant with PKCE, for servers
+	// acting on behalf of a user. It is the default grant type.
+	OAuthAuthorizationCode = "authorization_code"
+	// OAuthClientCredentials is the client credentials grant, for machine-to-machine access.
+	OAuthClientCredentials = "client_credentials"
+)
+
+// oauthExpirySkew is how long before its expiry an access token is refreshed, so a
+// token does not expire while a request is in flight.
+const oauthExpirySkew = 30 * time.Second
+
+// OAuthConfig configures OAuth 2.1 authorization of an sse, http or streamable
+// server as described by the MCP authorization specification. The authorization
+// server is discovered through the server's protected resource metadata unless
+// AuthorizationServer is set.
+type OAuthConfig struct {
+	GrantType           string   `json:"grantType,omitempty" yaml:"grantType,omitempty"`                     // "authorization_code" (default) or "client_credentials"
+	ClientID            string   `json:"clientId" yaml:"clientId"`                                           // Client registered with the authorization server
+	ClientSecret        string   `json:"clientSecret,omitempty" yaml:"clientSecret,omitempty"`               // Secret of confidential clients, required for client_credentials
+	Scopes              []string `json:"scopes,omitempty" yaml:"scopes,omitempty"`                           // Scopes to request
+	RedirectURI         string   `json:"redirectUri,omitempty" yaml:"redirectUri,omitempty"`                 // Redirect URI of the authorization code grant
+	AuthorizationServer string   `json:"authorizationServer,omitempty" yaml:"authorizationServer,omitempty"` // Issuer URL, skips protected resource metadata discovery
+}
+
+// grantType returns the configured grant type or the default.
+func (c *OAuthConfig) grantType() string {
+	if c.GrantType == "" {
+		return OAuthAuthorizationCode
+	}
+	return c.GrantType
+}
+
+// clone returns a deep copy of c, or nil if c is nil.
+func (c *OAuthConfig) clone() *OAuthConfig {
+	if c == nil {
+		return nil
+	}
+	o := *c
+	o.Scopes = slices.Clone(c.Scopes)
+	return &o
+}
+
+// OAuthToken is a token issued by an authorization server.
+type OAuthToken struct {
+	AccessToken  string    `json:"accessToken"`
+	TokenType    string    `json:"tokenType,omitempty"`
+	RefreshToken string    `json:"refreshToken,omitempty"`
+	ExpiresAt    time.Time `json:"expiresAt,omitzero"` // Zero if the token does not expire
+	Scope        string    `json:"scope,omitempty"`
+}
+
+// expired reports whether the token expires within oauthExpirySkew.
+func (t *OAuthToken) expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Until(t.ExpiresAt) < oauthExpirySkew
+}
+
+// TokenStore persists the OAuth tokens of servers, so that a restarted hub can reuse
+// them instead of authorizing again. Implementations must be safe for concurrent use.
+type TokenStore interface {
+	// LoadToken returns the token of a server, or nil if there is none.
+	LoadToken(ctx context.Context, serverName string) (*OAuthToken, error)
+	// SaveToken stores the token of a server, replacing the previous one.
+	SaveToken(ctx context.Context, serverName string, token *OAuthToken) error
+}
+
+// memoryTokenStore keeps tokens in memory for the lifetime of the hub.
+type memoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]OAuthToken
+}
+
+// NewMemoryTokenStore returns a TokenStore that keeps tokens in memory.
+// It is the default store of a hub.
+func NewMemoryTokenStore() TokenStore {
+	return &memoryTokenStore{tokens: make(map[string]OAuthToken)}
+}
+
+// LoadToken implements TokenStore.
+func (s *memoryTokenStore) LoadToken(ctx context.Context, serverName string) (*OAuthToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	token, ok := s.tokens[serverName]
+	if !ok {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+// SaveToken implements TokenStore.
+func (s *memoryTokenStore) SaveToken(ctx context.Context, serverName string, token *OAuthToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[serverName] = *token
+	return nil
+}
+
+// OAuthAuthorizer lets a user authorize the hub with the authorization code grant.
+// It must send the user to authorizationURL, typically by opening a browser, wait
+// until the authorization server redirects to the configured redirect URI and return
+// that redirect URL including its query, from which the code and state are taken.
+type OAuthAuthorizer func(ctx context.Context, serverName, authorizationURL string) (redirectURL string, err error)
+
+// WithOAuthAuthorizer sets the function that runs the interactive part of the
+// authorization code grant. Without it, servers using that grant can only connect
+// with a token that is already in the TokenStore or can be refreshed.
+//
+// Parameters:
+//   - authorizer: Function obtaining the user's consent
+func WithOAuthAuthorizer(authorizer OAuthAuthorizer) MCPHubOption {
+	return func(h *MCPHub) {
+		h.oauthAuthorizer = authorizer
+	}
+}
+
+// WithTokenStore sets where OAuth tokens are kept. Defaults to NewMemoryTokenStore.
+//
+// Parameters:
+//   - store: Storage of the tokens, keyed by server name
+func WithTokenStore(store TokenStore) MCPHubOption {
+	return func(h *MCPHub) {
+		h.tokenStore = store
+	}
+}
+
+// validateOAuthConfig checks the oauth block of a server.
+func validateOAuthConfig(name string, server *ServerConfig) error {
+	config := server.OAuth
+	if config == nil {
+		return nil
+	}
+	if server.Transport == transportStdio {
+		return fmt.Errorf(errMsgOAuthNotSupported, name)
+	}
+	if server.BearerToken != "" {
+		return fmt.Errorf(errMsgOAuthBearerToken, name)
+	}
+	if strings.TrimSpace(config.ClientID) == "" {
+		return fmt.Errorf(errMsgOAuthClientID, name)
+	}
+	switch config.grantType() {
+	case OAuthAuthorizationCode:
+		if strings.TrimSpace(config.RedirectURI) == "" {
+			return fmt.Errorf(errMsgOAuthRedirectURI, name)
+		}
+	case OAuthClientCredentials:
+		if config.ClientSecret == "" {
+			return fmt.Errorf(errMsgOAuthClientSecret, name)
+		}
+	default:
+		return fmt.Errorf(errMsgOAuthGrantType, name, config.GrantType)
+	}
+	return nil
+}
+
+// authServerMetadata holds the fields of RFC 8414 authorization server metadata used by the hub.
+type authServerMetadata struct {
+	Issuer                        string   `json:"issuer"`
+	AuthorizationEndpoint         string   `json:"authorization_endpoint"`
+	TokenEndpoint                 string   `json:"token_endpoint"`
+	TokenEndpointAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported []string `json:"code_challenge_methods_supported"`
+}
+
+// protectedResourceMetadata holds the fields of RFC 9728 protected resource metadata used by the hub.
+type protectedResourceMetadata struct {
+	Resource             string   `json:"resource"`
+	AuthorizationServers []string `json:"authorization_servers"`
+}
+
+// tokenResponse is the response of a token endpoint, successful or not.
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Scope            string `json:"scope"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// resourceMetadataPattern extracts resource_metadata from a WWW-Authenticate challenge.
+var resourceMetadataPattern = regexp.MustCompile(`resource_metadata="([^"]*)"`)
+
+// oauthSession obtains and refreshes the access token of one server connection.
+type oauthSession struct {
+	hub        *MCPHub
+	serverName string
+	resource   string // URL of the MCP server, sent as the RFC 8707 resource indicator
+	config     *OAuthConfig
+	store      TokenStore
+	authorizer OAuthAuthorizer
+	client     *http.Client // Client for metadata and token requests, with the server's proxy and TLS settings
+
+	mu                  sync.Mutex
+	metadata            *authServerMetadata // Discovered on first use
+	resourceMetadataURL string              // Taken from a WWW-Authenticate challenge
+}
+
+// newOAuthSession creates the OAuth session of a resolved server config, or returns
+// nil if the server does not use OAuth.
+func (h *MCPHub) newOAuthSession(serverName string, config *ServerConfig) *oauthSession {
+	if config.OAuth == nil {
+		return nil
+	}
+	store := h.tokenStore
+	if store == nil {
+		store = NewMemoryTokenStore()
+	}
+	client := config.client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &oauthSession{
+		hub:        h,
+		serverName: serverName,
+		resource:   config.URL,
+		config:     config.OAuth,
+		store:      store,
+		authorizer: h.oauthAuthorizer,
+		client:     client,
+	}
+}
+
+// accessToken returns a valid access token. A stored token is used while it is
+// valid and differs from rejected, the token the server has just refused; otherwise
+// it is refreshed or, if that fails, a new token is requested.
+func (s *oauthSession) accessToken(ctx context.Context, rejected string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := s.store.LoadToken(ctx, s.serverName)
+	if err != nil {
+		return "", fmt.Errorf("读取令牌失败: %w", err)
+	}
+	if token != nil && token.AccessToken != rejected && !token.expired() {
+		s.redactTokens(token)
+		return token.AccessToken, nil
+	}
+
+	metadata, err := s.authServerMetadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var fresh *OAuthToken
+	if token != nil && token.RefreshToken != "" {
+		fresh, err = s.refresh(ctx, metadata, token.RefreshToken)
+		if err != nil {
+			logf("刷新服务器 %s 的令牌失败，重新授权: %v", s.serverName, err)
+		}
+	}
+	if fresh == nil {
+		if s.config.grantType() == OAuthClientCredentials {
+			fresh, err = s.clientCredentials(ctx, metadata)
+		} else {
+			fresh, err = s.authorizationCode(ctx, metadata)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+
+	s.redactTokens(fresh)
+	if err := s.store.SaveToken(ctx, s.serverName, fresh); err != nil {
+		return "", fmt.Errorf("保存令牌失败: %w", err)
+	}
+	return fresh.AccessToken, nil
+}
+
+// redactTokens registers the tokens in use for redaction, replacing the ones they
+// were refreshed from.
+func (s *oauthSession) redactTokens(token *OAuthToken) {
+	secrets.set(secretSlot{hub: s.hub, server: s.serverName, name: "access_token"}, token.AccessToken)
+	secrets.set(secretSlot{hub: s.hub, server: s.serverName, name: "refresh_token"}, token.RefreshToken)
+}
+
+// challenged records the resource metadata URL of a 401 response, so the next
+// discovery uses it instead of the well-known location.
+func (s *oauthSession) challenged(header string) {
+	match := resourceMetadataPattern.FindStringSubmatch(header)
+	if match == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resourceMetadataURL != match[1] {
+		s.resourceMetadataURL = match[1]
+		s.metadata = nil
+	}
+}
+
+// authServerMetadata discovers the authorization server of the resource and fetches
+// its metadata. The result is cached for the lifetime of the session.
+func (s *oauthSession) authServerMetadata(ctx context.Context) (*authServerMetadata, error) {
+	if s.metadata != nil {
+		return s.metadata, nil
+	}
+
+	issuer := s.config.AuthorizationServer
+	if issuer == "" {
+		resource, err := s.protectedResourceMetadata(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(resource.AuthorizationServers) == 0 {
+			return nil, fmt.Errorf("受保护资源元数据没有列出授权服务器")
+		}
+		issuer = resource.AuthorizationServers[0]
+	}
+
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return nil, fmt.Errorf("解析授权服务器地址失败: %w", err)
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	var errs []error
+	for _, wellKnown := range []string{"/.well-known/oauth-authorization-server", "/.well-known/openid-configuration"} {
+		var metadata authServerMetadata
+		if err := s.getJSON(ctx, u.Scheme+"://"+u.Host+wellKnown+path, &metadata); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if metadata.TokenEndpoint == "" {
+			errs = append(errs, fmt.Errorf("授权服务器 %s 的元数据缺少 token_endpoint", issuer))
+			continue
+		}
+		s.metadata = &metadata
+		return s.metadata, nil
+	}
+	return nil, fmt.Errorf("获取授权服务器元数据失败: %w", errors.Join(errs...))
+}
+
+// protectedResourceMetadata fetches the RFC 9728 metadata of the server, from the URL
+// of its last challenge or from the well-known location derived from its URL.
+func (s *oauthSession) protectedResourceMetadata(ctx context.Context) (*protectedResourceMetadata, error) {
+	candidates := []string{s.resourceMetadataURL}
+	if s.resourceMetadataURL == "" {
+		u, err := url.Parse(s.resource)
+		if err != nil {
+			return nil, fmt.Errorf("解析服务器地址失败: %w", err)
+		}
+		origin := u.Scheme + "://" + u.Host
+		candidates = []string{origin + "/.well-known/oauth-protected-resource"}
+		if path := strings.TrimSuffix(u.Path, "/"); path != "" {
+			candidates = append([]string{origin + "/.well-known/oauth-protected-resource" + path}, candidates...)
+		}
+	}
+
+	var errs []error
+	for _, candidate := range candidates {
+		var metadata protectedResourceMetadata
+		if err := s.getJSON(ctx, candidate, &metadata); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		return &metadata, nil
+	}
+	return nil, fmt.Errorf("获取受保护资源元数据失败: %w", errors.Join(errs...))
+}
+
+// clientCredentials requests a token with the client credentials grant.
+func (s *oauthSession) clientCredentials(ctx context.Context, metadata *authServerMetadata) (*OAuthToken, error) {
+	form := url.Values{"grant_type": {OAuthClientCredentials}}
+	if len(s.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+	return s.requestToken(ctx, metadata, form)
+}
+
+// authorizationCode runs the authorization code grant with PKCE: the user authorizes
+// the hub through the OAuthAuthorizer and the returned code is exchanged for a token.
+func (s *oauthSession) authorizationCode(ctx context.Context, metadata *authServerMetadata) (*OAuthToken, error) {
+	if s.authorizer == nil {
+		return nil, fmt.Errorf("服务器 %s 需要用户授权，但没有设置OAuthAuthorizer", s.serverName)
+	}
+	if metadata.AuthorizationEndpoint == "" {
+		return nil, fmt.Errorf("授权服务器元数据缺少 authorization_endpoint")
+	}
+	if len(metadata.CodeChallengeMethodsSupported) > 0 && !slices.Contains(metadata.CodeChallengeMethodsSupported, "S256") {
+		return nil, fmt.Errorf("授权服务器不支持S256 PKCE")
+	}
+
+	verifier, state := randomString(), randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("解析授权地址失败: %w", err)
+	}
+	query := authURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", s.config.ClientID)
+	query.Set("redirect_uri", s.config.RedirectURI)
+	query.Set("state", state)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	query.Set("resource", s.resource)
+	if len(s.config.Scopes) > 0 {
+		query.Set("scope", strings.Join(s.config.Scopes, " "))
+	}
+	authURL.RawQuery = query.Encode()
+
+	redirect, err := s.authorizer(ctx, s.serverName, authURL.String())
+	if err != nil {
+		return nil, fmt.Errorf("用户授权失败: %w", err)
+	}
+	redirectURL, err := url.Parse(redirect)
+	if err != nil {
+		return nil, fmt.Errorf("解析重定向地址失败: %w", err)
+	}
+	params := redirectURL.Query()
+	if e := params.Get("error"); e != "" {
+		return nil, fmt.Errorf("用户授权失败: %s %s", e, params.Get("error_description"))
+	}
+	if params.Get("state") != state {
+		return nil, fmt.Errorf("授权响应的state不匹配")
+	}
+	if params.Get("code") == "" {
+		return nil, fmt.Errorf("授权响应缺少code")
+	}
+
+	return s.requestToken(ctx, metadata, url.Values{
+		"grant_type":    {OAuthAuthorizationCode},
+		"code":          {params.Get("code")},
+		"redirect_uri":  {s.config.RedirectURI},
+		"code_verifier": {verifier},
+	})
+}
+
+// refresh exchanges a refresh token for a new access token. Authorization servers
+// that do not rotate refresh tokens omit it from the response, so the old one is kept.
+func (s *oauthSession) refresh(ctx context.Context, metadata *authServerMetadata, refreshToken string) (*OAuthToken, error) {
+	token, err := s.requestToken(ctx, metadata, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = refreshToken
+	}
+	return token, nil
+}
+
+// requestToken posts a token request with the client credentials and the resource
+// indicator added to form.
+func (s *oauthSession) requestToken(ctx context.Context, metadata *authServerMetadata, form url.Values) (*OAuthToken, error) {
+	form.Set("resource", s.resource)
+	// 机密客户端默认用HTTP Basic认证，授权服务器只支持 client_secret_post 时放在表单中
+	basic := s.config.ClientSecret != "" &&
+		(len(metadata.TokenEndpointAuthMethods) == 0 || slices.Contains(metadata.TokenEndpointAuthMethods, "client_secret_basic"))
+	if !basic {
+		form.Set("client_id", s.config.ClientID)
+		if s.config.ClientSecret != "" {
+			form.Set("client_secret", s.config.ClientSecret)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("创建令牌请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basic {
+		req.SetBasicAuth(url.QueryEscape(s.config.ClientID), url.QueryEscape(s.config.ClientSecret))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("请求令牌失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body tokenResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return nil, fmt.Errorf("解析令牌响应失败 (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return nil, fmt.Errorf("授权服务器拒绝令牌请求 (HTTP %d): %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.AccessToken == "" {
+		return nil, fmt.Errorf("令牌响应缺少 access_token")
+	}
+
+	token := &OAuthToken{
+		AccessToken:  body.AccessToken,
+		TokenType:    body.TokenType,
+		RefreshToken: body.RefreshToken,
+		Scope:        body.Scope,
+	}
+	if body.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// getJSON fetches a metadata document.
+func (s *oauthSession) getJSON(ctx context.Context, target string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP %d", target, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", target, err)
+	}
+	return nil
+}
+
+// randomString returns 32 random bytes encoded as base64url, suitable as PKCE code
+// verifier and state.
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// oauthTransport adds the session's access token to every request. When the server
+// answers 401 the token is refreshed and the request is retried once.
+type oauthTransport struct {
+	session *oauthSession
+	base    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *oauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	token, err := t.session.accessToken(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("获取OAuth令牌失败: %w", err)
+	}
+
+	resp, err := t.base.RoundTrip(withBearer(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	// 请求体不能重放时无法重试
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return resp, nil
+	}
+
+	t.session.challenged(resp.Header.Get("WWW-Authenticate"))
+	fresh, err := t.session.accessToken(ctx, token)
+	if err != nil {
+		logf("服务器 %s 拒绝了令牌，重新获取失败: %v", t.session.serverName, err)
+		return resp, nil
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	retry := req.Clone(ctx)
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	return t.base.RoundTrip(withBearer(retry, fresh))
+}
+
+// withBearer returns a copy of req with its Authorization header set to the token.
+func withBearer(req *http.Request, token string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	return r
+}
@@ -0,0 +1,295 @@
+package einomcphost
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testAuthServer 是测试用的授权服务器，同时保护一个streamable HTTP的MCP服务器
+type testAuthServer struct {
+	t        *testing.T
+	issuer   *httptest.Server
+	resource *httptest.Server
+
+	mu        sync.Mutex
+	valid     map[string]bool   // 有效的访问令牌
+	refresh   map[string]bool   // 有效的刷新令牌
+	codes     map[string]string // 授权码对应的code_challenge
+	grants    []string          // 令牌端点收到的grant_type
+	resources []string          // 令牌请求中的resource参数
+	issued    int
+	expiresIn int
+}
+
+func newTestAuthServer(t *testing.T) *testAuthServer {
+	s := &testAuthServer{
+		t:       t,
+		valid:   make(map[string]bool),
+		refresh: make(map[string]bool),
+		codes:   make(map[string]string),
+	}
+
+	as := http.NewServeMux()
+	as.HandleFunc("/.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                           s.issuer.URL,
+			"authorization_endpoint":           s.issuer.URL + "/authorize",
+			"token_endpoint":                   s.issuer.URL + "/token",
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	})
+	as.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		s.mu.Lock()
+		code := fmt.Sprintf("code-%d", len(s.codes))
+		s.codes[code] = q.Get("code_challenge")
+		s.mu.Unlock()
+		redirect, _ := url.Parse(q.Get("redirect_uri"))
+		redirect.RawQuery = url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	})
+	as.HandleFunc("/token", s.token)
+	s.issuer = httptest.NewServer(as)
+	t.Cleanup(s.issuer.Close)
+
+	mcp := server.NewStreamableHTTPServer(newMultiToolServer("read"))
+	rs := http.NewServeMux()
+	rs.HandleFunc("/.well-known/oauth-protected-resource/mcp", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"resource":              s.resource.URL + "/mcp",
+			"authorization_servers": []string{s.issuer.URL},
+		})
+	})
+	rs.HandleFunc("/mcp", func(w http.ResponseWriter, r *http.Request) {
+		token, _ := bearerToken(r)
+		s.mu.Lock()
+		ok := s.valid[token]
+		s.mu.Unlock()
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer resource_metadata="`+s.resource.URL+`/.well-known/oauth-protected-resource/mcp"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mcp.ServeHTTP(w, r)
+	})
+	s.resource = httptest.NewServer(rs)
+	t.Cleanup(s.resource.Close)
+	return s
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	auth := r.Header.Get("Authorization")
+	if len(auth) < 7 || auth[:7] != "Bearer " {
+		return "", false
+	}
+	return auth[7:], true
+}
+
+func (s *testAuthServer) token(w http.ResponseWriter, r *http.Request) {
+	require.NoError(s.t, r.ParseForm())
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	grant := r.PostForm.Get("grant_type")
+	s.grants = append(s.grants, grant)
+	s.resources = append(s.resources, r.PostForm.Get("resource"))
+	deny := func() {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+	}
+
+	switch grant {
+	case OAuthClientCredentials:
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "hub" || secret != "hub-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+	case OAuthAuthorizationCode:
+		challenge, ok := s.codes[r.PostForm.Get("code")]
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if !ok || challenge != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			deny()
+			return
+		}
+		delete(s.codes, r.PostForm.Get("code"))
+	case "refresh_token":
+		if !s.refresh[r.PostForm.Get("refresh_token")] {
+			deny()
+			return
+		}
+		delete(s.refresh, r.PostForm.Get("refresh_token"))
+	default:
+		deny()
+		return
+	}
+
+	s.issued++
+	access, refresh := fmt.Sprintf("access-%d", s.issued), fmt.Sprintf("refresh-%d", s.issued)
+	s.valid[access] = true
+	s.refresh[refresh] = true
+	response := map[string]any{"access_token": access, "token_type": "Bearer", "refresh_token": refresh}
+	if s.expiresIn > 0 {
+		response["expires_in"] = s.expiresIn
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// revokeAll 使所有访问令牌失效，刷新令牌仍然有效
+func (s *testAuthServer) revokeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.valid = make(map[string]bool)
+}
+
+func (s *testAuthServer) grantTypes() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.grants...)
+}
+
+// TestOAuthClientCredentials 测试客户端凭据授权、元数据发现以及401后刷新令牌重试
+func TestOAuthClientCredentials(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("OAUTH_TEST_SECRET", "hub-secret")
+	as := newTestAuthServer(t)
+
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"remote": {
+				Transport: transportHTTP1,
+				URL:       as.resource.URL + "/mcp",
+				OAuth: &OAuthConfig{
+					GrantType:    OAuthClientCredentials,
+					ClientID:     "hub",
+					ClientSecret: "${OAUTH_TEST_SECRET}",
+				},
+			},
+		},
+	}
+	store := NewMemoryTokenStore()
+	hub, err := NewMCPHubFromSettings(ctx, settings, WithTokenStore(store), WithStrictStartup())
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	assert.Equal(t, []string{"remote_read"}, hubToolNames(t, hub))
+	assert.Equal(t, []string{OAuthClientCredentials}, as.grantTypes())
+	assert.Equal(t, []string{as.resource.URL + "/mcp"}, as.resources, "令牌请求带上resource参数")
+	assert.Equal(t, "${OAUTH_TEST_SECRET}", settings.MCPServers["remote"].OAuth.ClientSecret, "配置保留变量引用")
+
+	// 令牌被服务器拒绝后刷新并重试，调用方看不到401
+	as.revokeAll()
+	result, err := hub.InvokeTool(ctx, "remote_read", nil)
+	require.NoError(t, err)
+	assert.Contains(t, result, "read")
+	assert.Equal(t, []string{OAuthClientCredentials, "refresh_token"}, as.grantTypes())
+
+	token, err := store.LoadToken(ctx, "remote")
+	require.NoError(t, err)
+	assert.Equal(t, "access-2", token.AccessToken)
+	assert.Equal(t, "refresh-2", token.RefreshToken)
+}
+
+// TestOAuthAuthorizationCode 测试带PKCE的授权码流程，以及新的hub复用令牌存储中的令牌
+func TestOAuthAuthorizationCode(t *testing.T) {
+	ctx := context.Background()
+	as := newTestAuthServer(t)
+	as.expiresIn = 1 // 比 oauthExpirySkew 短，每次使用前都刷新
+
+	var authorizations int
+	authorizer := func(ctx context.Context, serverName, authorizationURL string) (string, error) {
+		authorizations++
+		assert.Equal(t, "remote", serverName)
+		u, err := url.Parse(authorizationURL)
+		require.NoError(t, err)
+		assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+		assert.Equal(t, "files:read", u.Query().Get("scope"))
+
+		// 模拟浏览器：跟随授权地址，记录重定向到redirectUri的地址
+		noRedirect := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+		resp, err := noRedirect.Get(authorizationURL)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		return resp.Header.Get("Location"), nil
+	}
+
+	settings := func() *MCPSettings {
+		return &MCPSettings{
+			MCPServers: map[string]*ServerConfig{
+				"remote": {
+					Transport: transportHTTP1,
+					URL:       as.resource.URL + "/mcp",
+					OAuth: &OAuthConfig{
+						ClientID:    "hub",
+						RedirectURI: "http://127.0.0.1:8765/callback",
+						Scopes:      []string{"files:read"},
+					},
+				},
+			},
+		}
+	}
+
+	store := NewMemoryTokenStore()
+	hub, err := NewMCPHubFromSettings(ctx, settings(), WithTokenStore(store), WithOAuthAuthorizer(authorizer), WithStrictStartup())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"remote_read"}, hubToolNames(t, hub))
+	assert.Equal(t, 1, authorizations)
+	require.NoError(t, hub.CloseServers())
+
+	// 已保存的令牌过期后用刷新令牌续期，不再要求用户授权
+	hub, err = NewMCPHubFromSettings(ctx, settings(), WithTokenStore(store), WithOAuthAuthorizer(authorizer), WithStrictStartup())
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+	_, err = hub.InvokeTool(ctx, "remote_read", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, authorizations)
+	assert.Equal(t, OAuthAuthorizationCode, as.grantTypes()[0])
+	assert.Contains(t, as.grantTypes()[1:], "refresh_token")
+	assert.NotContains(t, as.grantTypes()[1:], OAuthAuthorizationCode)
+
+	// 没有OAuthAuthorizer又没有令牌时连接失败
+	_, err = NewMCPHubFromSettings(ctx, settings(), WithStrictStartup())
+	assert.ErrorContains(t, err, "OAuthAuthorizer")
+}
+
+// TestOAuthConfigValidation 测试oauth配置的校验
+func TestOAuthConfigValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		errMsg string
+	}{
+		{"stdio", `{"command": "server", "oauth": {"clientId": "hub", "redirectUri": "http://localhost/cb"}}`, "oauth requires"},
+		{"bearer token", `{"url": "https://example.org/mcp", "bearerToken": "t", "oauth": {"clientId": "hub", "redirectUri": "http://localhost/cb"}}`, "cannot be combined"},
+		{"client id", `{"url": "https://example.org/mcp", "oauth": {"redirectUri": "http://localhost/cb"}}`, "clientId is required"},
+		{"redirect uri", `{"url": "https://example.org/mcp", "oauth": {"clientId": "hub"}}`, "redirectUri is required"},
+		{"client secret", `{"url": "https://example.org/mcp", "oauth": {"grantType": "client_credentials", "clientId": "hub"}}`, "clientSecret is required"},
+		{"grant type", `{"url": "https://example.org/mcp", "oauth": {"grantType": "password", "clientId": "hub"}}`, "unsupported oauth grantType"},
+		{"valid", `{"url": "https://example.org/mcp", "oauth": {"grantType": "client_credentials", "clientId": "hub", "clientSecret": "s"}}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadSettingsFromString(`{"mcpServers": {"server": ` + tt.config + `}}`)
+			if tt.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.errMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"slices"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// exposedName returns the tool name before the naming strategy is applied.
+func (o ToolOverride) exposedName(toolName string) string {
+	if o.Name != "" {
+		return o.Name
+	}
+	return toolName
+}
+
+// description returns the tool description after applying the override.
+func (o ToolOverride) description(original string) string {
+	desc := original
+	if o.Description != "" {
+		desc = o.Description
+	}
+	if o.AppendDescription != "" {
+		if desc == "" {
+			return o.AppendDescription
+		}
+		desc += "\n" + o.AppendDescription
+	}
+	return desc
+}
+
+// hides reports whether the parameter is removed from the schema shown to the model.
+func (o ToolOverride) hides(param string) bool {
+	if _, fixed := o.FixedArgs[param]; fixed {
+		return true
+	}
+	return slices.Contains(o.HiddenParams, param)
+}
+
+// applySchema removes hidden and fixed parameters from the input schema.
+func (o ToolOverride) applySchema(inputSchema *openapi3.Schema) {
+	if inputSchema == nil || (len(o.HiddenParams) == 0 && len(o.FixedArgs) == 0) {
+		return
+	}
+
+	for param := range inputSchema.Properties {
+		if o.hides(param) {
+			delete(inputSchema.Properties, param)
+		}
+	}
+	inputSchema.Required = slices.DeleteFunc(slices.Clone(inputSchema.Required), o.hides)
+}
+
+// applyArgs drops hidden parameters sent by the model and injects fixed arguments.
+// The caller's map is not modified.
+func (o ToolOverride) applyArgs(params map[string]any) map[string]any {
+	if len(o.HiddenParams) == 0 && len(o.FixedArgs) == 0 {
+		return params
+	}
+
+	args := make(map[string]any, len(params)+len(o.FixedArgs))
+	for name, value := range params {
+		if !o.hides(name) {
+			args[name] = value
+		}
+	}
+	for name, value := range o.FixedArgs {
+		args[name] = value
+	}
+	return args
+}
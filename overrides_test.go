@@ -0,0 +1,102 @@
+package einomcphost
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestHTTPServer 通过streamable HTTP提供MCP服务器，返回/mcp地址
+func newTestHTTPServer(t *testing.T, s *server.MCPServer) string {
+	t.Helper()
+
+	httpServer := httptest.NewServer(server.NewStreamableHTTPServer(s))
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL + "/mcp"
+}
+
+// newSearchServer 创建一个search工具，返回收到的参数
+func newSearchServer() *server.MCPServer {
+	s := server.NewMCPServer("override-test-server", "1.0.0")
+	s.AddTool(
+		mcp.NewTool("search",
+			mcp.WithDescription("search things"),
+			mcp.WithString("query", mcp.Required()),
+			mcp.WithString("region", mcp.Required()),
+			mcp.WithBoolean("debug"),
+		),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			args, _ := json.Marshal(request.GetArguments())
+			return mcp.NewToolResultText(string(args)), nil
+		},
+	)
+	return s
+}
+
+// TestToolOverrides 测试工具重命名、描述覆盖、隐藏参数和固定参数
+func TestToolOverrides(t *testing.T) {
+	ctx := context.Background()
+
+	config := `{
+		"mcpServers": {
+			"web": {
+				"url": "` + newTestHTTPServer(t, newSearchServer()) + `",
+				"toolOverrides": {
+					"search": {
+						"name": "find_pages",
+						"description": "Search the public web.",
+						"appendDescription": "Use English keywords.",
+						"hiddenParams": ["debug"],
+						"fixedArgs": {"region": "us"}
+					}
+				}
+			}
+		}
+	}`
+	hub, err := NewMCPHubFromString(ctx, config)
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	toolsMap, err := hub.GetToolsMap(ctx)
+	require.NoError(t, err)
+	require.Contains(t, toolsMap, "web_find_pages")
+	info := toolsMap["web_find_pages"]
+	assert.Equal(t, "Search the public web.\nUse English keywords.", info.Desc)
+	assert.Equal(t, "search", info.Extra[ToolInfoExtraToolName])
+
+	openAPI, err := info.ToOpenAPIV3()
+	require.NoError(t, err)
+	assert.Contains(t, openAPI.Properties, "query")
+	assert.NotContains(t, openAPI.Properties, "region")
+	assert.NotContains(t, openAPI.Properties, "debug")
+	assert.Equal(t, []string{"query"}, openAPI.Required)
+
+	// 固定参数覆盖模型传入的值，隐藏参数被丢弃
+	result, err := hub.InvokeTool(ctx, "web_find_pages", map[string]any{"query": "go", "region": "cn", "debug": true})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"query":"go","region":"us"}`, result)
+}
+
+// TestToolOverrideConflict 测试重命名冲突在校验阶段被拒绝
+func TestToolOverrideConflict(t *testing.T) {
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"web": {
+				URL: "http://localhost/mcp",
+				ToolOverrides: map[string]ToolOverride{
+					"search":  {Name: "find"},
+					"search2": {Name: "find"},
+				},
+			},
+		},
+	}
+	err := validateSettings(settings)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "rename both search and search2 to find")
+}
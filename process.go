@@ -0,0 +1,414 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	mcptransport "github.com/mark3labs/mcp-go/client/transport"
+)
+
+// ProcessLimits caps the resources of a stdio server process. Memory, CPU time and
+// open files are enforced on Linux only; on other systems a server that sets them
+// fails to start. Lifetime is enforced everywhere.
+type ProcessLimits struct {
+	MemoryMB  uint64        `json:"memoryMB,omitempty" yaml:"memoryMB,omitempty"`   // Virtual memory of the process (RLIMIT_AS) in MiB
+	CPUTime   time.Duration `json:"cpuTime,omitempty" yaml:"cpuTime,omitempty"`     // CPU time of the process (RLIMIT_CPU), rounded up to whole seconds
+	OpenFiles uint64        `json:"openFiles,omitempty" yaml:"openFiles,omitempty"` // Open file descriptors (RLIMIT_NOFILE)
+	Lifetime  time.Duration `json:"lifetime,omitempty" yaml:"lifetime,omitempty"`   // Wall-clock time after which the process and its children are killed
+}
+
+// processLimitsAlias has the fields of ProcessLimits without its JSON methods.
+type processLimitsAlias ProcessLimits
+
+// UnmarshalJSON decodes process limits. cpuTime and lifetime accept a number of
+// seconds or a Go duration string such as "10m".
+func (l *ProcessLimits) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*processLimitsAlias
+		CPUTime  json.RawMessage `json:"cpuTime,omitempty"`
+		Lifetime json.RawMessage `json:"lifetime,omitempty"`
+	}{processLimitsAlias: (*processLimitsAlias)(l)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.CPUTime) > 0 {
+		cpuTime, err := parseJSONDuration(aux.CPUTime)
+		if err != nil {
+			return fmt.Errorf("cpuTime: %w", err)
+		}
+		l.CPUTime = cpuTime
+	}
+	if len(aux.Lifetime) > 0 {
+		lifetime, err := parseJSONDuration(aux.Lifetime)
+		if err != nil {
+			return fmt.Errorf("lifetime: %w", err)
+		}
+		l.Lifetime = lifetime
+	}
+	return nil
+}
+
+// MarshalJSON encodes process limits, writing durations as duration strings.
+func (l ProcessLimits) MarshalJSON() ([]byte, error) {
+	aux := struct {
+		processLimitsAlias
+		CPUTime  string `json:"cpuTime,omitempty"`
+		Lifetime string `json:"lifetime,omitempty"`
+	}{processLimitsAlias: processLimitsAlias(l)}
+
+	if l.CPUTime != 0 {
+		aux.CPUTime = l.CPUTime.String()
+	}
+	if l.Lifetime != 0 {
+		aux.Lifetime = l.Lifetime.String()
+	}
+	return json.Marshal(aux)
+}
+
+// hasRlimits reports whether any limit enforced through setrlimit is set.
+func (l *ProcessLimits) hasRlimits() bool {
+	return l != nil && (l.MemoryMB > 0 || l.CPUTime > 0 || l.OpenFiles > 0)
+}
+
+// clone returns a copy of l, or nil if l is nil.
+func (l *ProcessLimits) clone() *ProcessLimits {
+	if l == nil {
+		return nil
+	}
+	c := *l
+	return &c
+}
+
+// ProcessExit describes how the process of a stdio server ended. It is recorded in
+// the server's status and used as the error of the outage.
+type ProcessExit struct {
+	PID      int       // Process ID
+	ExitCode int       // Exit code, -1 if the process was killed by a signal or the code is unknown
+	Signal   string    // Signal that killed the process, empty if it exited
+	Reason   string    // Why the process ended, such as "exceeded lifetime of 1h0m0s"
+	Time     time.Time // When the exit was observed
+	Stderr   []string  // Last lines the process wrote to stderr, oldest first
+}
+
+// Error implements the error interface.
+func (e *ProcessExit) Error() string {
+	message := fmt.Sprintf("MCP服务器进程 %d 已退出: %s", e.PID, e.Reason)
+	if len(e.Stderr) > 0 {
+		message += "\n" + formatStderr(e.Stderr)
+	}
+	return message
+}
+
+// Unwrap lets errors.Is match the generic process exit error.
+func (e *ProcessExit) Unwrap() error {
+	return errServerProcessExited
+}
+
+// processState is how a process ended, as read from its wait status.
+type processState struct {
+	observed bool          // false if the exit could not be observed
+	exitCode int           // Exit code, -1 if the process was killed by a signal
+	signal   string        // Name of the signal that killed the process, empty if it exited
+	cpuTime  time.Duration // CPU time used by the process
+}
+
+// validateProcessConfig checks the process controls of a server.
+func validateProcessConfig(name string, server *ServerConfig) error {
+	if server.Cwd == "" && server.InheritEnv == nil && len(server.EnvAllowlist) == 0 && server.Limits == nil {
+		return nil
+	}
+	if server.Transport != transportStdio {
+		return fmt.Errorf(errMsgProcessNotSupported, name)
+	}
+	if server.InheritEnv != nil && !*server.InheritEnv && len(server.EnvAllowlist) > 0 {
+		return fmt.Errorf(errMsgEnvAllowlist, name)
+	}
+	for _, pattern := range server.EnvAllowlist {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf(errMsgInvalidEnvPattern, name, pattern, err)
+		}
+	}
+	if limits := server.Limits; limits != nil && (limits.CPUTime < 0 || limits.Lifetime < 0) {
+		return fmt.Errorf(errMsgProcessLimitInvalid, name)
+	}
+	return nil
+}
+
+// inheritedEnvironment returns the variables of the hub's environment passed to a
+// stdio server: all of them by default, those matching EnvAllowlist if it is set and
+// none if InheritEnv is false.
+func inheritedEnvironment(config *ServerConfig) []string {
+	if config.InheritEnv != nil && !*config.InheritEnv {
+		return nil
+	}
+	environ := os.Environ()
+	if len(config.EnvAllowlist) == 0 {
+		return environ
+	}
+
+	var env []string
+	for _, entry := range environ {
+		name, _, _ := strings.Cut(entry, "=")
+		for _, pattern := range config.EnvAllowlist {
+			if ok, _ := path.Match(pattern, name); ok {
+				env = append(env, entry)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// stdioProcess spawns the process of a stdio server and watches it: it applies the
+// resource limits once the process has started, kills it when its lifetime is over
+// and records how it ended.
+type stdioProcess struct {
+	serverName string
+	config     *ServerConfig     // Resolved config of the server
+	supervisor *serverSupervisor // Keeps the process info and stderr of the server across restarts
+	transport  *stdioTransport   // Transport running the process
+	client     *client.Client    // Client talking to the process
+
+	stderrLines atomic.Int64  // Number of stderr lines written by this process
+	stderrDone  chan struct{} // Closed once stderr has been read to the end
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	timer   *time.Timer
+	expired atomic.Bool   // Set when the process was killed for exceeding its lifetime
+	exited  chan struct{} // Closed once exit is set
+	exit    *ProcessExit
+
+	resultOnce  sync.Once
+	result      *ProcessExit // exit with the last stderr lines
+	restartOnce sync.Once
+	restartErr  error // Why the restart policy keeps the server down, nil if it restarts
+}
+
+// newStdioProcess returns the process controller of a resolved stdio server config.
+func newStdioProcess(serverName string, config *ServerConfig, supervisor *serverSupervisor) *stdioProcess {
+	return &stdioProcess{
+		serverName: serverName,
+		config:     config,
+		supervisor: supervisor,
+		stderrDone: make(chan struct{}),
+		exited:     make(chan struct{}),
+	}
+}
+
+// newClient starts the process of the server and returns a client talking to it.
+// env holds the server's own variables.
+func (p *stdioProcess) newClient(command string, env []string, args []string) (*client.Client, error) {
+	stdio := mcptransport.NewStdioWithOptions(command, env, args, mcptransport.WithCommandFunc(p.command))
+	if err := stdio.Start(context.Background()); err != nil {
+		return nil, fmt.Errorf("启动stdio传输失败: %w", err)
+	}
+	p.transport = &stdioTransport{Stdio: stdio, process: p}
+	p.client = client.NewClient(p.transport)
+	return p.client, nil
+}
+
+// command builds the command of the server. It is the CommandFunc of the stdio
+// transport, so env holds the server's own variables only.
+func (p *stdioProcess) command(ctx context.Context, command string, env []string, args []string) (*exec.Cmd, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = append(cmd.Env, inheritedEnvironment(p.config)...)
+	cmd.Env = append(cmd.Env, env...)
+	if cmd.Env == nil {
+		// nil表示继承hub的全部环境变量
+		cmd.Env = []string{}
+	}
+	cmd.Dir = p.config.Cwd
+	cmd.SysProcAttr = processSysProcAttr(p.config.Limits)
+
+	p.mu.Lock()
+	p.cmd = cmd
+	p.mu.Unlock()
+	return cmd, nil
+}
+
+// started applies the limits to the running process and starts watching its
+// lifetime. If the limits cannot be applied the process is killed.
+func (p *stdioProcess) started() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd == nil || p.cmd.Process == nil {
+		return fmt.Errorf("服务器 %s 的进程没有启动", p.serverName)
+	}
+	process := p.cmd.Process
+	limits := p.config.Limits
+
+	if limits.hasRlimits() {
+		if err := applyProcessLimits(process.Pid, limits); err != nil {
+			killProcess(process)
+			return fmt.Errorf("设置资源限制失败: %w", err)
+		}
+	}
+	if limits != nil && limits.Lifetime > 0 {
+		p.timer = time.AfterFunc(limits.Lifetime, func() {
+			logf("服务器 %s 的进程超过生存时间 %s，结束进程", p.serverName, limits.Lifetime)
+			p.expired.Store(true)
+			killProcess(process)
+		})
+	}
+	p.supervisor.processStarted(process.Pid, p)
+	return nil
+}
+
+// recordExit records how the process ended and why, once the transport has reaped
+// it. It is called once, by the transport's Close.
+func (p *stdioProcess) recordExit() {
+	p.mu.Lock()
+	cmd := p.cmd
+	p.mu.Unlock()
+	if cmd == nil || cmd.Process == nil || cmd.ProcessState == nil {
+		return
+	}
+	pid := cmd.Process.Pid
+	state := processStateOf(cmd.ProcessState)
+	exit := &ProcessExit{PID: pid, ExitCode: -1, Signal: state.signal, Time: time.Now()}
+
+	limits := p.config.Limits
+	switch {
+	case p.expired.Load():
+		exit.Reason = fmt.Sprintf("exceeded lifetime of %s", limits.Lifetime)
+	case !state.observed:
+		exit.Reason = "exited"
+	case state.signal != "" && limits != nil && limits.CPUTime > 0 && state.cpuTime >= limits.CPUTime:
+		exit.Reason = fmt.Sprintf("exceeded CPU time limit of %s (killed by signal %s)", limits.CPUTime, state.signal)
+	case state.signal != "":
+		exit.Reason = "killed by signal " + state.signal
+	default:
+		exit.ExitCode = state.exitCode
+		exit.Reason = fmt.Sprintf("exited with code %d", state.exitCode)
+	}
+	if limits != nil && limits.MemoryMB > 0 && state.signal != "" && !p.expired.Load() {
+		// 超出内存限制时分配失败，进程通常因信号崩溃，无法确定就是这个原因
+		exit.Reason += fmt.Sprintf(" (memory limit %d MiB)", limits.MemoryMB)
+	}
+
+	p.mu.Lock()
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.exit = exit
+	p.mu.Unlock()
+	p.supervisor.processExited(pid)
+	close(p.exited)
+}
+
+// stdioTransport is the stdio transport of a server process. It is started when it
+// is created, and closing it waits for the process, so that wait is the only one
+// and its status is recorded as how the process ended.
+type stdioTransport struct {
+	*mcptransport.Stdio
+	process *stdioProcess
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Start does nothing since the transport is already running.
+func (t *stdioTransport) Start(ctx context.Context) error {
+	return nil
+}
+
+// Close closes the transport, which reaps the process, and records the exit.
+// Concurrent calls wait until the process has been reaped.
+func (t *stdioTransport) Close() error {
+	t.closeOnce.Do(func() {
+		t.closeErr = t.Stdio.Close()
+		t.process.recordExit()
+	})
+	return t.closeErr
+}
+
+// waitExit returns how the process ended, waiting at most timeout for the transport
+// to reap it. It returns nil if the process was not started or has not been reaped
+// in time.
+func (p *stdioProcess) waitExit(timeout time.Duration) *ProcessExit {
+	if p == nil {
+		return nil
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-p.exited:
+		return p.exit
+	case <-timer.C:
+		return nil
+	}
+}
+
+// stderrTail returns the last stderr lines of this process for an error message,
+// waiting at most timeout for stderr to be read to the end.
+func (p *stdioProcess) stderrTail(timeout time.Duration) []string {
+	if p == nil {
+		return nil
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-p.stderrDone:
+	case <-timer.C:
+	}
+	// 缓冲区在重启之间保留，只取本进程写入的行
+	n := int(min(p.stderrLines.Load(), stderrErrorLines))
+	return p.supervisor.tail(n)
+}
+
+// exitResult returns how the process ended together with its last stderr lines,
+// waiting at most a second for the exit. It returns nil if the process is still
+// running or the exit was not observed.
+func (p *stdioProcess) exitResult() *ProcessExit {
+	exit := p.waitExit(time.Second)
+	if exit == nil {
+		return nil
+	}
+	p.resultOnce.Do(func() {
+		result := *exit
+		result.Stderr = p.stderrTail(time.Second)
+		p.result = &result
+	})
+	return p.result
+}
+
+// withStderr adds the last stderr lines of the process to err. It is used once the
+// client is closed, so stderr is complete.
+func (p *stdioProcess) withStderr(err error) error {
+	lines := p.stderrTail(time.Second)
+	if len(lines) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w\n%s", err, formatStderr(lines))
+}
+
+// recordProcessExit stores the exit of a server process in the server's status if
+// mcpClient is still its live client. Exits of clients the hub closed itself are
+// not recorded.
+func (h *MCPHub) recordProcessExit(mcpClient *client.Client, serverName string, exit *ProcessExit) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	conn, ok := h.connections[serverName]
+	if !ok || h.closed || conn.Client != client.MCPClient(mcpClient) {
+		return
+	}
+	logf("服务器 %s 的进程已退出: %s", serverName, exit.Reason)
+	status := ServerStatus{MCPTools: MCPTools{Name: serverName, Err: exit}, State: ServerStateFailed, LastExit: exit}
+	if previous, ok := h.status[serverName]; ok {
+		status.ListedTools = previous.ListedTools
+	}
+	h.setServerStatus(status)
+}
@@ -0,0 +1,69 @@
+//go:build linux
+
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// applyProcessLimits sets the resource limits of a started stdio server process
+// with prlimit. Processes the server starts afterwards inherit them.
+func applyProcessLimits(pid int, limits *ProcessLimits) error {
+	set := func(resource int, soft, hard uint64) error {
+		if soft == 0 {
+			return nil
+		}
+		return unix.Prlimit(pid, resource, &unix.Rlimit{Cur: soft, Max: hard}, nil)
+	}
+
+	// 超过软限制时内核发送SIGXCPU，忽略该信号的进程在一秒后被SIGKILL结束
+	cpuSeconds := uint64((limits.CPUTime + time.Second - 1) / time.Second)
+	return errors.Join(
+		set(unix.RLIMIT_AS, limits.MemoryMB<<20, limits.MemoryMB<<20),
+		set(unix.RLIMIT_CPU, cpuSeconds, cpuSeconds+1),
+		set(unix.RLIMIT_NOFILE, limits.OpenFiles, limits.OpenFiles),
+	)
+}
+
+// processSysProcAttr starts stdio servers in their own process group, so that
+// killing a server also kills the processes it started, such as node under npx.
+func processSysProcAttr(limits *ProcessLimits) *syscall.SysProcAttr {
+	if limits == nil || limits.Lifetime == 0 {
+		return nil
+	}
+	return &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcess kills the process and, if it leads one, its process group.
+func killProcess(process *os.Process) {
+	if pgid, err := unix.Getpgid(process.Pid); err == nil && pgid == process.Pid {
+		unix.Kill(-pgid, unix.SIGKILL)
+		return
+	}
+	process.Kill()
+}
+
+// processStateOf returns how a reaped process ended.
+func processStateOf(state *os.ProcessState) processState {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok {
+		return processState{}
+	}
+	result := processState{observed: true, cpuTime: state.UserTime() + state.SystemTime()}
+	switch {
+	case status.Exited():
+		result.exitCode = status.ExitStatus()
+	case status.Signaled():
+		result.exitCode = -1
+		result.signal = unix.SignalName(status.Signal())
+	default:
+		return processState{}
+	}
+	return result
+}
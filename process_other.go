@@ -0,0 +1,34 @@
+//go:build !linux
+
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// processSysProcAttr returns no process attributes outside Linux.
+func processSysProcAttr(limits *ProcessLimits) *syscall.SysProcAttr {
+	return nil
+}
+
+// applyProcessLimits fails because resource limits are only enforced on Linux.
+func applyProcessLimits(pid int, limits *ProcessLimits) error {
+	return fmt.Errorf("内存、CPU时间和打开文件数限制只在Linux上生效")
+}
+
+// killProcess kills the process.
+func killProcess(process *os.Process) {
+	process.Kill()
+}
+
+// processStateOf returns how a reaped process ended. Only the exit code is known
+// outside Linux.
+func processStateOf(state *os.ProcessState) processState {
+	if state.ExitCode() < 0 {
+		return processState{}
+	}
+	return processState{observed: true, exitCode: state.ExitCode()}
+}
@@ -0,0 +1,199 @@
+package einomcphost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// processToolsEnv 开启测试服务器中getenv、getwd和spin工具的环境变量
+const processToolsEnv = "MCP_TEST_PROCESS_TOOLS"
+
+// invokeText 调用工具并返回去掉引号的文本结果
+func invokeText(t *testing.T, hub *MCPHub, toolName string, arguments map[string]any) string {
+	t.Helper()
+	result, err := hub.InvokeTool(context.Background(), toolName, arguments)
+	require.NoError(t, err)
+	return strings.Trim(result, `"`)
+}
+
+// TestStdioProcessEnvironment 测试stdio服务器的工作目录和环境变量继承
+func TestStdioProcessEnvironment(t *testing.T) {
+	serverPath := buildTestServer(t)
+	t.Setenv("HUB_VISIBLE", "visible")
+	t.Setenv("HUB_HIDDEN", "hidden")
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	inherit := false
+
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"allowed": {
+				Transport:    transportStdio,
+				Command:      serverPath,
+				Env:          map[string]string{"HUB_OWN": "own", processToolsEnv: "1"},
+				Cwd:          dir,
+				EnvAllowlist: []string{"HUB_VIS*", "PATH"},
+			},
+			"isolated": {
+				Transport:  transportStdio,
+				Command:    serverPath,
+				Env:        map[string]string{"HUB_OWN": "own", processToolsEnv: "1"},
+				InheritEnv: &inherit,
+			},
+			"inherited": {
+				Transport: transportStdio,
+				Command:   serverPath,
+				Env:       map[string]string{processToolsEnv: "1"},
+			},
+		},
+	}, WithStrictStartup())
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	getenv := func(server, name string) string {
+		return invokeText(t, hub, server+"_getenv", map[string]any{"name": name})
+	}
+	assert.Equal(t, dir, invokeText(t, hub, "allowed_getwd", nil))
+	assert.Equal(t, "visible", getenv("allowed", "HUB_VISIBLE"))
+	assert.Equal(t, "<unset>", getenv("allowed", "HUB_HIDDEN"))
+	assert.Equal(t, "own", getenv("allowed", "HUB_OWN"))
+
+	assert.Equal(t, "<unset>", getenv("isolated", "HUB_VISIBLE"))
+	assert.Equal(t, "own", getenv("isolated", "HUB_OWN"))
+
+	assert.Equal(t, "visible", getenv("inherited", "HUB_VISIBLE"))
+	assert.Equal(t, "hidden", getenv("inherited", "HUB_HIDDEN"))
+}
+
+// waitProcessExitStatus 等待服务器状态中记录进程退出
+func waitProcessExitStatus(t *testing.T, hub *MCPHub, serverName string) ServerStatus {
+	t.Helper()
+	var status ServerStatus
+	require.Eventually(t, func() bool {
+		status = hub.ServerStatuses()[serverName]
+		return status.LastExit != nil && status.State == ServerStateFailed
+	}, 15*time.Second, 50*time.Millisecond)
+	return status
+}
+
+// TestStdioProcessLifetime 测试超过生存时间的进程被结束并记录原因
+func TestStdioProcessLifetime(t *testing.T) {
+	serverPath := buildTestServer(t)
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"short": {
+				Transport: transportStdio,
+				Command:   serverPath,
+				Limits:    &ProcessLimits{Lifetime: time.Second},
+			},
+		},
+	}, WithStrictStartup(), WithReconnectPolicy(ReconnectPolicy{}))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	status := waitProcessExitStatus(t, hub, "short")
+	assert.Contains(t, status.LastExit.Reason, "exceeded lifetime of 1s")
+	assert.Positive(t, status.LastExit.PID)
+	assert.True(t, errors.Is(status.Err, errServerProcessExited))
+}
+
+// TestStdioProcessCPULimit 测试超过CPU时间限制的进程被结束并记录原因
+func TestStdioProcessCPULimit(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("资源限制只在Linux上生效")
+	}
+	serverPath := buildTestServer(t)
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"busy": {
+				Transport: transportStdio,
+				Command:   serverPath,
+				Env:       map[string]string{processToolsEnv: "1"},
+				Limits:    &ProcessLimits{CPUTime: time.Second, OpenFiles: 256},
+			},
+		},
+	}, WithStrictStartup(), WithReconnectPolicy(ReconnectPolicy{}))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = hub.InvokeTool(ctx, "busy_spin", nil)
+	assert.Error(t, err)
+
+	status := waitProcessExitStatus(t, hub, "busy")
+	assert.Contains(t, status.LastExit.Reason, "exceeded CPU time limit of 1s")
+	assert.NotEmpty(t, status.LastExit.Signal)
+}
+
+// TestStdioProcessLimits 测试资源限制设置到服务器进程上，且不改变它的环境变量
+func TestStdioProcessLimits(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("资源限制只在Linux上生效")
+	}
+	serverPath := buildTestServer(t)
+	t.Setenv("HUB_VISIBLE", "visible")
+	inherit := false
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"limited": {
+				Transport:  transportStdio,
+				Command:    serverPath,
+				Env:        map[string]string{processToolsEnv: "1"},
+				InheritEnv: &inherit,
+				Limits:     &ProcessLimits{CPUTime: time.Minute, OpenFiles: 128},
+			},
+		},
+	}, WithStrictStartup())
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	limit := func(name string) string {
+		return invokeText(t, hub, "limited_limits", map[string]any{"name": name})
+	}
+	assert.Equal(t, "Max open files 128 128 files", limit("Max open files"))
+	assert.Equal(t, "Max cpu time 60 61 seconds", limit("Max cpu time"))
+
+	getenv := func(name string) string {
+		return invokeText(t, hub, "limited_getenv", map[string]any{"name": name})
+	}
+	assert.Equal(t, "<unset>", getenv("HUB_VISIBLE"))
+	assert.Equal(t, "1", getenv(processToolsEnv))
+}
+
+// TestProcessConfigValidation 测试进程控制配置的解析和校验
+func TestProcessConfigValidation(t *testing.T) {
+	settings, err := LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "cwd": "/tmp",
+		"envAllowlist": ["PATH", "LC_*"], "limits": {"memoryMB": 512, "cpuTime": "30s", "openFiles": 64, "lifetime": 3600}}}}`)
+	require.NoError(t, err)
+	config := settings.MCPServers["local"]
+	assert.Equal(t, "/tmp", config.Cwd)
+	assert.Equal(t, &ProcessLimits{MemoryMB: 512, CPUTime: 30 * time.Second, OpenFiles: 64, Lifetime: time.Hour}, config.Limits)
+
+	data, err := json.Marshal(config.Limits)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"memoryMB": 512, "cpuTime": "30s", "openFiles": 64, "lifetime": "1h0m0s"}`, string(data))
+
+	_, err = LoadSettingsFromString(`{"mcpServers": {"remote": {"url": "https://example.org/mcp", "cwd": "/tmp"}}}`)
+	assert.ErrorContains(t, err, "require the stdio transport")
+	_, err = LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "inheritEnv": false, "envAllowlist": ["PATH"]}}}`)
+	assert.ErrorContains(t, err, "envAllowlist")
+	_, err = LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "envAllowlist": ["["]}}}`)
+	assert.ErrorContains(t, err, "invalid envAllowlist pattern")
+	_, err = LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "limits": {"lifetime": "forever"}}}}`)
+	assert.Error(t, err)
+
+	// 进程控制不同的服务器不共享连接池中的连接
+	limited := *config
+	limited.Limits = &ProcessLimits{MemoryMB: 256}
+	assert.NotEqual(t, serverConfigKey("local", config), serverConfigKey("local", &limited))
+}
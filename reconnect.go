@@ -0,0 +1,294 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	mcptransport "github.com/mark3labs/mcp-go/client/transport"
+)
+
+// errServerProcessExited is reported when the stdio server process goes away.
+var errServerProcessExited = errors.New("MCP服务器进程已退出")
+
+// ReconnectPolicy controls how the hub re-establishes a dropped server connection.
+// The delay before attempt n is InitialBackoff * Multiplier^(n-1), capped at
+// MaxBackoff, with up to Jitter (a fraction of the delay) added or removed at random.
+type ReconnectPolicy struct {
+	MaxAttempts    int           // Maximum reconnect attempts per outage, 0 disables reconnecting
+	InitialBackoff time.Duration // Delay before the second attempt
+	MaxBackoff     time.Duration // Upper bound for the delay between attempts
+	Multiplier     float64       // Growth factor of the delay, values below 1 are treated as 1
+	Jitter         float64       // Random fraction of the delay in [0, 1]
+}
+
+// DefaultReconnectPolicy is used by hubs that do not configure WithReconnectPolicy.
+var DefaultReconnectPolicy = ReconnectPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: 200 * time.Millisecond,
+	MaxBackoff:     10 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// WithReconnectPolicy overrides the policy used to reconnect dropped servers.
+// Pass a policy with MaxAttempts set to 0 to disable automatic reconnection.
+func WithReconnectPolicy(policy ReconnectPolicy) MCPHubOption {
+	return func(h *MCPHub) {
+		h.reconnectPolicy = policy
+	}
+}
+
+// backoff returns the delay to wait after the given failed attempt (starting at 1).
+func (p ReconnectPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		delay *= multiplier
+		if p.MaxBackoff > 0 && delay >= float64(p.MaxBackoff) {
+			delay = float64(p.MaxBackoff)
+			break
+		}
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	return time.Duration(delay)
+}
+
+// reconnectFlight is a reconnect attempt shared by every caller that noticed the outage.
+type reconnectFlight struct {
+	done chan struct{}
+	err  error
+}
+
+// reconnectEnabled reports whether dropped connections are re-established automatically.
+func (h *MCPHub) reconnectEnabled() bool {
+	return h.reconnectPolicy.MaxAttempts > 0
+}
+
+// lifetimeContext returns the context bound to the lifetime of the hub.
+func (h *MCPHub) lifetimeContext() context.Context {
+	if h.ctx == nil {
+		return context.Background()
+	}
+	return h.ctx
+}
+
+// isConnectionError reports whether err means the transport to the server is gone,
+// as opposed to the server rejecting the request or the caller giving up.
+func isConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var transportErr *mcptransport.Error
+	if errors.As(err, &transportErr) {
+		return true
+	}
+
+	return strings.Contains(err.Error(), "file already closed") ||
+		strings.Contains(err.Error(), "failed to write request")
+}
+
+// watchConnection registers a connection-lost handler on transports that support it,
+// so the hub starts reconnecting without waiting for the next tool call.
+func (h *MCPHub) watchConnection(mcpClient *client.Client, serverName string) {
+	mcpClient.OnConnectionLost(func(err error) {
+		h.handleConnectionLost(mcpClient, serverName, err)
+	})
+}
+
+// handleConnectionLost starts a background reconnect if mcpClient is still the live
+// client of the server. Notifications from clients that were replaced or closed by
+// the hub are ignored.
+func (h *MCPHub) handleConnectionLost(mcpClient *client.Client, serverName string, cause error) {
+	if !h.reconnectEnabled() {
+		return
+	}
+
+	h.mu.RLock()
+	conn, ok := h.connections[serverName]
+	live := ok && !h.closed && conn.Client == client.MCPClient(mcpClient)
+	h.mu.RUnlock()
+	if !live {
+		return
+	}
+
+	logf("MCP服务器连接已断开: %s, 原因: %v", serverName, cause)
+	h.startReconnect(serverName, cause)
+}
+
+// awaitReconnect reconnects the server, joining an attempt that is already running,
+// and waits for it to finish or for ctx to be done.
+//
+// Returns:
+//   - client.MCPClient: The new live client of the server
+//   - error: Error if reconnecting failed or ctx was done first
+func (h *MCPHub) awaitReconnect(ctx context.Context, serverName string, cause error) (client.MCPClient, error) {
+	flight := h.startReconnect(serverName, cause)
+
+	select {
+	case <-flight.done:
+		if flight.err != nil {
+			return nil, flight.err
+		}
+		return h.GetClient(serverName)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// startReconnect starts a reconnect for the server unless one is already in flight.
+// The attempt runs on the hub lifetime context, so it outlives the tool call that
+// detected the outage and stops when the hub is closed.
+func (h *MCPHub) startReconnect(serverName string, cause error) *reconnectFlight {
+	h.reconnectMu.Lock()
+	defer h.reconnectMu.Unlock()
+
+	if flight, ok := h.reconnects[serverName]; ok {
+		return flight
+	}
+	if h.reconnects == nil {
+		h.reconnects = make(map[string]*reconnectFlight)
+	}
+
+	flight := &reconnectFlight{done: make(chan struct{})}
+	h.reconnects[serverName] = flight
+
+	go func() {
+		flight.err = h.reconnectServer(h.lifetimeContext(), serverName, cause)
+
+		h.reconnectMu.Lock()
+		delete(h.reconnects, serverName)
+		h.reconnectMu.Unlock()
+		close(flight.done)
+	}()
+
+	return flight
+}
+
+// reconnectServer re-runs client creation, initialization and tool discovery for a
+// server with exponential backoff, then swaps the new client into the existing
+// Connection and re-registers the server's tools.
+func (h *MCPHub) reconnectServer(ctx context.Context, serverName string, cause error) error {
+	h.mu.RLock()
+	conn, ok := h.connections[serverName]
+	var (
+		config *ServerConfig
+		live   client.MCPClient
+	)
+	if ok {
+		config = conn.Config
+		live = conn.Client
+	}
+	h.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("未找到服务器连接: %s", serverName)
+	}
+	if err := h.checkRestart(serverName, live); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.setServerStatus(ServerStatus{MCPTools: MCPTools{Name: serverName, Err: cause}, State: ServerStateReconnecting})
+	h.mu.Unlock()
+
+	lastErr := cause
+	for attempt := 1; attempt <= h.reconnectPolicy.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			timer := time.NewTimer(h.reconnectPolicy.backoff(attempt - 1))
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+
+		h.emitEvent(HubEvent{Type: EventReconnecting, Server: serverName, Attempt: attempt, Err: lastErr})
+		logf("正在重新连接MCP服务器: %s, 第 %d 次尝试", serverName, attempt)
+
+		// 每次尝试都受服务器超时限制，避免卡在无响应的服务器上
+		attemptCtx, cancel := context.WithTimeout(ctx, config.GetTimeoutDuration())
+		mcpClient, initResult, allTools, err := h.dialServer(attemptCtx, serverName, config)
+		cancel()
+		if err != nil {
+			lastErr = err
+			logf("重新连接MCP服务器 %s 失败: %v", serverName, err)
+			continue
+		}
+		tools := h.filterTools(serverName, config, allTools)
+
+		h.mu.Lock()
+		conn, ok := h.connections[serverName]
+		if !ok || h.closed || connectionChanged(conn.Config, config) {
+			// 服务器已被关闭、移除或以新的连接设置重新配置
+			h.mu.Unlock()
+			mcpClient.Close()
+			return fmt.Errorf("服务器 %s 已关闭，放弃重连", serverName)
+		}
+		if conn.Client != live {
+			// 共享同一连接的其他hub已经重连，本hub已切换到它的客户端
+			h.mu.Unlock()
+			mcpClient.Close()
+			h.emitEvent(HubEvent{Type: EventReconnected, Server: serverName, Attempt: attempt})
+			return nil
+		}
+		// 重连期间可能只更新了实时设置，按最新的配置注册工具
+		config = conn.Config
+		oldClient := conn.Client
+		conn.Client = mcpClient
+		before := h.toolSnapshot()
+		h.removeServerTools(serverName)
+		if err := h.registerTools(serverName, config, tools); err != nil {
+			logf("重新注册服务器 %s 的工具失败: %v", serverName, err)
+		}
+		h.setServerStatus(ServerStatus{MCPTools: MCPTools{Name: serverName, Tools: tools}, State: ServerStateConnected, ListedTools: toolNames(allTools)})
+		h.rememberTools(serverName, config, initResult, allTools)
+		change := diffTools(before, h.toolSnapshot())
+		h.mu.Unlock()
+
+		// 旧客户端的传输已失效，在后台关闭避免阻塞
+		go oldClient.Close()
+		GetConnectionPool().shareReconnectedClient(h, serverName, oldClient, mcpClient)
+
+		h.emitEvent(HubEvent{Type: EventReconnected, Server: serverName, Attempt: attempt})
+		// 服务器重启后工具列表可能不同
+		h.emitToolsChanged(serverName, change)
+		logf("成功重新连接MCP服务器: %s", serverName)
+		return nil
+	}
+
+	err := fmt.Errorf("重新连接服务器 %s 失败，已尝试 %d 次: %w", serverName, h.reconnectPolicy.MaxAttempts, lastErr)
+	h.mu.Lock()
+	h.setServerStatus(ServerStatus{MCPTools: MCPTools{Name: serverName, Err: err}, State: ServerStateFailed})
+	h.mu.Unlock()
+	h.emitEvent(HubEvent{Type: EventReconnectFailed, Server: serverName, Attempt: h.reconnectPolicy.MaxAttempts, Err: err})
+	return err
+}
+
+// adoptClient switches a connection to the client another hub sharing the pooled
+// connection reconnected, so the server counts as connected again. Callers must hold
+// h.mu.
+func (h *MCPHub) adoptClient(conn *Connection, serverName string, mcpClient client.MCPClient) {
+	conn.Client = mcpClient
+	if status, ok := h.status[serverName]; ok && status.State != ServerStateConnected {
+		updated := *status
+		updated.State, updated.Err = ServerStateConnected, nil
+		h.setServerStatus(updated)
+	}
+}
@@ -0,0 +1,225 @@
+package einomcphost
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyHTTPServer 是一个可以模拟宕机的streamable HTTP MCP服务器
+type flakyHTTPServer struct {
+	*httptest.Server
+	down atomic.Bool
+}
+
+func newFlakyHTTPServer(t *testing.T) *flakyHTTPServer {
+	t.Helper()
+
+	mcpServer := server.NewStreamableHTTPServer(newTestInprocessServer(t))
+	s := &flakyHTTPServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.down.Load() {
+			http.Error(w, "server down", http.StatusServiceUnavailable)
+			return
+		}
+		mcpServer.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// eventRecorder 收集hub事件
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []HubEvent
+}
+
+func (r *eventRecorder) handle(event HubEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) types() []HubEventType {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var types []HubEventType
+	for _, event := range r.events {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+// TestReconnectAfterServerOutage 测试服务器恢复后已取出的Eino工具继续可用
+func TestReconnectAfterServerOutage(t *testing.T) {
+	ctx := context.Background()
+	srv := newFlakyHTTPServer(t)
+	recorder := &eventRecorder{}
+
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"flaky": {Transport: transportHTTP1, URL: srv.URL + "/mcp"},
+		},
+	}
+	hub, err := NewMCPHubFromSettings(ctx, settings,
+		WithReconnectPolicy(ReconnectPolicy{MaxAttempts: 50, InitialBackoff: 20 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Multiplier: 2}),
+		WithEventHandler(recorder.handle),
+	)
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	tools, err := hub.GetEinoTools(ctx, []string{"flaky_echo"})
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	echo, ok := tools[0].(tool.InvokableTool)
+	require.True(t, ok)
+
+	client, err := hub.GetClient("flaky")
+	require.NoError(t, err)
+
+	// 模拟服务器宕机一段时间后恢复
+	srv.down.Store(true)
+	time.AfterFunc(300*time.Millisecond, func() { srv.down.Store(false) })
+
+	// 宕机前取出的工具在重连后继续可用
+	result, err := echo.InvokableRun(ctx, `{"message": "again"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "Echo: again", result)
+
+	newClient, err := hub.GetClient("flaky")
+	require.NoError(t, err)
+	assert.NotSame(t, client, newClient, "client should be replaced after reconnect")
+
+	types := recorder.types()
+	assert.Contains(t, types, EventReconnecting)
+	assert.Contains(t, types, EventReconnected)
+	assert.Equal(t, ServerStateConnected, hub.ServerStatuses()["flaky"].State)
+}
+
+// TestReconnectGivesUp 测试重试次数耗尽后返回错误并记录状态
+func TestReconnectGivesUp(t *testing.T) {
+	ctx := context.Background()
+	srv := newFlakyHTTPServer(t)
+	recorder := &eventRecorder{}
+
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"flaky": {Transport: transportHTTP1, URL: srv.URL + "/mcp"},
+		},
+	}
+	hub, err := NewMCPHubFromSettings(ctx, settings,
+		WithReconnectPolicy(ReconnectPolicy{MaxAttempts: 2, InitialBackoff: 10 * time.Millisecond}),
+		WithEventHandler(recorder.handle),
+	)
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	srv.down.Store(true)
+
+	_, err = hub.InvokeTool(ctx, "flaky_echo", map[string]any{"message": "lost"})
+	require.Error(t, err)
+
+	assert.Equal(t, []HubEventType{EventReconnecting, EventReconnecting, EventReconnectFailed}, recorder.types())
+	status := hub.ServerStatuses()["flaky"]
+	assert.Equal(t, ServerStateFailed, status.State)
+	assert.Error(t, status.Err)
+}
+
+// TestReconnectDisabled 测试关闭重连后保持原有的失败行为
+func TestReconnectDisabled(t *testing.T) {
+	ctx := context.Background()
+	srv := newFlakyHTTPServer(t)
+	recorder := &eventRecorder{}
+
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"flaky": {Transport: transportHTTP1, URL: srv.URL + "/mcp"},
+		},
+	}
+	hub, err := NewMCPHubFromSettings(ctx, settings,
+		WithReconnectPolicy(ReconnectPolicy{}),
+		WithEventHandler(recorder.handle),
+	)
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	srv.down.Store(true)
+
+	_, err = hub.InvokeTool(ctx, "flaky_echo", map[string]any{"message": "lost"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "MCP服务器连接不可用")
+	assert.Empty(t, recorder.types())
+}
+
+// TestReconnectPolicyBackoff 测试退避时间的增长和上限
+func TestReconnectPolicyBackoff(t *testing.T) {
+	policy := ReconnectPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+
+	assert.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	assert.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	assert.Equal(t, 400*time.Millisecond, policy.backoff(3))
+	assert.Equal(t, time.Second, policy.backoff(10))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		delay := policy.backoff(2)
+		assert.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		assert.LessOrEqual(t, delay, 300*time.Millisecond)
+	}
+}
+
+// TestReconnectUpdatesSharedConnection 测试一个hub重连后，共享池中连接的其他hub切换到新的客户端
+func TestReconnectUpdatesSharedConnection(t *testing.T) {
+	ctx := context.Background()
+	srv := newFlakyHTTPServer(t)
+	pool := GetConnectionPool()
+	settings := func() *MCPSettings {
+		return &MCPSettings{
+			MCPServers: map[string]*ServerConfig{
+				"pool_shared_flaky": {Transport: transportHTTP1, URL: srv.URL + "/mcp"},
+			},
+		}
+	}
+
+	pooled, err := pool.GetHub(ctx, settings())
+	require.NoError(t, err)
+	t.Cleanup(func() { pool.ForceCloseHub(settings()) })
+
+	hub, err := NewMCPHubFromSettings(ctx, settings(),
+		WithReconnectPolicy(ReconnectPolicy{MaxAttempts: 50, InitialBackoff: 20 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Multiplier: 2}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	oldClient, err := pooled.GetClient("pool_shared_flaky")
+	require.NoError(t, err)
+	shared, err := hub.GetClient("pool_shared_flaky")
+	require.NoError(t, err)
+	require.Same(t, oldClient, shared, "第二个hub应复用池中的连接")
+
+	srv.down.Store(true)
+	time.AfterFunc(200*time.Millisecond, func() { srv.down.Store(false) })
+	result, err := hub.InvokeTool(ctx, "pool_shared_flaky_echo", map[string]any{"message": "again"})
+	require.NoError(t, err)
+	assert.Equal(t, "Echo: again", result)
+
+	newClient, err := hub.GetClient("pool_shared_flaky")
+	require.NoError(t, err)
+	assert.NotSame(t, oldClient, newClient)
+	pooledClient, err := pooled.GetClient("pool_shared_flaky")
+	require.NoError(t, err)
+	assert.Same(t, newClient, pooledClient, "池中的hub应切换到重连后的客户端")
+
+	result, err = pooled.InvokeTool(ctx, "pool_shared_flaky_echo", map[string]any{"message": "pooled"})
+	require.NoError(t, err)
+	assert.Equal(t, "Echo: pooled", result)
+}
@@ -0,0 +1,147 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// watchToolList registers a handler that refreshes the server's tools when the
+// server sends notifications/tools/list_changed.
+func (h *MCPHub) watchToolList(mcpClient *client.Client, serverName string) {
+	mcpClient.OnNotification(func(notification mcp.JSONRPCNotification) {
+		if notification.Method != mcp.MethodNotificationToolsListChanged {
+			return
+		}
+
+		h.mu.RLock()
+		conn, ok := h.connections[serverName]
+		live := ok && !h.closed && conn.Client == client.MCPClient(mcpClient)
+		h.mu.RUnlock()
+		if !live {
+			return
+		}
+
+		// 通知在传输层的读取协程中回调，不能在这里同步发起请求
+		go func() {
+			logf("MCP服务器 %s 的工具列表已变化，重新发现工具", serverName)
+			if err := h.RefreshTools(h.lifetimeContext(), serverName); err != nil {
+				logf("刷新服务器 %s 的工具失败: %v", serverName, err)
+			}
+		}()
+	})
+}
+
+// RefreshTools lists the tools of a connected server again, applies the
+// allowedTools/excludedTools filters and tool overrides, and replaces the server's
+// tools in the hub in one step. If the set of exposed tools changed, an
+// EventToolsChanged event is emitted so long-lived agents can rebuild their
+// ToolsNode from GetEinoTools.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - serverName: Name of the server whose tools should be refreshed
+//
+// Returns:
+//   - error: Error if the server is not connected or listing its tools fails
+func (h *MCPHub) RefreshTools(ctx context.Context, serverName string) error {
+	conn, err := h.getConnection(serverName)
+	if err != nil {
+		return err
+	}
+	config := conn.Config
+	if config == nil {
+		config = &ServerConfig{}
+	}
+
+	listCtx, cancel := context.WithTimeout(ctx, config.GetTimeoutDuration())
+	allTools, err := h.discoverTools(listCtx, conn.Client)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("刷新服务器 %s 的工具失败: %w", serverName, err)
+	}
+	tools := h.filterTools(serverName, config, allTools)
+
+	h.mu.Lock()
+	current, ok := h.connections[serverName]
+	if !ok || h.closed || current.Client != conn.Client {
+		// 连接已被重连或关闭替换，新连接会自行发现工具
+		h.mu.Unlock()
+		return nil
+	}
+	before := h.toolSnapshot()
+	if err := h.registerTools(serverName, config, tools); err != nil {
+		h.mu.Unlock()
+		return fmt.Errorf("刷新服务器 %s 的工具失败: %w", serverName, err)
+	}
+	h.setServerStatus(ServerStatus{MCPTools: MCPTools{Name: serverName, Tools: tools}, State: ServerStateConnected, ListedTools: toolNames(allTools)})
+	h.rememberTools(serverName, config, nil, allTools)
+	change := diffTools(before, h.toolSnapshot())
+	h.mu.Unlock()
+
+	h.emitToolsChanged(serverName, change)
+	return nil
+}
+
+// toolSnapshot returns the prepared tool behind every exposed tool name. Callers must hold h.mu.
+func (h *MCPHub) toolSnapshot() map[string]*preparedTool {
+	snapshot := make(map[string]*preparedTool, len(h.toolIndex))
+	for name, prepared := range h.toolIndex {
+		snapshot[name] = prepared
+	}
+	return snapshot
+}
+
+// toolsChange lists the exposed tool names that differ between two snapshots.
+type toolsChange struct {
+	added, removed, updated []string
+}
+
+// empty reports whether nothing changed.
+func (c toolsChange) empty() bool {
+	return len(c.added) == 0 && len(c.removed) == 0 && len(c.updated) == 0
+}
+
+// diffTools compares two tool snapshots. A tool counts as updated if the name now
+// refers to another server's tool or the tool definition or override changed.
+func diffTools(before, after map[string]*preparedTool) toolsChange {
+	var change toolsChange
+	for name, next := range after {
+		prev, ok := before[name]
+		switch {
+		case !ok:
+			change.added = append(change.added, name)
+		case prev.server != next.server || !reflect.DeepEqual(prev.mcpTool, next.mcpTool) || !reflect.DeepEqual(prev.override, next.override):
+			change.updated = append(change.updated, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			change.removed = append(change.removed, name)
+		}
+	}
+	sort.Strings(change.added)
+	sort.Strings(change.removed)
+	sort.Strings(change.updated)
+	return change
+}
+
+// emitToolsChanged emits EventToolsChanged unless change is empty. Callers must not hold h.mu.
+func (h *MCPHub) emitToolsChanged(serverName string, change toolsChange) {
+	if change.empty() {
+		return
+	}
+	logf("服务器 %s 的工具已更新: 新增 %v, 删除 %v, 修改 %v", serverName, change.added, change.removed, change.updated)
+	h.emitEvent(HubEvent{
+		Type:    EventToolsChanged,
+		Server:  serverName,
+		Added:   change.added,
+		Removed: change.removed,
+		Updated: change.updated,
+	})
+}
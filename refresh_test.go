@@ -0,0 +1,110 @@
+package einomcphost
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestToolListChanged 测试服务器推送tools/list_changed后hub自动刷新工具
+func TestToolListChanged(t *testing.T) {
+	ctx := context.Background()
+	s := server.NewMCPServer("list-changed-server", "1.0.0", server.WithToolCapabilities(true))
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(request.Params.Name), nil
+	}
+	s.AddTool(mcp.NewTool("alpha"), handler)
+	s.AddTool(mcp.NewTool("beta"), handler)
+
+	events := make(chan HubEvent, 10)
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"live": {Transport: transportHTTP1, URL: newTestHTTPServer(t, s), ExcludedTools: []string{"secret*"}},
+		},
+	}
+	hub, err := NewMCPHubFromSettings(ctx, settings, WithEventHandler(func(event HubEvent) {
+		if event.Type == EventToolsChanged {
+			events <- event
+		}
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	// 等待客户端的监听流建立
+	time.Sleep(200 * time.Millisecond)
+
+	s.AddTool(mcp.NewTool("gamma"), handler)
+	s.AddTool(mcp.NewTool("secret_tool"), handler)
+	s.DeleteTools("beta")
+
+	// 每次变化都会推送通知，直到工具集合收敛
+	added, removed := map[string]bool{}, map[string]bool{}
+	deadline := time.After(5 * time.Second)
+	for !added["live_gamma"] || !removed["live_beta"] {
+		select {
+		case event := <-events:
+			assert.Equal(t, "live", event.Server)
+			for _, name := range event.Added {
+				added[name] = true
+			}
+			for _, name := range event.Removed {
+				removed[name] = true
+			}
+		case <-deadline:
+			t.Fatalf("没有收到工具变化事件: added=%v removed=%v", added, removed)
+		}
+	}
+	assert.False(t, added["live_secret_tool"], "排除的工具不应出现")
+
+	result, err := hub.InvokeTool(ctx, "live_gamma", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "gamma", result)
+	_, err = hub.InvokeTool(ctx, "live_beta", nil)
+	assert.Error(t, err)
+}
+
+// TestRefreshTools 测试手动刷新工具和更新事件
+func TestRefreshTools(t *testing.T) {
+	ctx := context.Background()
+	// 不声明listChanged能力，服务器不会推送通知
+	s := server.NewMCPServer("refresh-server", "1.0.0")
+	handler := func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		return mcp.NewToolResultText(request.Params.Name), nil
+	}
+	s.AddTool(mcp.NewTool("alpha", mcp.WithDescription("v1")), handler)
+
+	recorder := &eventRecorder{}
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"manual": {Transport: transportHTTP1, URL: newTestHTTPServer(t, s)},
+		},
+	}
+	hub, err := NewMCPHubFromSettings(ctx, settings, WithEventHandler(recorder.handle))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	// 没有变化时不发送事件
+	require.NoError(t, hub.RefreshTools(ctx, "manual"))
+	assert.NotContains(t, recorder.types(), EventToolsChanged)
+
+	s.AddTool(mcp.NewTool("alpha", mcp.WithDescription("v2")), handler)
+	s.AddTool(mcp.NewTool("beta"), handler)
+	require.NoError(t, hub.RefreshTools(ctx, "manual"))
+
+	recorder.mu.Lock()
+	event := recorder.events[len(recorder.events)-1]
+	recorder.mu.Unlock()
+	assert.Equal(t, EventToolsChanged, event.Type)
+	assert.Equal(t, []string{"manual_beta"}, event.Added)
+	assert.Equal(t, []string{"manual_alpha"}, event.Updated)
+	assert.Empty(t, event.Removed)
+
+	assert.Len(t, hub.ServerStatuses()["manual"].Tools, 2)
+
+	assert.Error(t, hub.RefreshTools(ctx, "missing"))
+}
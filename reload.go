@@ -0,0 +1,266 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+)
+
+// DefaultConfigWatchInterval is how often a watched configuration file is checked
+// for changes when WithConfigWatch is given a non-positive interval.
+const DefaultConfigWatchInterval = 2 * time.Second
+
+// WithConfigWatch makes a hub created by NewMCPHub poll its configuration file and
+// call ReloadConfig whenever the content changes. It has no effect on hubs created
+// from a string or from MCPSettings.
+//
+// Parameters:
+//   - interval: Polling interval, DefaultConfigWatchInterval if not positive
+func WithConfigWatch(interval time.Duration) MCPHubOption {
+	return func(h *MCPHub) {
+		if interval <= 0 {
+			interval = DefaultConfigWatchInterval
+		}
+		h.configWatchInterval = interval
+	}
+}
+
+// withConfigPath records the file the hub settings were loaded from and its digest.
+func withConfigPath(path string, digest [sha256.Size]byte) MCPHubOption {
+	return func(h *MCPHub) {
+		h.configPath = path
+		h.configDigest = digest
+	}
+}
+
+// ReloadConfig loads the hub's configuration file again and reconciles the running
+// servers with it. Servers that were removed from the file are closed, new servers
+// are connected, and servers whose connection settings (transport, command, args,
+// env, URL, headers, oauth, proxy, tls, cwd, env inheritance, limits, tool filters,
+// disabled) changed are reconnected. Other changes, such as timeouts, autoApprove
+// or toolOverrides, are applied to the live connection without reconnecting, also
+// while it is reconnecting. Servers that did not change keep their connection, and
+// clients shared with other hubs through the connection pool are released rather
+// than closed.
+//
+// If the file cannot be read or fails validation the running configuration stays in
+// place, an EventConfigRejected event is emitted and the error is returned.
+// Otherwise an EventConfigReloaded event is emitted once reconciliation finishes.
+//
+// Parameters:
+//   - ctx: Context for connecting new and changed servers
+//
+// Returns:
+//   - error: Error if the file is invalid, or the joined errors of servers that
+//     failed to apply
+func (h *MCPHub) ReloadConfig(ctx context.Context) error {
+	if h.configPath == "" {
+		return fmt.Errorf("MCPHub不是从配置文件创建的，无法重新加载配置")
+	}
+
+	h.reloadMu.Lock()
+	defer h.reloadMu.Unlock()
+
+	settings, err := LoadSettings(h.configPath)
+	if err != nil {
+		err = fmt.Errorf("配置文件 %s 无效，继续使用当前配置: %w", h.configPath, err)
+		logf("%v", err)
+		h.emitEvent(HubEvent{Type: EventConfigRejected, Err: err})
+		return err
+	}
+
+	err = h.applySettings(ctx, settings)
+	if err != nil {
+		logf("重新加载配置文件 %s 时部分服务器失败: %v", h.configPath, err)
+	} else {
+		logf("已重新加载配置文件: %s", h.configPath)
+	}
+	h.emitEvent(HubEvent{Type: EventConfigReloaded, Err: err})
+	return err
+}
+
+// applySettings reconciles the running servers with validated settings.
+// Servers added with WithInprocessMCPClient are not part of any file and are kept.
+func (h *MCPHub) applySettings(ctx context.Context, next *MCPSettings) error {
+	h.mu.Lock()
+	current := make(map[string]*ServerConfig, len(h.config.MCPServers))
+	for name, config := range h.config.MCPServers {
+		current[name] = config
+	}
+	hubFiltersChanged := !slices.Equal(h.config.AllowedTools, next.AllowedTools) ||
+		!slices.Equal(h.config.ExcludedTools, next.ExcludedTools)
+	h.config.AllowedTools, h.config.ExcludedTools = next.AllowedTools, next.ExcludedTools
+	h.mu.Unlock()
+
+	var errs []error
+
+	for _, name := range sortedServerNames(current) {
+		if _, ok := next.MCPServers[name]; ok || name == innerServerName || current[name].Transport == transportInprocess {
+			continue
+		}
+		if err := h.RemoveServer(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, name := range sortedServerNames(next.MCPServers) {
+		config, old := next.MCPServers[name], current[name]
+		var err error
+		switch {
+		case name == innerServerName:
+			continue
+		case old == nil:
+			err = h.AddServer(ctx, name, config)
+		case old.Transport == transportInprocess:
+			logf("服务器 %s 是进程内服务器，忽略配置文件中的同名配置", name)
+		case connectionChanged(old, config):
+			err = h.UpdateServer(ctx, name, config)
+		case !reflect.DeepEqual(old, config):
+			err = h.updateServerSettings(ctx, name, config)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if hubFiltersChanged {
+		// 全局过滤器变化时，所有已连接服务器按新规则重新发现工具
+		h.mu.RLock()
+		var connected, idle []string
+		for name := range h.connections {
+			connected = append(connected, name)
+		}
+		for name, status := range h.status {
+			if status.State == ServerStateIdle {
+				idle = append(idle, name)
+			}
+		}
+		h.mu.RUnlock()
+		sort.Strings(connected)
+		for _, name := range connected {
+			if err := h.RefreshTools(ctx, name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		// 未连接的延迟连接服务器按新规则从工具清单重新注册
+		sort.Strings(idle)
+		for _, name := range idle {
+			if err := h.refreshIdleServer(name); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// updateServerSettings replaces the config of a server without reconnecting it and
+// re-registers its tools, so new toolOverrides and autoApprove entries take effect.
+// The connection is replaced rather than modified, so callers holding a copy of it
+// keep a consistent config.
+func (h *MCPHub) updateServerSettings(ctx context.Context, name string, config *ServerConfig) error {
+	h.adminMu.Lock()
+	defer h.adminMu.Unlock()
+
+	h.mu.Lock()
+	h.config.MCPServers[name] = config
+	conn, connected := h.connections[name]
+	if connected {
+		updated := *conn
+		updated.Config = config
+		h.connections[name] = &updated
+	}
+	h.mu.Unlock()
+
+	if !connected {
+		return h.refreshIdleServer(name)
+	}
+	return h.RefreshTools(ctx, name)
+}
+
+// connectionChanged reports whether two configs of a server differ in anything
+// other than the settings that can be applied to a live connection.
+func connectionChanged(old, next *ServerConfig) bool {
+	return !reflect.DeepEqual(connectionSettings(old), connectionSettings(next))
+}
+
+// connectionSettings returns a copy of config without the settings that can be
+// applied to a live connection.
+func connectionSettings(config *ServerConfig) ServerConfig {
+	c := *config
+	c.AutoApprove = nil
+	c.Timeout = 0
+	c.ToolTimeouts = nil
+	c.ToolOverrides = nil
+	c.Restart = nil
+	c.Lazy = nil
+	c.IdleTimeout = 0
+	c.Tools = nil
+	c.MaxConcurrentCalls = 0
+	c.MaxQueueLength = 0
+	return c
+}
+
+// sortedServerNames returns the names of the given servers in order.
+func sortedServerNames(servers map[string]*ServerConfig) []string {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// watchConfig polls the configuration file until the hub is closed and reloads it
+// whenever its content changes. Editors that replace the file may leave it missing
+// for a moment, so read errors are logged once and retried on the next tick.
+func (h *MCPHub) watchConfig(interval time.Duration) {
+	ctx := h.lifetimeContext()
+	last := h.configDigest
+	readFailed := false
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		digest, err := fileDigest(h.configPath)
+		if err != nil {
+			if !readFailed {
+				logf("读取配置文件 %s 失败: %v", h.configPath, err)
+				readFailed = true
+			}
+			continue
+		}
+		readFailed = false
+		if digest == last {
+			continue
+		}
+		last = digest
+
+		// 无效的修改只报告一次，文件再次变化时才重新尝试
+		logf("检测到配置文件变化: %s", h.configPath)
+		h.ReloadConfig(ctx)
+	}
+}
+
+// fileDigest returns the SHA-256 of a file's content.
+func fileDigest(path string) ([sha256.Size]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
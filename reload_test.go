@@ -0,0 +1,216 @@
+package einomcphost
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig 写入配置文件
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+// TestReloadConfig 测试重新加载配置时只重连有变化的服务器
+func TestReloadConfig(t *testing.T) {
+	ctx := context.Background()
+	firstURL := newTestHTTPServer(t, newMultiToolServer("read", "write"))
+	secondURL := newTestHTTPServer(t, newMultiToolServer("list"))
+	thirdURL := newTestHTTPServer(t, newMultiToolServer("search"))
+
+	path := filepath.Join(t.TempDir(), "mcpservers.json")
+	writeConfig(t, path, fmt.Sprintf(`{"mcpServers": {
+		"first": {"url": %q},
+		"second": {"url": %q}
+	}}`, firstURL, secondURL))
+
+	recorder := &eventRecorder{}
+	hub, err := NewMCPHub(ctx, path, WithEventHandler(recorder.handle))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+	assert.Equal(t, []string{"first_read", "first_write", "second_list"}, hubToolNames(t, hub))
+
+	firstClient, err := hub.GetClient("first")
+	require.NoError(t, err)
+	secondClient, err := hub.GetClient("second")
+	require.NoError(t, err)
+
+	// first只修改超时和工具覆盖，second修改过滤器，删除third之外新增third
+	writeConfig(t, path, fmt.Sprintf(`{"mcpServers": {
+		"first": {"url": %q, "timeout": 60, "toolOverrides": {"read": {"name": "cat"}}},
+		"third": {"url": %q},
+		"second": {"url": %q, "excludedTools": ["list"]}
+	}}`, firstURL, thirdURL, secondURL))
+	require.NoError(t, hub.ReloadConfig(ctx))
+
+	assert.Equal(t, []string{"first_cat", "first_write", "third_search"}, hubToolNames(t, hub))
+	client, err := hub.GetClient("first")
+	require.NoError(t, err)
+	assert.Same(t, firstClient, client, "只修改超时和覆盖时不重连")
+	client, err = hub.GetClient("second")
+	require.NoError(t, err)
+	assert.NotSame(t, secondClient, client, "修改过滤器时重连")
+	assert.Contains(t, recorder.types(), EventConfigReloaded)
+
+	// 删除服务器
+	writeConfig(t, path, fmt.Sprintf(`{"mcpServers": {"first": {"url": %q}}}`, firstURL))
+	require.NoError(t, hub.ReloadConfig(ctx))
+	assert.Equal(t, []string{"first_read", "first_write"}, hubToolNames(t, hub))
+	assert.NotContains(t, hub.ServerStatuses(), "third")
+
+	// 无效的修改被拒绝，当前配置保持不变
+	writeConfig(t, path, `{"mcpServers": {"first": {"transport": "sse"}}}`)
+	assert.Error(t, hub.ReloadConfig(ctx))
+	assert.Contains(t, recorder.types(), EventConfigRejected)
+	assert.Equal(t, []string{"first_read", "first_write"}, hubToolNames(t, hub))
+	result, err := hub.InvokeTool(ctx, "first_read", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "read", result)
+}
+
+// TestConfigWatch 测试监听配置文件变化自动重新加载
+func TestConfigWatch(t *testing.T) {
+	ctx := context.Background()
+	firstURL := newTestHTTPServer(t, newMultiToolServer("read"))
+	secondURL := newTestHTTPServer(t, newMultiToolServer("list"))
+
+	path := filepath.Join(t.TempDir(), "mcpservers.json")
+	writeConfig(t, path, fmt.Sprintf(`{"mcpServers": {"first": {"url": %q}}}`, firstURL))
+
+	events := make(chan HubEvent, 10)
+	hub, err := NewMCPHub(ctx, path, WithConfigWatch(20*time.Millisecond), WithEventHandler(func(event HubEvent) {
+		if event.Type == EventConfigReloaded || event.Type == EventConfigRejected {
+			events <- event
+		}
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	waitEvent := func() HubEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("没有检测到配置文件变化")
+			return HubEvent{}
+		}
+	}
+
+	writeConfig(t, path, `{"mcpServers": {`)
+	event := waitEvent()
+	assert.Equal(t, EventConfigRejected, event.Type)
+	assert.Error(t, event.Err)
+
+	writeConfig(t, path, fmt.Sprintf(`{"mcpServers": {"first": {"url": %q}, "second": {"url": %q}}}`, firstURL, secondURL))
+	event = waitEvent()
+	assert.Equal(t, EventConfigReloaded, event.Type)
+	assert.NoError(t, event.Err)
+	assert.Equal(t, []string{"first_read", "second_list"}, hubToolNames(t, hub))
+}
+
+// TestReloadDuringReconnect 测试重连期间只修改实时设置的重新加载不会中断重连
+func TestReloadDuringReconnect(t *testing.T) {
+	ctx := context.Background()
+	srv := newFlakyHTTPServer(t)
+
+	path := filepath.Join(t.TempDir(), "mcpservers.json")
+	writeConfig(t, path, fmt.Sprintf(`{"mcpServers": {"flaky": {"transport": "http", "url": %q}}}`, srv.URL+"/mcp"))
+
+	reconnecting := make(chan struct{}, 10)
+	recorder := &eventRecorder{}
+	hub, err := NewMCPHub(ctx, path,
+		WithReconnectPolicy(ReconnectPolicy{MaxAttempts: 3, InitialBackoff: 500 * time.Millisecond, MaxBackoff: 500 * time.Millisecond, Multiplier: 1}),
+		WithEventHandler(func(event HubEvent) {
+			recorder.handle(event)
+			if event.Type == EventReconnecting {
+				reconnecting <- struct{}{}
+			}
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	srv.down.Store(true)
+	done := make(chan error, 1)
+	go func() {
+		_, err := hub.InvokeTool(ctx, "flaky_echo", map[string]any{"message": "again"})
+		done <- err
+	}()
+
+	// 第一次重连失败后，在退避期间恢复服务器并修改超时
+	select {
+	case <-reconnecting:
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有开始重连")
+	}
+	srv.down.Store(false)
+	writeConfig(t, path, fmt.Sprintf(`{"mcpServers": {"flaky": {"transport": "http", "url": %q, "timeout": 60}}}`, srv.URL+"/mcp"))
+	require.NoError(t, hub.ReloadConfig(ctx))
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("调用没有完成")
+	}
+	assert.Contains(t, recorder.types(), EventReconnected)
+	assert.NotContains(t, recorder.types(), EventReconnectFailed)
+	assert.Equal(t, ServerStateConnected, hub.ServerStatuses()["flaky"].State)
+	assert.Equal(t, 60*time.Second, hub.config.MCPServers["flaky"].Timeout)
+}
+
+// TestReloadKeepsSharedConnection 测试重新加载配置修改或删除服务器时不关闭共享池中连接的其他hub的客户端
+func TestReloadKeepsSharedConnection(t *testing.T) {
+	ctx := context.Background()
+	pool := GetConnectionPool()
+	url := newTestHTTPServer(t, newMultiToolServer("read", "write"))
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"pool_reload_shared": {Transport: transportHTTP1, URL: url},
+		},
+	}
+	pooled, err := pool.GetHub(ctx, settings)
+	require.NoError(t, err)
+	t.Cleanup(func() { pool.ForceCloseHub(settings) })
+	pooledClient, err := pooled.GetClient("pool_reload_shared")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "mcpservers.json")
+	writeConfig(t, path, fmt.Sprintf(`{"mcpServers": {"pool_reload_shared": {"transport": "http", "url": %q}}}`, url))
+	sharing, err := NewMCPHub(ctx, path)
+	require.NoError(t, err)
+	t.Cleanup(func() { sharing.CloseServers() })
+	sharedClient, err := sharing.GetClient("pool_reload_shared")
+	require.NoError(t, err)
+	require.Same(t, pooledClient, sharedClient, "第二个hub应复用池中的连接")
+
+	assertPooledAlive := func() {
+		t.Helper()
+		result, err := pooled.InvokeTool(ctx, "pool_reload_shared_read", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "read", result)
+		client, err := pooled.GetClient("pool_reload_shared")
+		require.NoError(t, err)
+		assert.Same(t, pooledClient, client, "共享的客户端未被关闭，不需要重连")
+	}
+
+	// 修改连接设置时重连
+	writeConfig(t, path, fmt.Sprintf(`{"mcpServers": {"pool_reload_shared": {"transport": "http", "url": %q, "excludedTools": ["write"]}}}`, url))
+	require.NoError(t, sharing.ReloadConfig(ctx))
+	assert.Equal(t, []string{"pool_reload_shared_read"}, hubToolNames(t, sharing))
+	assertPooledAlive()
+
+	// 删除服务器
+	writeConfig(t, path, `{"mcpServers": {}}`)
+	require.NoError(t, sharing.ReloadConfig(ctx))
+	assert.Empty(t, hubToolNames(t, sharing))
+	assertPooledAlive()
+}
@@ -0,0 +1,189 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// secretScheme is the scheme of references to a named secret, such as secret://github.
+// It has no built-in backend; register one with WithSecretResolver.
+const secretScheme = "secret"
+
+// SecretResolver looks up the value of a secret reference such as secret://github or
+// file:///run/secrets/token. The reference is passed parsed, so a resolver can use
+// ref.Host as the secret name or ref.Path as a file path.
+type SecretResolver interface {
+	ResolveSecret(ctx context.Context, ref *url.URL) (string, error)
+}
+
+// SecretResolverFunc adapts a function to the SecretResolver interface.
+type SecretResolverFunc func(ctx context.Context, ref *url.URL) (string, error)
+
+// ResolveSecret calls f(ctx, ref).
+func (f SecretResolverFunc) ResolveSecret(ctx context.Context, ref *url.URL) (string, error) {
+	return f(ctx, ref)
+}
+
+// FileSecretResolver returns a resolver that reads file references, such as
+// file:///run/secrets/token, and strips one trailing newline from the content.
+// It is registered for the "file" scheme by default.
+func FileSecretResolver() SecretResolver {
+	return SecretResolverFunc(func(ctx context.Context, ref *url.URL) (string, error) {
+		path := ref.Path
+		if ref.Host != "" {
+			// file://secrets/token 按相对路径处理
+			path = ref.Host + ref.Path
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		value := strings.TrimSuffix(string(data), "\n")
+		return strings.TrimSuffix(value, "\r"), nil
+	})
+}
+
+// EnvSecretResolver returns a resolver for env://NAME references that reads the
+// environment variable NAME and fails if it is unset. It is registered for the
+// "env" scheme by default.
+func EnvSecretResolver() SecretResolver {
+	return SecretResolverFunc(func(ctx context.Context, ref *url.URL) (string, error) {
+		value, ok := os.LookupEnv(ref.Host)
+		if !ok {
+			return "", fmt.Errorf("环境变量 %s 未设置", ref.Host)
+		}
+		return value, nil
+	})
+}
+
+// WithSecretResolver registers a resolver for secret references with the given
+// scheme, replacing the built-in resolver if the scheme is "file" or "env".
+// Header values, bearer token, OAuth client credentials and proxy of a server that
+// consist of exactly one reference of a registered scheme, or of the secret://
+// scheme, are replaced by the resolved value each time the server is connected;
+// WithSecretReferencesAnywhere extends this to command, args, env values and URL.
+// The settings themselves keep the reference, so re-serializing them never writes
+// out a secret.
+//
+// Parameters:
+//   - scheme: URL scheme handled by the resolver, such as "secret" or "vault"
+//   - resolver: Backend looking up the values
+func WithSecretResolver(scheme string, resolver SecretResolver) MCPHubOption {
+	return func(h *MCPHub) {
+		h.secretResolvers[strings.ToLower(scheme)] = resolver
+	}
+}
+
+// WithSecretReferencesAnywhere also resolves secret references in the command, args,
+// env values and URL of servers. Without it these settings are used literally, so an
+// argument such as file:///srv/data is passed on unchanged.
+func WithSecretReferencesAnywhere() MCPHubOption {
+	return func(h *MCPHub) {
+		h.secretsAnywhere = true
+	}
+}
+
+// defaultSecretResolvers returns the built-in resolvers.
+func defaultSecretResolvers() map[string]SecretResolver {
+	return map[string]SecretResolver{
+		"file": FileSecretResolver(),
+		"env":  EnvSecretResolver(),
+	}
+}
+
+// resolveConfig returns a copy of config with environment variables expanded and
+// secret references in its credential settings resolved, and in its other settings
+// with WithSecretReferencesAnywhere. The returned config is used to create the
+// client and is never stored in the hub settings.
+func (h *MCPHub) resolveConfig(ctx context.Context, config *ServerConfig) (*ServerConfig, error) {
+	expanded, err := expandedConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("展开环境变量失败: %w", err)
+	}
+
+	c := *expanded
+	c.Args = slices.Clone(expanded.Args)
+	c.Env = maps.Clone(expanded.Env)
+	c.Headers = maps.Clone(expanded.Headers)
+	c.OAuth = expanded.OAuth.clone()
+	c.TLS = expanded.TLS.clone()
+
+	var errs []error
+	resolve := func(value string) string {
+		resolved, err := h.resolveSecret(ctx, value)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		return resolved
+	}
+
+	if h.secretsAnywhere {
+		c.Command = resolve(c.Command)
+		c.URL = resolve(c.URL)
+		for i, arg := range c.Args {
+			c.Args[i] = resolve(arg)
+		}
+		for _, key := range sortedKeys(c.Env) {
+			c.Env[key] = resolve(c.Env[key])
+		}
+	}
+	for _, key := range sortedKeys(c.Headers) {
+		c.Headers[key] = resolve(c.Headers[key])
+	}
+	c.BearerToken = resolve(c.BearerToken)
+	c.Proxy = resolve(c.Proxy)
+	if c.OAuth != nil {
+		c.OAuth.ClientID = resolve(c.OAuth.ClientID)
+		c.OAuth.ClientSecret = resolve(c.OAuth.ClientSecret)
+	}
+	// 令牌即使直接写在配置中也不应出现在日志里
+	secrets.add(c.BearerToken)
+	if c.OAuth != nil {
+		secrets.add(c.OAuth.ClientSecret)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// resolveSecret resolves value if it is a secret reference and returns it unchanged otherwise.
+func (h *MCPHub) resolveSecret(ctx context.Context, value string) (string, error) {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return value, nil
+	}
+	scheme = strings.ToLower(scheme)
+
+	resolvers := h.secretResolvers
+	if resolvers == nil {
+		resolvers = defaultSecretResolvers()
+	}
+	resolver, registered := resolvers[scheme]
+	if !registered {
+		if scheme == secretScheme {
+			return "", fmt.Errorf("没有为 %s:// 注册SecretResolver", secretScheme)
+		}
+		return value, nil
+	}
+
+	ref, err := url.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("解析秘密引用失败: %w", err)
+	}
+	// 错误信息只包含引用本身，不包含解析出的值
+	resolved, err := resolver.ResolveSecret(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("解析秘密 %s 失败: %w", value, err)
+	}
+	secrets.add(resolved)
+	return resolved, nil
+}
@@ -0,0 +1,85 @@
+package einomcphost
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResolveConfigSecrets 测试内置的file和env解析器
+func TestResolveConfigSecrets(t *testing.T) {
+	ctx := context.Background()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("file-secret-value\n"), 0o600))
+	t.Setenv("SECRET_TEST_KEY", "env-secret-value")
+
+	config := &ServerConfig{
+		Command: "server",
+		Args:    []string{"--token", "file://" + tokenFile},
+		Env:     map[string]string{"KEY": "env://SECRET_TEST_KEY", "MODE": "plain"},
+		URL:     "https://example.com/mcp",
+		Headers: map[string]string{"X-Key": "env://SECRET_TEST_KEY"},
+	}
+
+	// 默认只解析凭据设置，参数和环境变量按字面使用
+	hub := &MCPHub{secretResolvers: defaultSecretResolvers()}
+	resolved, err := hub.resolveConfig(ctx, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--token", "file://" + tokenFile}, resolved.Args)
+	assert.Equal(t, "env://SECRET_TEST_KEY", resolved.Env["KEY"])
+	assert.Equal(t, "env-secret-value", resolved.Headers["X-Key"])
+
+	hub = &MCPHub{secretResolvers: defaultSecretResolvers(), secretsAnywhere: true}
+	resolved, err = hub.resolveConfig(ctx, config)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--token", "file-secret-value"}, resolved.Args)
+	assert.Equal(t, map[string]string{"KEY": "env-secret-value", "MODE": "plain"}, resolved.Env)
+	assert.Equal(t, "https://example.com/mcp", resolved.URL, "未注册的scheme保持原样")
+
+	// 原配置保留引用
+	assert.Equal(t, "file://"+tokenFile, config.Args[1])
+	assert.Equal(t, "env://SECRET_TEST_KEY", config.Env["KEY"])
+
+	// secret:// 没有注册后端时报错，错误中不包含秘密
+	_, err = hub.resolveConfig(ctx, &ServerConfig{BearerToken: "secret://github"})
+	assert.ErrorContains(t, err, "secret://")
+	_, err = hub.resolveConfig(ctx, &ServerConfig{BearerToken: "env://SECRET_TEST_UNSET"})
+	assert.ErrorContains(t, err, "SECRET_TEST_UNSET")
+}
+
+// TestSecretResolverOption 测试自定义后端解析服务器地址，且配置重新序列化时不包含秘密
+func TestSecretResolverOption(t *testing.T) {
+	ctx := context.Background()
+	endpoint := newTestHTTPServer(t, newMultiToolServer("read"))
+
+	var refs []string
+	store := SecretResolverFunc(func(ctx context.Context, ref *url.URL) (string, error) {
+		refs = append(refs, ref.String())
+		return map[string]string{"endpoint": endpoint}[ref.Host], nil
+	})
+
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"vault": {Transport: transportHTTP1, URL: "secret://endpoint"},
+		},
+	}
+	hub, err := NewMCPHubFromSettings(ctx, settings, WithSecretResolver("secret", store), WithSecretReferencesAnywhere())
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	result, err := hub.InvokeTool(ctx, "vault_read", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "read", result)
+	assert.Equal(t, []string{"secret://endpoint"}, refs)
+
+	data, err := json.Marshal(settings)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "secret://endpoint")
+	assert.NotContains(t, string(data), endpoint)
+}
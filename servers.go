@@ -0,0 +1,278 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+	"fmt"
+)
+
+// innerServerName is reserved for the built-in tool server and skipped at startup.
+const innerServerName = "inner"
+
+// AddServer connects a new server and registers its tools on the running hub.
+// Connections to the other servers are left untouched. A disabled config is only
+// recorded, and can be connected later with EnableServer. If connecting fails the
+// hub is left unchanged.
+//
+// Parameters:
+//   - ctx: Context for connecting the server
+//   - name: Name of the new server
+//   - config: Server configuration, validated like a configuration file entry
+//
+// Returns:
+//   - error: Error if the name is taken, the config is invalid or connecting fails
+func (h *MCPHub) AddServer(ctx context.Context, name string, config *ServerConfig) error {
+	if err := checkRuntimeServerConfig(name, config); err != nil {
+		return err
+	}
+
+	h.adminMu.Lock()
+	defer h.adminMu.Unlock()
+
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		return fmt.Errorf("MCPHub已关闭，无法添加服务器: %s", name)
+	}
+	if _, exists := h.config.MCPServers[name]; exists {
+		h.mu.Unlock()
+		return fmt.Errorf("服务器已存在: %s", name)
+	}
+	if h.config.MCPServers == nil {
+		h.config.MCPServers = make(map[string]*ServerConfig)
+	}
+	h.config.MCPServers[name] = config
+	if config.Disabled {
+		h.setServerStatus(ServerStatus{MCPTools: MCPTools{Name: name}, State: ServerStateDisabled})
+		h.mu.Unlock()
+		GetConnectionPool().syncHub(h)
+		return nil
+	}
+	h.mu.Unlock()
+
+	before := h.snapshotTools()
+	if err := h.startServer(ctx, name, config); err != nil {
+		// 添加失败时不保留该服务器
+		h.mu.Lock()
+		delete(h.config.MCPServers, name)
+		delete(h.status, name)
+		h.mu.Unlock()
+		return fmt.Errorf("添加服务器 %s 失败: %w", name, err)
+	}
+
+	GetConnectionPool().syncHub(h)
+	h.emitToolsChanged(name, diffTools(before, h.snapshotTools()))
+	logf("已添加MCP服务器: %s", name)
+	return nil
+}
+
+// RemoveServer closes the connection to a server and removes the server and its
+// tools from the hub. Eino tools of the server handed out earlier fail on their
+// next call.
+//
+// Parameters:
+//   - name: Name of the server to remove
+//
+// Returns:
+//   - error: Error if the server does not exist or its connection fails to close;
+//     the server is removed in either case
+func (h *MCPHub) RemoveServer(name string) error {
+	h.adminMu.Lock()
+	defer h.adminMu.Unlock()
+
+	h.mu.Lock()
+	if _, exists := h.config.MCPServers[name]; !exists {
+		h.mu.Unlock()
+		return fmt.Errorf("未找到服务器: %s", name)
+	}
+	before := h.toolSnapshot()
+	err := h.dropConnection(name)
+	delete(h.config.MCPServers, name)
+	delete(h.status, name)
+	delete(h.supervisors, name)
+	delete(h.manifest, name)
+	delete(h.limiters, name)
+	change := diffTools(before, h.toolSnapshot())
+	h.mu.Unlock()
+	h.forgetIdle(name)
+
+	GetConnectionPool().syncHub(h)
+	h.emitToolsChanged(name, change)
+	logf("已移除MCP服务器: %s", name)
+	return err
+}
+
+// DisableServer closes the connection to a server and unregisters its tools while
+// keeping its configuration, as if it had been configured with "disabled": true.
+// Disabling a disabled server does nothing.
+//
+// Parameters:
+//   - name: Name of the server to disable
+//
+// Returns:
+//   - error: Error if the server does not exist or its connection fails to close
+func (h *MCPHub) DisableServer(name string) error {
+	h.adminMu.Lock()
+	defer h.adminMu.Unlock()
+
+	h.mu.Lock()
+	config, exists := h.config.MCPServers[name]
+	if !exists {
+		h.mu.Unlock()
+		return fmt.Errorf("未找到服务器: %s", name)
+	}
+	if config.Disabled {
+		h.mu.Unlock()
+		return nil
+	}
+	before := h.toolSnapshot()
+	err := h.dropConnection(name)
+	disabled := *config
+	disabled.Disabled = true
+	h.config.MCPServers[name] = &disabled
+	h.setServerStatus(ServerStatus{MCPTools: MCPTools{Name: name}, State: ServerStateDisabled})
+	change := diffTools(before, h.toolSnapshot())
+	h.mu.Unlock()
+
+	GetConnectionPool().syncHub(h)
+	h.emitToolsChanged(name, change)
+	logf("已禁用MCP服务器: %s", name)
+	return err
+}
+
+// EnableServer connects a disabled server and registers its tools. Enabling a server
+// that is already enabled does nothing. If connecting fails the server stays enabled
+// and is reported as failed in ServerStatuses, like a server that failed at startup.
+//
+// Parameters:
+//   - ctx: Context for connecting the server
+//   - name: Name of the server to enable
+//
+// Returns:
+//   - error: Error if the server does not exist or connecting fails
+func (h *MCPHub) EnableServer(ctx context.Context, name string) error {
+	h.adminMu.Lock()
+	defer h.adminMu.Unlock()
+
+	h.mu.Lock()
+	config, exists := h.config.MCPServers[name]
+	if !exists {
+		h.mu.Unlock()
+		return fmt.Errorf("未找到服务器: %s", name)
+	}
+	if h.closed {
+		h.mu.Unlock()
+		return fmt.Errorf("MCPHub已关闭，无法启用服务器: %s", name)
+	}
+	if !config.Disabled {
+		h.mu.Unlock()
+		return nil
+	}
+	enabled := *config
+	enabled.Disabled = false
+	h.config.MCPServers[name] = &enabled
+	h.mu.Unlock()
+
+	return h.reconnectWithConfig(ctx, name, &enabled)
+}
+
+// UpdateServer replaces the configuration of a server and reconnects it with the
+// new settings. The old connection is closed first; other servers are untouched.
+// If connecting with the new config fails the new config is kept and the server is
+// reported as failed in ServerStatuses.
+//
+// Parameters:
+//   - ctx: Context for connecting the server
+//   - name: Name of the server to update
+//   - config: New server configuration
+//
+// Returns:
+//   - error: Error if the server does not exist, the config is invalid or connecting fails
+func (h *MCPHub) UpdateServer(ctx context.Context, name string, config *ServerConfig) error {
+	if err := checkRuntimeServerConfig(name, config); err != nil {
+		return err
+	}
+
+	h.adminMu.Lock()
+	defer h.adminMu.Unlock()
+
+	h.mu.Lock()
+	if _, exists := h.config.MCPServers[name]; !exists {
+		h.mu.Unlock()
+		return fmt.Errorf("未找到服务器: %s", name)
+	}
+	if h.closed {
+		h.mu.Unlock()
+		return fmt.Errorf("MCPHub已关闭，无法更新服务器: %s", name)
+	}
+	h.config.MCPServers[name] = config
+	h.mu.Unlock()
+
+	return h.reconnectWithConfig(ctx, name, config)
+}
+
+// reconnectWithConfig closes the server's current connection, if any, and connects it
+// again with config unless config is disabled. Callers must hold h.adminMu.
+func (h *MCPHub) reconnectWithConfig(ctx context.Context, name string, config *ServerConfig) error {
+	h.mu.Lock()
+	before := h.toolSnapshot()
+	closeErr := h.dropConnection(name)
+	if config.Disabled {
+		h.setServerStatus(ServerStatus{MCPTools: MCPTools{Name: name}, State: ServerStateDisabled})
+	}
+	h.mu.Unlock()
+	if closeErr != nil {
+		logf("%v", closeErr)
+	}
+
+	var err error
+	if !config.Disabled {
+		err = h.startServer(ctx, name, config)
+	}
+
+	GetConnectionPool().syncHub(h)
+	h.emitToolsChanged(name, diffTools(before, h.snapshotTools()))
+	if err != nil {
+		return fmt.Errorf("连接服务器 %s 失败: %w", name, err)
+	}
+	logf("已更新MCP服务器: %s", name)
+	return nil
+}
+
+// dropConnection closes the server's connection, if any, and unregisters its tools.
+// A client shared with other hubs through the connection pool is only released.
+// Unlike closeExistingConnection the connection is removed even if closing fails.
+// Callers must hold h.mu.
+func (h *MCPHub) dropConnection(name string) error {
+	var err error
+	if conn, exists := h.connections[name]; exists {
+		if closeErr := h.closeClient(conn.Client); closeErr != nil {
+			err = fmt.Errorf("关闭服务器 %s 失败: %w", name, closeErr)
+		}
+		delete(h.connections, name)
+	}
+	h.removeServerTools(name)
+	return err
+}
+
+// snapshotTools returns toolSnapshot under the read lock.
+func (h *MCPHub) snapshotTools() map[string]*preparedTool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.toolSnapshot()
+}
+
+// checkRuntimeServerConfig validates a server config passed to AddServer or UpdateServer.
+// Like a configuration file entry, an empty transport is detected from URL or Command.
+func checkRuntimeServerConfig(name string, config *ServerConfig) error {
+	if name == "" {
+		return fmt.Errorf("服务器名称不能为空")
+	}
+	if name == innerServerName {
+		return fmt.Errorf("服务器名称 %s 为内置工具保留", name)
+	}
+	if config == nil {
+		return fmt.Errorf("服务器 %s 的配置不能为空", name)
+	}
+	return validateServerConfig(name, config)
+}
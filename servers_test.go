@@ -0,0 +1,158 @@
+package einomcphost
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hubToolNames 返回hub中所有工具名称，已排序
+func hubToolNames(t *testing.T, hub *MCPHub) []string {
+	t.Helper()
+	toolsMap, err := hub.GetToolsMap(context.Background())
+	require.NoError(t, err)
+	names := make([]string, 0, len(toolsMap))
+	for name := range toolsMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TestRuntimeServerChanges 测试运行时添加、禁用、启用、更新和移除服务器
+func TestRuntimeServerChanges(t *testing.T) {
+	ctx := context.Background()
+	firstURL := newTestHTTPServer(t, newMultiToolServer("read"))
+	secondURL := newTestHTTPServer(t, newMultiToolServer("list", "write"))
+
+	recorder := &eventRecorder{}
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"first": {Transport: transportHTTP1, URL: firstURL},
+		},
+	}
+	hub, err := NewMCPHubFromSettings(ctx, settings, WithEventHandler(recorder.handle))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+	firstClient, err := hub.GetClient("first")
+	require.NoError(t, err)
+
+	// 添加服务器，未指定传输类型时按URL推断
+	require.NoError(t, hub.AddServer(ctx, "second", &ServerConfig{URL: secondURL}))
+	assert.Equal(t, []string{"first_read", "second_list", "second_write"}, hubToolNames(t, hub))
+	assert.Equal(t, ServerStateConnected, hub.ServerStatuses()["second"].State)
+	assert.Contains(t, recorder.types(), EventToolsChanged)
+
+	// 已有连接不受影响
+	client, err := hub.GetClient("first")
+	require.NoError(t, err)
+	assert.Same(t, firstClient, client)
+
+	assert.Error(t, hub.AddServer(ctx, "second", &ServerConfig{URL: secondURL}), "重名服务器")
+	assert.Error(t, hub.AddServer(ctx, "third", &ServerConfig{Transport: transportHTTP1}), "缺少URL")
+	assert.Error(t, hub.AddServer(ctx, "broken", &ServerConfig{Transport: transportHTTP1, URL: "http://127.0.0.1:1/mcp"}))
+	assert.NotContains(t, settings.MCPServers, "broken", "添加失败时不保留配置")
+	assert.NotContains(t, hub.ServerStatuses(), "broken")
+
+	// 禁用和启用
+	require.NoError(t, hub.DisableServer("second"))
+	assert.Equal(t, []string{"first_read"}, hubToolNames(t, hub))
+	assert.Equal(t, ServerStateDisabled, hub.ServerStatuses()["second"].State)
+	_, err = hub.GetClient("second")
+	assert.Error(t, err)
+
+	require.NoError(t, hub.EnableServer(ctx, "second"))
+	assert.Equal(t, []string{"first_read", "second_list", "second_write"}, hubToolNames(t, hub))
+
+	// 更新配置后按新配置重新连接
+	require.NoError(t, hub.UpdateServer(ctx, "second", &ServerConfig{Transport: transportHTTP1, URL: secondURL, ExcludedTools: []string{"write"}}))
+	assert.Equal(t, []string{"first_read", "second_list"}, hubToolNames(t, hub))
+	result, err := hub.InvokeTool(ctx, "second_list", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "list", result)
+
+	// 移除
+	require.NoError(t, hub.RemoveServer("second"))
+	assert.Equal(t, []string{"first_read"}, hubToolNames(t, hub))
+	assert.NotContains(t, settings.MCPServers, "second")
+	assert.NotContains(t, hub.ServerStatuses(), "second")
+	assert.Error(t, hub.RemoveServer("second"))
+	assert.Error(t, hub.EnableServer(ctx, "second"))
+}
+
+// TestRuntimeServerChangesSyncPool 测试运行时修改服务器后连接池映射保持同步
+func TestRuntimeServerChangesSyncPool(t *testing.T) {
+	ctx := context.Background()
+	pool := GetConnectionPool()
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"pool_sync_first": {Transport: transportHTTP1, URL: newTestHTTPServer(t, newMultiToolServer("read"))},
+		},
+	}
+	hub, err := pool.GetHub(ctx, settings)
+	require.NoError(t, err)
+	t.Cleanup(func() { pool.ForceCloseHub(settings) })
+
+	require.NoError(t, hub.AddServer(ctx, "pool_sync_second", &ServerConfig{Transport: transportHTTP1, URL: newTestHTTPServer(t, newMultiToolServer("list"))}))
+	pooled, err := pool.GetHubByServerName("pool_sync_second")
+	require.NoError(t, err)
+	assert.Same(t, hub, pooled)
+
+	// 配置键随服务器集合变化，按当前配置仍能取回同一个hub
+	same, err := pool.GetHub(ctx, settings)
+	require.NoError(t, err)
+	assert.Same(t, hub, same)
+	pool.ReleaseHub(settings)
+
+	require.NoError(t, hub.DisableServer("pool_sync_first"))
+	_, err = pool.GetHubByServerName("pool_sync_first")
+	assert.Error(t, err)
+
+	require.NoError(t, hub.RemoveServer("pool_sync_second"))
+	_, err = pool.GetHubByServerName("pool_sync_second")
+	assert.Error(t, err)
+}
+
+// TestRemoveServerKeepsSharedConnection 测试一个hub移除服务器时不关闭共享池中连接的其他hub仍在使用的客户端
+func TestRemoveServerKeepsSharedConnection(t *testing.T) {
+	ctx := context.Background()
+	pool := GetConnectionPool()
+	url := newTestHTTPServer(t, newMultiToolServer("read"))
+	settings := func() *MCPSettings {
+		return &MCPSettings{
+			MCPServers: map[string]*ServerConfig{
+				"pool_remove_shared": {Transport: transportHTTP1, URL: url},
+			},
+		}
+	}
+
+	pooled, err := pool.GetHub(ctx, settings())
+	require.NoError(t, err)
+	t.Cleanup(func() { pool.ForceCloseHub(settings()) })
+	sharing, err := NewMCPHubFromSettings(ctx, settings())
+	require.NoError(t, err)
+	t.Cleanup(func() { sharing.CloseServers() })
+
+	pooledClient, err := pooled.GetClient("pool_remove_shared")
+	require.NoError(t, err)
+	sharedClient, err := sharing.GetClient("pool_remove_shared")
+	require.NoError(t, err)
+	require.Same(t, pooledClient, sharedClient, "第二个hub应复用池中的连接")
+
+	require.NoError(t, sharing.RemoveServer("pool_remove_shared"))
+	result, err := pooled.InvokeTool(ctx, "pool_remove_shared_read", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "read", result)
+	client, err := pooled.GetClient("pool_remove_shared")
+	require.NoError(t, err)
+	assert.Same(t, pooledClient, client, "共享的客户端未被关闭，不需要重连")
+
+	// 最后一个使用者移除服务器时关闭客户端
+	require.NoError(t, pooled.RemoveServer("pool_remove_shared"))
+	_, err = pooledClient.ListTools(ctx, mcp.ListToolsRequest{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,133 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ServerState describes the lifecycle state of a configured MCP server inside a hub.
+type ServerState string
+
+// Server state constants
+const (
+	ServerStateConnected    ServerState = "connected"    // 已连接，工具已注册
+	ServerStateReconnecting ServerState = "reconnecting" // 连接断开，正在重连
+	ServerStateFailed       ServerState = "failed"       // 连接、初始化或发现工具失败
+	ServerStateDisabled     ServerState = "disabled"     // 配置中已禁用，未连接
+	ServerStateIdle         ServerState = "idle"         // 延迟连接，工具已从清单注册，首次调用时连接
+	ServerStateConnecting   ServerState = "connecting"   // 工具已从缓存注册，正在后台连接
+)
+
+// Startup stage constants identify which step of bringing a server up failed.
+const (
+	StageConnect    = "connect"    // 创建并启动客户端
+	StageInitialize = "initialize" // MCP 初始化握手
+	StageDiscover   = "discover"   // 列出并注册工具
+)
+
+// ServerError is returned for a server that failed to come up.
+// It records the server name and the startup stage so callers can tell
+// a missing binary apart from a handshake or tool discovery problem.
+type ServerError struct {
+	Server string // Server name
+	Stage  string // One of StageConnect, StageInitialize, StageDiscover
+	Err    error  // Underlying error
+}
+
+// Error implements the error interface.
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("服务器 %s %s 阶段失败: %v", e.Server, e.Stage, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ServerError) Unwrap() error {
+	return e.Err
+}
+
+// ServerStatus reports the outcome of connecting to a single MCP server.
+// The embedded MCPTools carries the discovered tools and, for failed servers,
+// the error that stopped the server from starting.
+type ServerStatus struct {
+	MCPTools
+	State       ServerState  // Current state of the server
+	UpdatedAt   time.Time    // When the status was last recorded
+	ListedTools []string     // Names of all tools listed by the server, before allowedTools/excludedTools filtering
+	LastExit    *ProcessExit // How the last process of a stdio server ended, kept across reconnects
+	Process     *ProcessInfo // Running process and restarts of a stdio server, nil for other transports
+	ActiveCalls int          // Calls in flight, counted for servers with maxConcurrentCalls
+	QueuedCalls int          // Calls waiting for a free slot, counted for servers with maxConcurrentCalls
+}
+
+// setServerStatus records the status of a server. Callers must hold h.mu.
+func (h *MCPHub) setServerStatus(status ServerStatus) {
+	if h.status == nil {
+		h.status = make(map[string]*ServerStatus)
+	}
+	status.UpdatedAt = time.Now()
+	if previous, ok := h.status[status.Name]; ok && status.LastExit == nil {
+		status.LastExit = previous.LastExit
+	}
+	h.status[status.Name] = &status
+}
+
+// ServerStatuses returns a snapshot of the status of every configured server.
+// Servers that failed to connect are listed with State ServerStateFailed and a
+// non-nil Err, so callers running in degraded mode can see what is missing.
+//
+// Returns:
+//   - map[string]ServerStatus: Server status indexed by server name
+func (h *MCPHub) ServerStatuses() map[string]ServerStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := make(map[string]ServerStatus, len(h.status))
+	for name, status := range h.status {
+		result[name] = h.statusSnapshot(status)
+	}
+	return result
+}
+
+// statusSnapshot returns a copy of status with the current process info of the
+// server. Callers must hold h.mu.
+func (h *MCPHub) statusSnapshot(status *ServerStatus) ServerStatus {
+	snapshot := *status
+	if supervisor, ok := h.supervisors[status.Name]; ok {
+		info := supervisor.info()
+		snapshot.Process = &info
+	}
+	if limiter, ok := h.limiters[status.Name]; ok {
+		snapshot.ActiveCalls, snapshot.QueuedCalls = limiter.load()
+	}
+	return snapshot
+}
+
+// FailedServers returns the servers that failed to start together with the reason.
+//
+// Returns:
+//   - map[string]error: Startup error indexed by server name, empty if all servers are healthy
+func (h *MCPHub) FailedServers() map[string]error {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	result := make(map[string]error)
+	for name, status := range h.status {
+		if status.State == ServerStateFailed {
+			result[name] = status.Err
+		}
+	}
+	return result
+}
+
+// failedServerNames returns the sorted names of failed servers. Callers must hold h.mu.
+func (h *MCPHub) failedServerNames() []string {
+	var names []string
+	for name, status := range h.status {
+		if status.State == ServerStateFailed {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,106 @@
+package einomcphost
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestInprocessServer 创建一个带有echo工具的进程内MCP服务器
+func newTestInprocessServer(t *testing.T) *server.MCPServer {
+	t.Helper()
+
+	s := server.NewMCPServer("inprocess-test-server", "1.0.0")
+	s.AddTool(
+		mcp.NewTool("echo",
+			mcp.WithDescription("echo a message"),
+			mcp.WithString("message", mcp.DefaultString("hello")),
+		),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText("Echo: " + request.GetString("message", "")), nil
+		},
+	)
+	return s
+}
+
+// newTestInprocessClient 创建连接到进程内测试服务器的客户端
+func newTestInprocessClient(t *testing.T, s *server.MCPServer) *client.Client {
+	t.Helper()
+
+	cli, err := client.NewInProcessClient(s)
+	require.NoError(t, err)
+	t.Cleanup(func() { cli.Close() })
+	return cli
+}
+
+// TestDegradedStartup 测试部分服务器失败时hub仍然可以启动
+func TestDegradedStartup(t *testing.T) {
+	ctx := context.Background()
+
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"broken": {
+				Transport: transportStdio,
+				Command:   "nonexistent_command_that_should_fail",
+			},
+			"off": {
+				Transport: transportStdio,
+				Command:   "echo",
+				Disabled:  true,
+			},
+		},
+	}
+
+	cli := newTestInprocessClient(t, newTestInprocessServer(t))
+	hub, err := NewMCPHubFromSettings(ctx, settings, WithInprocessMCPClient("healthy", cli))
+	require.NoError(t, err)
+	defer hub.CloseServers()
+
+	// 健康服务器的工具可用
+	result, err := hub.InvokeTool(ctx, "healthy_echo", map[string]any{"message": "hi"})
+	require.NoError(t, err)
+	assert.Equal(t, "Echo: hi", result)
+
+	statuses := hub.ServerStatuses()
+	require.Len(t, statuses, 3)
+	assert.Equal(t, ServerStateConnected, statuses["healthy"].State)
+	assert.Len(t, statuses["healthy"].Tools, 1)
+	assert.Equal(t, ServerStateDisabled, statuses["off"].State)
+	assert.Equal(t, ServerStateFailed, statuses["broken"].State)
+
+	failed := hub.FailedServers()
+	require.Len(t, failed, 1)
+	var serverErr *ServerError
+	require.True(t, errors.As(failed["broken"], &serverErr))
+	assert.Equal(t, "broken", serverErr.Server)
+	assert.Equal(t, StageConnect, serverErr.Stage)
+}
+
+// TestStrictStartup 测试严格模式下任一服务器失败都会导致创建失败
+func TestStrictStartup(t *testing.T) {
+	ctx := context.Background()
+
+	settings := &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"broken": {
+				Transport: transportStdio,
+				Command:   "nonexistent_command_that_should_fail",
+			},
+		},
+	}
+
+	cli := newTestInprocessClient(t, newTestInprocessServer(t))
+	hub, err := NewMCPHubFromSettings(ctx, settings, WithInprocessMCPClient("healthy", cli), WithStrictStartup())
+	assert.Error(t, err)
+	assert.Nil(t, hub)
+
+	var serverErr *ServerError
+	require.True(t, errors.As(err, &serverErr))
+	assert.Equal(t, "broken", serverErr.Server)
+}
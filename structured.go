@@ -0,0 +1,153 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ToolInfoExtraOutputSchema is the schema.ToolInfo Extra key holding the
+// *openapi3.Schema converted from the tool's declared outputSchema.
+const ToolInfoExtraOutputSchema = "outputSchema"
+
+// convertOutputSchema converts the output schema declared by an MCP tool to an
+// OpenAPI v3 schema.
+//
+// Returns:
+//   - *openapi3.Schema: Converted schema, nil if the tool declares no output schema
+//   - error: Error if schema conversion fails
+func (h *MCPHub) convertOutputSchema(mcpTool mcp.Tool) (*openapi3.Schema, error) {
+	var raw []byte
+	switch {
+	case len(mcpTool.RawOutputSchema) > 0:
+		var schema map[string]any
+		if err := json.Unmarshal(mcpTool.RawOutputSchema, &schema); err != nil {
+			return nil, fmt.Errorf("反序列化工具输出模式失败: %w", err)
+		}
+		if properties, ok := schema["properties"].(map[string]any); ok {
+			stripExclusiveBounds(properties)
+		}
+		marshaled, err := json.Marshal(schema)
+		if err != nil {
+			return nil, fmt.Errorf("序列化工具输出模式失败: %w", err)
+		}
+		raw = marshaled
+	case mcpTool.OutputSchema.Type != "":
+		stripExclusiveBounds(mcpTool.OutputSchema.Properties)
+		marshaled, err := json.Marshal(mcpTool.OutputSchema)
+		if err != nil {
+			return nil, fmt.Errorf("序列化工具输出模式失败: %w", err)
+		}
+		raw = marshaled
+	default:
+		return nil, nil
+	}
+
+	outputSchema := &openapi3.Schema{}
+	if err := json.Unmarshal(raw, outputSchema); err != nil {
+		return nil, fmt.Errorf("反序列化工具输出模式失败: %w", err)
+	}
+	return outputSchema, nil
+}
+
+// renderStructuredContent returns the canonical JSON encoding of a structured tool
+// result after validating it against the tool's output schema, if one was declared.
+// Object keys are sorted so equal results always produce the same output.
+func renderStructuredContent(toolName string, outputSchema *openapi3.Schema, structured any) (string, error) {
+	data, err := json.Marshal(structured)
+	if err != nil {
+		return "", fmt.Errorf("MCP: 序列化工具 %s 的结构化结果失败: %w", toolName, err)
+	}
+
+	// 统一转换为通用JSON值，保证键有序且数字类型与校验器一致
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return "", fmt.Errorf("MCP: 解析工具 %s 的结构化结果失败: %w", toolName, err)
+	}
+
+	if outputSchema != nil {
+		if err := outputSchema.VisitJSON(value, openapi3.VisitAsResponse(), openapi3.MultiErrors()); err != nil {
+			return "", fmt.Errorf("MCP: 工具 %s 的结构化结果不符合输出模式: %w", toolName, err)
+		}
+	}
+
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("MCP: 序列化工具 %s 的结构化结果失败: %w", toolName, err)
+	}
+	return string(canonical), nil
+}
+
+// invocationOutcomeKey is the context key of the invocationRecord filled in by a
+// tool invoker.
+type invocationOutcomeKey struct{}
+
+// invocationRecord tells InvokeToolAs what the string returned by a tool stands for.
+type invocationRecord struct {
+	denied     bool // The output is the approver's denial message
+	structured bool // The output is the tool's validated structured content
+}
+
+// invocationOutcome returns the record of the current invocation, a discarded one
+// if the caller did not ask for it.
+func invocationOutcome(ctx context.Context) *invocationRecord {
+	if record, ok := ctx.Value(invocationOutcomeKey{}).(*invocationRecord); ok {
+		return record
+	}
+	return &invocationRecord{}
+}
+
+// InvokeToolAs invokes a tool and decodes its result into a value of type T.
+// Tools that return structuredContent are decoded from the validated structured
+// result; for other tools the text output must itself be JSON. A call denied by
+// the approver returns ErrToolCallDenied instead of decoding the denial message.
+//
+// Generic methods are not supported by Go, so this is a package function taking
+// the hub as its second argument.
+//
+// Parameters:
+//   - ctx: Context for the operation
+//   - h: Hub that owns the tool
+//   - toolName: Name of the tool to invoke
+//   - arguments: Arguments to pass to the tool
+//
+// Returns:
+//   - T: Decoded tool result
+//   - error: Error if the invocation fails or the result cannot be decoded into T
+func InvokeToolAs[T any](ctx context.Context, h *MCPHub, toolName string, arguments map[string]any) (T, error) {
+	var result T
+
+	record := &invocationRecord{}
+	output, err := h.InvokeTool(context.WithValue(ctx, invocationOutcomeKey{}, record), toolName, arguments)
+	if err != nil {
+		return result, err
+	}
+	if record.denied {
+		return result, fmt.Errorf("工具 %s: %w: %s", toolName, ErrToolCallDenied, output)
+	}
+	if !record.structured && !json.Valid([]byte(output)) {
+		return result, fmt.Errorf("工具 %s 没有返回结构化结果，文本结果也不是JSON: %q", toolName, output)
+	}
+
+	if err := json.Unmarshal([]byte(output), &result); err != nil {
+		return result, fmt.Errorf("解析工具 %s 的结果失败: %w", toolName, err)
+	}
+	return result, nil
+}
+
+// stripExclusiveBounds removes exclusiveMaximum and exclusiveMinimum from schema
+// properties. Some servers emit them as numbers (JSON Schema draft 6+) while the
+// OpenAPI v3 schema expects booleans, which makes the conversion fail.
+func stripExclusiveBounds(properties map[string]any) {
+	for _, v := range properties {
+		switch values := v.(type) {
+		case map[string]any:
+			delete(values, "exclusiveMaximum")
+			delete(values, "exclusiveMinimum")
+		}
+	}
+}
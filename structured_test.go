@@ -0,0 +1,109 @@
+package einomcphost
+
+import (
+	"context"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type weatherReport struct {
+	City        string  `json:"city" jsonschema:"required"`
+	Temperature float64 `json:"temperature" jsonschema:"required"`
+}
+
+// newStructuredHub 创建一个工具返回结构化结果的hub
+func newStructuredHub(t *testing.T, opts ...MCPHubOption) *MCPHub {
+	t.Helper()
+
+	s := server.NewMCPServer("structured-test-server", "1.0.0")
+	s.AddTool(
+		mcp.NewTool("weather", mcp.WithOutputSchema[weatherReport]()),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultStructured(weatherReport{City: "Paris", Temperature: 21.5}, "Paris: 21.5°C"), nil
+		},
+	)
+	s.AddTool(
+		mcp.NewTool("broken", mcp.WithOutputSchema[weatherReport]()),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultStructured(map[string]any{"city": 42}, "broken"), nil
+		},
+	)
+
+	opts = append(opts, WithInprocessMCPClient("structured", newTestInprocessClient(t, s)))
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{MCPServers: map[string]*ServerConfig{}}, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+	return hub
+}
+
+// TestStructuredContent 测试结构化结果以规范JSON返回并按输出模式校验
+func TestStructuredContent(t *testing.T) {
+	ctx := context.Background()
+	hub := newStructuredHub(t)
+
+	result, err := hub.InvokeTool(ctx, "structured_weather", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `{"city":"Paris","temperature":21.5}`, result)
+
+	_, err = hub.InvokeTool(ctx, "structured_broken", nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "不符合输出模式")
+
+	// 输出模式通过ToolInfo.Extra暴露
+	tools, err := hub.GetEinoTools(ctx, []string{"structured_weather"})
+	require.NoError(t, err)
+	require.Len(t, tools, 1)
+	info, err := tools[0].Info(ctx)
+	require.NoError(t, err)
+	require.IsType(t, &openapi3.Schema{}, info.Extra[ToolInfoExtraOutputSchema])
+}
+
+// TestInvokeToolAs 测试将结构化结果解码为类型化的值
+func TestInvokeToolAs(t *testing.T) {
+	ctx := context.Background()
+	hub := newStructuredHub(t)
+
+	report, err := InvokeToolAs[weatherReport](ctx, hub, "structured_weather", nil)
+	require.NoError(t, err)
+	assert.Equal(t, weatherReport{City: "Paris", Temperature: 21.5}, report)
+
+	// 偏好文本时返回文本内容，无法解码为JSON
+	textHub := newStructuredHub(t, WithContentPolicy(ContentPolicy{PreferText: true}))
+	result, err := textHub.InvokeTool(ctx, "structured_weather", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "Paris: 21.5°C", result)
+	_, err = InvokeToolAs[weatherReport](ctx, textHub, "structured_weather", nil)
+	assert.ErrorContains(t, err, "没有返回结构化结果")
+
+	// 被拒绝的调用返回明确的错误，而不是解码拒绝信息
+	deny := ToolApproverFunc(func(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+		return ApprovalDecision{Message: "not now"}, nil
+	})
+	deniedHub := newStructuredHub(t, WithToolApprover(deny))
+	_, err = InvokeToolAs[weatherReport](ctx, deniedHub, "structured_weather", nil)
+	assert.ErrorIs(t, err, ErrToolCallDenied)
+	assert.ErrorContains(t, err, "not now")
+}
+
+// TestRawOutputSchemaExclusiveBounds 测试原始输出模式中的数值型排他边界与OutputSchema一样被处理
+func TestRawOutputSchemaExclusiveBounds(t *testing.T) {
+	hub := &MCPHub{}
+	raw := mcp.NewToolWithRawSchema("weather", "", nil)
+	raw.RawOutputSchema = []byte(`{"type": "object", "properties": {"temperature": {"type": "number", "exclusiveMinimum": -274}}}`)
+	schema, err := hub.convertOutputSchema(raw)
+	require.NoError(t, err)
+	require.NoError(t, schema.VisitJSON(map[string]any{"temperature": 21.5}))
+
+	typed := mcp.NewTool("weather")
+	typed.OutputSchema = mcp.ToolOutputSchema{Type: "object", Properties: map[string]any{
+		"temperature": map[string]any{"type": "number", "exclusiveMinimum": -274},
+	}}
+	schema, err = hub.convertOutputSchema(typed)
+	require.NoError(t, err)
+	require.NoError(t, schema.VisitJSON(map[string]any{"temperature": 21.5}))
+}
@@ -0,0 +1,462 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+)
+
+// DefaultStderrLines is the number of stderr lines kept per server by hubs that do
+// not configure WithStderrLines.
+const DefaultStderrLines = 100
+
+// stderrErrorLines is the number of stderr lines added to error messages.
+const stderrErrorLines = 10
+
+// RestartMode selects which exits of a stdio server process lead to a restart.
+type RestartMode string
+
+// Restart mode constants
+const (
+	RestartNever     RestartMode = "never"      // 进程退出后不重启
+	RestartOnFailure RestartMode = "on-failure" // 只在退出码非0或被信号结束时重启
+	RestartAlways    RestartMode = "always"     // 任何退出都重启
+)
+
+// RestartPolicy controls whether the process of a stdio server is restarted after it
+// exits. Restarts are carried out like reconnects, with the hub's ReconnectPolicy, so
+// disabling reconnects also disables restarts.
+type RestartPolicy struct {
+	Mode        RestartMode   `json:"mode,omitempty" yaml:"mode,omitempty"`               // When to restart, defaults to always
+	MaxRestarts int           `json:"maxRestarts,omitempty" yaml:"maxRestarts,omitempty"` // Restarts allowed within Window, 0 means unlimited
+	Window      time.Duration `json:"window,omitempty" yaml:"window,omitempty"`           // Period in which MaxRestarts is counted, 0 counts every restart
+}
+
+// DefaultRestartPolicy is used for stdio servers without a restart setting in hubs
+// that do not configure WithRestartPolicy. It restarts every exit without limit.
+var DefaultRestartPolicy = RestartPolicy{Mode: RestartAlways}
+
+// restartPolicyAlias has the fields of RestartPolicy without its JSON methods.
+type restartPolicyAlias RestartPolicy
+
+// UnmarshalJSON decodes a restart policy. window accepts a number of seconds or a Go
+// duration string such as "10m".
+func (p *RestartPolicy) UnmarshalJSON(data []byte) error {
+	aux := struct {
+		*restartPolicyAlias
+		Window json.RawMessage `json:"window,omitempty"`
+	}{restartPolicyAlias: (*restartPolicyAlias)(p)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if len(aux.Window) > 0 {
+		window, err := parseJSONDuration(aux.Window)
+		if err != nil {
+			return fmt.Errorf("window: %w", err)
+		}
+		p.Window = window
+	}
+	return nil
+}
+
+// MarshalJSON encodes a restart policy, writing the window as a duration string.
+func (p RestartPolicy) MarshalJSON() ([]byte, error) {
+	aux := struct {
+		restartPolicyAlias
+		Window string `json:"window,omitempty"`
+	}{restartPolicyAlias: restartPolicyAlias(p)}
+
+	if p.Window != 0 {
+		aux.Window = p.Window.String()
+	}
+	return json.Marshal(aux)
+}
+
+// mode returns the restart mode, always if none is set.
+func (p RestartPolicy) mode() RestartMode {
+	if p.Mode == "" {
+		return RestartAlways
+	}
+	return p.Mode
+}
+
+// restarts reports whether the policy restarts a process that ended with exit.
+// An exit that was not observed counts as a failure.
+func (p RestartPolicy) restarts(exit *ProcessExit) bool {
+	switch p.mode() {
+	case RestartNever:
+		return false
+	case RestartOnFailure:
+		return exit == nil || exit.ExitCode != 0
+	default:
+		return true
+	}
+}
+
+// clone returns a copy of p, or nil if p is nil.
+func (p *RestartPolicy) clone() *RestartPolicy {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
+}
+
+// WithRestartPolicy sets the restart policy of stdio servers that do not configure
+// their own restart setting.
+func WithRestartPolicy(policy RestartPolicy) MCPHubOption {
+	return func(h *MCPHub) {
+		h.restartPolicy = policy
+	}
+}
+
+// WithStderrLines sets how many of the last stderr lines of each stdio server the hub
+// keeps for error messages and Diagnostics. 0 disables keeping them; the lines are
+// still logged.
+func WithStderrLines(n int) MCPHubOption {
+	return func(h *MCPHub) {
+		h.stderrLines = n
+	}
+}
+
+// validateRestartPolicy checks the restart setting of a server.
+func validateRestartPolicy(name string, server *ServerConfig) error {
+	if server.Restart == nil {
+		return nil
+	}
+	if server.Transport != transportStdio {
+		return fmt.Errorf(errMsgRestartNotSupported, name)
+	}
+	switch server.Restart.Mode {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf(errMsgRestartMode, name, server.Restart.Mode)
+	}
+	if server.Restart.MaxRestarts < 0 || server.Restart.Window < 0 {
+		return fmt.Errorf(errMsgRestartInvalid, name)
+	}
+	return nil
+}
+
+// ProcessInfo describes the process of a stdio server as tracked by the hub.
+type ProcessInfo struct {
+	PID       int       // Process ID of the running process, 0 if none is running
+	StartedAt time.Time // When the running process was started
+	Restarts  int       // Restarts after the process exited since the server was last connected
+}
+
+// Uptime returns how long the running process has been up, 0 if none is running.
+func (p ProcessInfo) Uptime() time.Duration {
+	if p.PID == 0 {
+		return 0
+	}
+	return time.Since(p.StartedAt)
+}
+
+// ServerDiagnostics is the status of a server together with the recent output of
+// its process.
+type ServerDiagnostics struct {
+	ServerStatus
+	Stderr []string // Last lines written to stderr by the server's processes, oldest first
+}
+
+// Diagnostics returns the status of a server and the last lines its stdio processes
+// wrote to stderr, kept across restarts.
+//
+// Parameters:
+//   - serverName: Name of the server
+//
+// Returns:
+//   - ServerDiagnostics: Status and stderr of the server
+//   - error: Error if the hub has no status for the server
+func (h *MCPHub) Diagnostics(serverName string) (ServerDiagnostics, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	status, ok := h.status[serverName]
+	if !ok {
+		return ServerDiagnostics{}, fmt.Errorf("未找到服务器: %s", serverName)
+	}
+	diagnostics := ServerDiagnostics{ServerStatus: h.statusSnapshot(status)}
+	if supervisor, ok := h.supervisors[serverName]; ok {
+		diagnostics.Stderr = supervisor.tail(supervisor.capacity())
+	}
+	return diagnostics, nil
+}
+
+// serverSupervisor tracks the processes of a stdio server across restarts: the
+// running process, the restarts and the last lines written to stderr.
+type serverSupervisor struct {
+	mu        sync.Mutex
+	stderr    []string // Ring buffer of stderr lines
+	next      int      // Index of the next line in stderr
+	count     int      // Number of lines in stderr
+	pid       int
+	startedAt time.Time
+	process   *stdioProcess // Most recently started process
+	restarts  int
+	history   []time.Time // Times of the restarts counted against MaxRestarts
+	stopped   error       // Set when the restart policy gave up on the server
+}
+
+// newServerSupervisor returns a supervisor keeping the last lines stderr lines.
+func newServerSupervisor(lines int) *serverSupervisor {
+	if lines < 0 {
+		lines = 0
+	}
+	return &serverSupervisor{stderr: make([]string, lines)}
+}
+
+// supervisor returns the supervisor of a stdio server, creating it if needed.
+func (h *MCPHub) supervisor(serverName string) *serverSupervisor {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.supervisors == nil {
+		h.supervisors = make(map[string]*serverSupervisor)
+	}
+	s, ok := h.supervisors[serverName]
+	if !ok {
+		s = newServerSupervisor(h.stderrLines)
+		h.supervisors[serverName] = s
+	}
+	return s
+}
+
+// capacity returns the number of stderr lines kept.
+func (s *serverSupervisor) capacity() int {
+	return len(s.stderr)
+}
+
+// appendLine adds a stderr line, dropping the oldest line if the buffer is full.
+func (s *serverSupervisor) appendLine(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.stderr) == 0 {
+		return
+	}
+	s.stderr[s.next] = line
+	s.next = (s.next + 1) % len(s.stderr)
+	if s.count < len(s.stderr) {
+		s.count++
+	}
+}
+
+// tail returns up to n of the last stderr lines, oldest first.
+func (s *serverSupervisor) tail(n int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n > s.count {
+		n = s.count
+	}
+	if n <= 0 {
+		return nil
+	}
+	lines := make([]string, n)
+	start := s.next - n + len(s.stderr)
+	for i := range lines {
+		lines[i] = s.stderr[(start+i)%len(s.stderr)]
+	}
+	return lines
+}
+
+// processStarted records the running process.
+func (s *serverSupervisor) processStarted(pid int, process *stdioProcess) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pid = pid
+	s.startedAt = time.Now()
+	s.process = process
+}
+
+// processOf returns the most recently started process if mcpClient talks to it.
+func (s *serverSupervisor) processOf(mcpClient client.MCPClient) *stdioProcess {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.process == nil || client.MCPClient(s.process.client) != mcpClient {
+		return nil
+	}
+	return s.process
+}
+
+// processExited clears the running process if it is pid. A process replaced by a
+// newer one is ignored.
+func (s *serverSupervisor) processExited(pid int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pid == pid {
+		s.pid = 0
+		s.startedAt = time.Time{}
+	}
+}
+
+// info returns the running process and the restart count.
+func (s *serverSupervisor) info() ProcessInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return ProcessInfo{PID: s.pid, StartedAt: s.startedAt, Restarts: s.restarts}
+}
+
+// allowRestart applies the restart policy to an exit of the process. It counts the
+// restart and returns nil if the process is to be restarted, or the reason the
+// server stays down otherwise.
+func (s *serverSupervisor) allowRestart(policy RestartPolicy, exit *ProcessExit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var cause error = errServerProcessExited
+	if exit != nil {
+		cause = exit
+	}
+	if !policy.restarts(exit) {
+		s.stopped = fmt.Errorf("按重启策略 %s 不再重启: %w", policy.mode(), cause)
+		return s.stopped
+	}
+
+	now := time.Now()
+	if policy.MaxRestarts > 0 {
+		if policy.Window > 0 {
+			recent := s.history[:0]
+			for _, t := range s.history {
+				if now.Sub(t) < policy.Window {
+					recent = append(recent, t)
+				}
+			}
+			s.history = recent
+		}
+		if len(s.history) >= policy.MaxRestarts {
+			within := ""
+			if policy.Window > 0 {
+				within = fmt.Sprintf("在 %s 内", policy.Window)
+			}
+			s.stopped = fmt.Errorf("%s已重启 %d 次，不再重启: %w", within, len(s.history), cause)
+			return s.stopped
+		}
+		s.history = append(s.history, now)
+	}
+	s.restarts++
+	return nil
+}
+
+// stoppedErr returns why the restart policy gave up on the server, nil if it did not.
+func (s *serverSupervisor) stoppedErr() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// reset clears the restarts when the server is connected anew, for example after it
+// was enabled or reconfigured.
+func (s *serverSupervisor) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.restarts = 0
+	s.history = nil
+	s.stopped = nil
+}
+
+// serverRestartPolicy returns the restart policy of a server config.
+func (h *MCPHub) serverRestartPolicy(config *ServerConfig) RestartPolicy {
+	if config != nil && config.Restart != nil {
+		return *config.Restart
+	}
+	return h.restartPolicy
+}
+
+// superviseExit applies the restart policy once to the exit of a server process.
+// Both the stderr reader and a reconnect triggered by a failed call may notice the
+// exit; whichever comes first decides.
+//
+// Returns:
+//   - error: Why the server stays down, nil if it is to be restarted
+func (h *MCPHub) superviseExit(serverName string, process *stdioProcess, exit *ProcessExit) error {
+	process.restartOnce.Do(func() {
+		process.restartErr = h.applyRestartPolicy(serverName, process, exit)
+	})
+	return process.restartErr
+}
+
+// applyRestartPolicy decides whether an exited server process is restarted. If it is
+// not, the server's status is set to failed with the reason. Exits of processes the
+// hub replaced or closed are ignored.
+func (h *MCPHub) applyRestartPolicy(serverName string, process *stdioProcess, exit *ProcessExit) error {
+	if !h.reconnectEnabled() {
+		return nil
+	}
+
+	h.mu.RLock()
+	conn, ok := h.connections[serverName]
+	live := ok && !h.closed && conn.Client == client.MCPClient(process.client)
+	var config *ServerConfig
+	if live {
+		config = conn.Config
+	}
+	h.mu.RUnlock()
+	if !live {
+		return nil
+	}
+
+	err := process.supervisor.allowRestart(h.serverRestartPolicy(config), exit)
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("服务器 %s %w", serverName, err)
+
+	h.mu.Lock()
+	if current, ok := h.connections[serverName]; ok && current.Client == client.MCPClient(process.client) {
+		status := ServerStatus{MCPTools: MCPTools{Name: serverName, Err: err}, State: ServerStateFailed, LastExit: exit}
+		if previous, ok := h.status[serverName]; ok {
+			status.ListedTools = previous.ListedTools
+		}
+		h.setServerStatus(status)
+	}
+	h.mu.Unlock()
+
+	logf("%v", err)
+	h.emitEvent(HubEvent{Type: EventServerStopped, Server: serverName, Err: err})
+	return err
+}
+
+// checkRestart applies the restart policy before a reconnect of the server behind
+// mcpClient. It returns an error if the restart policy keeps the server down.
+func (h *MCPHub) checkRestart(serverName string, mcpClient client.MCPClient) error {
+	h.mu.RLock()
+	supervisor := h.supervisors[serverName]
+	h.mu.RUnlock()
+	if supervisor == nil {
+		return nil
+	}
+	if err := supervisor.stoppedErr(); err != nil {
+		// 重启策略已放弃该服务器，等待重新启用或重新配置
+		return err
+	}
+	process := supervisor.processOf(mcpClient)
+	if process == nil {
+		return nil
+	}
+	exit := process.exitResult()
+	if exit == nil {
+		// 进程仍在运行，例如没有响应
+		return nil
+	}
+	return h.superviseExit(serverName, process, exit)
+}
+
+// formatStderr formats stderr lines for an error message.
+func formatStderr(lines []string) string {
+	return "stderr:\n  " + strings.Join(lines, "\n  ")
+}
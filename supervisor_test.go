@@ -0,0 +1,201 @@
+package einomcphost
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastReconnect 测试中快速重连的策略
+var fastReconnect = ReconnectPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, Multiplier: 1}
+
+// newSupervisedHub 创建连接测试服务器的hub，服务器名为 "proc"
+func newSupervisedHub(t *testing.T, serverPath string, restart *RestartPolicy, opts ...MCPHubOption) *MCPHub {
+	t.Helper()
+	opts = append([]MCPHubOption{WithStrictStartup(), WithReconnectPolicy(fastReconnect)}, opts...)
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"proc": {
+				Transport: transportStdio,
+				Command:   serverPath,
+				Env:       map[string]string{processToolsEnv: "1"},
+				Restart:   restart,
+			},
+		},
+	}, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+	return hub
+}
+
+// exitServer 让测试服务器以指定退出码退出
+func exitServer(t *testing.T, hub *MCPHub, code int) {
+	t.Helper()
+	assert.Equal(t, "exiting", invokeText(t, hub, "proc_exit", map[string]any{"code": code}))
+}
+
+// waitRestarted 等待服务器重启到第 restarts 次并重新连接
+func waitRestarted(t *testing.T, hub *MCPHub, restarts int) ServerStatus {
+	t.Helper()
+	var status ServerStatus
+	require.Eventually(t, func() bool {
+		status = hub.ServerStatuses()["proc"]
+		return status.State == ServerStateConnected && status.Process.Restarts == restarts && status.Process.PID != 0
+	}, 15*time.Second, 20*time.Millisecond)
+	return status
+}
+
+// TestSupervisorRestartLimit 测试崩溃后重启以及窗口内的重启次数限制
+func TestSupervisorRestartLimit(t *testing.T) {
+	serverPath := buildTestServer(t)
+	var (
+		mu      sync.Mutex
+		stopped []HubEvent
+	)
+	hub := newSupervisedHub(t, serverPath, &RestartPolicy{Mode: RestartOnFailure, MaxRestarts: 1, Window: time.Minute},
+		WithEventHandler(func(e HubEvent) {
+			if e.Type == EventServerStopped {
+				mu.Lock()
+				stopped = append(stopped, e)
+				mu.Unlock()
+			}
+		}))
+
+	status := hub.ServerStatuses()["proc"]
+	require.NotNil(t, status.Process)
+	firstPID := status.Process.PID
+	assert.Positive(t, firstPID)
+	assert.Positive(t, status.Process.Uptime())
+
+	exitServer(t, hub, 3)
+	status = waitRestarted(t, hub, 1)
+	assert.NotEqual(t, firstPID, status.Process.PID)
+	require.NotNil(t, status.LastExit)
+	assert.Equal(t, 3, status.LastExit.ExitCode)
+	assert.Equal(t, "4", invokeText(t, hub, "proc_sum", map[string]any{"a": 2, "b": 2}))
+
+	// 窗口内第二次崩溃超过限制，不再重启
+	exitServer(t, hub, 3)
+	require.Eventually(t, func() bool {
+		return hub.ServerStatuses()["proc"].State == ServerStateFailed
+	}, 15*time.Second, 20*time.Millisecond)
+
+	status = hub.ServerStatuses()["proc"]
+	assert.ErrorContains(t, status.Err, "已重启 1 次")
+	assert.ErrorContains(t, status.Err, "exiting with code 3")
+	require.Len(t, status.LastExit.Stderr, 2, "只包含最后一个进程的输出")
+	assert.Equal(t, "exiting with code 3", status.LastExit.Stderr[1])
+	assert.Zero(t, status.Process.PID)
+	assert.Zero(t, status.Process.Uptime())
+
+	mu.Lock()
+	assert.Len(t, stopped, 1)
+	mu.Unlock()
+
+	_, err := hub.InvokeTool(context.Background(), "proc_sum", map[string]any{"a": 1, "b": 1})
+	assert.ErrorContains(t, err, "不再重启")
+
+	diagnostics, err := hub.Diagnostics("proc")
+	require.NoError(t, err)
+	assert.Equal(t, ServerStateFailed, diagnostics.State)
+	assert.Equal(t, 2, strings.Count(strings.Join(diagnostics.Stderr, "\n"), "exiting with code 3"))
+	assert.Contains(t, diagnostics.Stderr[0], "Starting MCP server in stdio mode")
+}
+
+// TestSupervisorRestartModes 测试正常退出时各重启模式的行为
+func TestSupervisorRestartModes(t *testing.T) {
+	serverPath := buildTestServer(t)
+
+	t.Run("on-failure", func(t *testing.T) {
+		hub := newSupervisedHub(t, serverPath, &RestartPolicy{Mode: RestartOnFailure})
+		exitServer(t, hub, 0)
+		require.Eventually(t, func() bool {
+			return hub.ServerStatuses()["proc"].State == ServerStateFailed
+		}, 15*time.Second, 20*time.Millisecond)
+		assert.ErrorContains(t, hub.ServerStatuses()["proc"].Err, "按重启策略 on-failure 不再重启")
+	})
+
+	t.Run("never", func(t *testing.T) {
+		hub := newSupervisedHub(t, serverPath, nil, WithRestartPolicy(RestartPolicy{Mode: RestartNever}))
+		exitServer(t, hub, 1)
+		require.Eventually(t, func() bool {
+			return hub.ServerStatuses()["proc"].State == ServerStateFailed
+		}, 15*time.Second, 20*time.Millisecond)
+		assert.ErrorContains(t, hub.ServerStatuses()["proc"].Err, "按重启策略 never 不再重启")
+	})
+
+	t.Run("always", func(t *testing.T) {
+		hub := newSupervisedHub(t, serverPath, nil)
+		exitServer(t, hub, 0)
+		waitRestarted(t, hub, 1)
+	})
+}
+
+// TestSupervisorStartupStderr 测试启动失败的错误中包含服务器的stderr
+func TestSupervisorStartupStderr(t *testing.T) {
+	serverPath := buildTestServer(t)
+	hub, err := NewMCPHubFromSettings(context.Background(), &MCPSettings{
+		MCPServers: map[string]*ServerConfig{
+			"broken": {Transport: transportStdio, Command: serverPath, Args: []string{"bogus"}, Timeout: 5 * time.Second},
+		},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	status := hub.ServerStatuses()["broken"]
+	assert.Equal(t, ServerStateFailed, status.State)
+	assert.ErrorContains(t, status.Err, "Unknown mode: bogus")
+
+	diagnostics, err := hub.Diagnostics("broken")
+	require.NoError(t, err)
+	require.NotEmpty(t, diagnostics.Stderr)
+	assert.Contains(t, diagnostics.Stderr[len(diagnostics.Stderr)-1], "Unknown mode: bogus")
+
+	_, err = hub.Diagnostics("missing")
+	assert.Error(t, err)
+}
+
+// TestServerSupervisorStderrRing 测试stderr环形缓冲区只保留最后的行
+func TestServerSupervisorStderrRing(t *testing.T) {
+	supervisor := newServerSupervisor(3)
+	assert.Empty(t, supervisor.tail(3))
+	for _, line := range []string{"a", "b", "c", "d", "e"} {
+		supervisor.appendLine(line)
+	}
+	assert.Equal(t, []string{"c", "d", "e"}, supervisor.tail(10))
+	assert.Equal(t, []string{"d", "e"}, supervisor.tail(2))
+
+	disabled := newServerSupervisor(0)
+	disabled.appendLine("a")
+	assert.Empty(t, disabled.tail(10))
+}
+
+// TestRestartPolicyConfig 测试重启策略的解析和校验
+func TestRestartPolicyConfig(t *testing.T) {
+	settings, err := LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server",
+		"restart": {"mode": "on-failure", "maxRestarts": 5, "window": "10m"}}}}`)
+	require.NoError(t, err)
+	assert.Equal(t, &RestartPolicy{Mode: RestartOnFailure, MaxRestarts: 5, Window: 10 * time.Minute}, settings.MCPServers["local"].Restart)
+
+	data, err := json.Marshal(settings.MCPServers["local"].Restart)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"mode": "on-failure", "maxRestarts": 5, "window": "10m0s"}`, string(data))
+
+	_, err = LoadSettingsFromString(`{"mcpServers": {"remote": {"url": "https://example.org/mcp", "restart": {"mode": "always"}}}}`)
+	assert.ErrorContains(t, err, "restart requires the stdio transport")
+	_, err = LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "restart": {"mode": "sometimes"}}}}`)
+	assert.ErrorContains(t, err, "unsupported restart mode")
+	_, err = LoadSettingsFromString(`{"mcpServers": {"local": {"command": "server", "restart": {"maxRestarts": -1}}}}`)
+	assert.ErrorContains(t, err, "must not be negative")
+
+	// 修改重启策略不需要重新连接
+	changed := *settings.MCPServers["local"]
+	changed.Restart = &RestartPolicy{Mode: RestartNever}
+	assert.False(t, connectionChanged(settings.MCPServers["local"], &changed))
+}
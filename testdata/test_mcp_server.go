@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -60,6 +63,102 @@ func main() {
 		},
 	)
 
+	// 进程控制测试用的工具，只在进程自己的环境变量中开启，避免影响其他测试的工具数量
+	if os.Getenv("MCP_TEST_PROCESS_TOOLS") == "1" {
+		s.AddTool(
+			mcp.NewTool("getenv",
+				mcp.WithDescription("return an environment variable"),
+				mcp.WithString("name", mcp.Required()),
+			),
+			func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				name := request.GetArguments()["name"].(string)
+				value, ok := os.LookupEnv(name)
+				if !ok {
+					return mcp.NewToolResultText("<unset>"), nil
+				}
+				return mcp.NewToolResultText(value), nil
+			},
+		)
+
+		s.AddTool(
+			mcp.NewTool("getwd",
+				mcp.WithDescription("return the working directory"),
+			),
+			func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				dir, err := os.Getwd()
+				if err != nil {
+					return nil, err
+				}
+				return mcp.NewToolResultText(dir), nil
+			},
+		)
+
+		s.AddTool(
+			mcp.NewTool("spin",
+				mcp.WithDescription("burn CPU until the process is stopped"),
+			),
+			func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				for i := 0; ; i++ {
+					if ctx.Err() != nil {
+						return mcp.NewToolResultText(fmt.Sprint(i)), nil
+					}
+				}
+			},
+		)
+
+		s.AddTool(
+			mcp.NewTool("limits",
+				mcp.WithDescription("return a resource limit of the process"),
+				mcp.WithString("name", mcp.Required()),
+			),
+			func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				name := request.GetArguments()["name"].(string)
+				// 只在Linux上可读
+				limits, _ := os.ReadFile("/proc/self/limits")
+				for _, line := range strings.Split(string(limits), "\n") {
+					if strings.HasPrefix(line, name) {
+						return mcp.NewToolResultText(strings.Join(strings.Fields(line), " ")), nil
+					}
+				}
+				return mcp.NewToolResultText("<unknown>"), nil
+			},
+		)
+
+		var sleeping atomic.Int64
+		s.AddTool(
+			mcp.NewTool("sleep",
+				mcp.WithDescription("sleep and return the number of calls in flight when it started"),
+				mcp.WithNumber("ms", mcp.Required()),
+			),
+			func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				inFlight := sleeping.Add(1)
+				defer sleeping.Add(-1)
+				ms := request.GetArguments()["ms"].(float64)
+				select {
+				case <-time.After(time.Duration(ms) * time.Millisecond):
+				case <-ctx.Done():
+				}
+				return mcp.NewToolResultText(fmt.Sprint(inFlight)), nil
+			},
+		)
+
+		s.AddTool(
+			mcp.NewTool("exit",
+				mcp.WithDescription("exit the process with the given code shortly after replying"),
+				mcp.WithNumber("code", mcp.Required()),
+			),
+			func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				code := int(request.GetArguments()["code"].(float64))
+				go func() {
+					time.Sleep(100 * time.Millisecond)
+					fmt.Fprintf(os.Stderr, "exiting with code %d\n", code)
+					os.Exit(code)
+				}()
+				return mcp.NewToolResultText("exiting"), nil
+			},
+		)
+	}
+
 	// 根据模式启动服务器
 	switch mode {
 	case "stdio":
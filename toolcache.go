@@ -0,0 +1,224 @@
+// package einomcphost provides MCP (Model Context Protocol) server management functionality.
+package einomcphost
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// toolCacheVersion is the format version of the tool cache file. Files of another
+// version are ignored and replaced.
+const toolCacheVersion = 1
+
+// toolCacheEntry is what a server reported when it was last connected.
+type toolCacheEntry struct {
+	ProtocolVersion string                 `json:"protocolVersion,omitempty"`
+	ServerInfo      mcp.Implementation     `json:"serverInfo"`
+	Capabilities    mcp.ServerCapabilities `json:"capabilities"`
+	Tools           []mcp.Tool             `json:"tools"`
+	UpdatedAt       time.Time              `json:"updatedAt"`
+}
+
+// toolCacheFile is the on-disk format of the tool cache.
+type toolCacheFile struct {
+	Version int                       `json:"version"`
+	Servers map[string]toolCacheEntry `json:"servers"` // Indexed by toolCacheKey
+}
+
+// toolCache persists the tools of servers across hubs and processes, keyed by a hash
+// of each server's connection settings. It is safe for concurrent use; a nil cache
+// stores nothing.
+type toolCache struct {
+	path string
+
+	mu      sync.Mutex                // Protects loaded, entries and dirty
+	loaded  bool                      // Whether the file has been read
+	entries map[string]toolCacheEntry // Known entries indexed by toolCacheKey
+	dirty   bool                      // Whether entries changed since the last save
+
+	saveMu sync.Mutex // Serializes writes of the file
+}
+
+// WithToolCache keeps the tools, server info and capabilities of every server in a
+// file at path, keyed by a hash of the server's connection settings. A server found
+// in the cache has its tools registered immediately and is connected in the
+// background, so a new hub is ready without waiting for Initialize and ListTools.
+// Lazy servers use the cache as their tool manifest. Entries are updated whenever a
+// server lists its tools, including after a tools/list_changed notification.
+//
+// Parameters:
+//   - path: Cache file, created with its directory if missing; see DefaultToolCachePath
+func WithToolCache(path string) MCPHubOption {
+	return func(h *MCPHub) {
+		h.toolCache = &toolCache{path: path}
+	}
+}
+
+// DefaultToolCachePath returns the tool cache file in the user's cache directory,
+// for use with WithToolCache.
+//
+// Returns:
+//   - string: Path of the cache file
+//   - error: Error if the user's cache directory is unknown
+func DefaultToolCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "einomcphost", "tools.json"), nil
+}
+
+// toolCacheKey returns the cache key of a server config. Settings that do not change
+// what the server lists, such as timeouts and tool filters, are left out, so
+// changing them keeps the entry.
+func toolCacheKey(config *ServerConfig) string {
+	c := connectionSettings(config)
+	c.AllowedTools = nil
+	c.ExcludedTools = nil
+	c.Disabled = false
+	data, err := json.Marshal(c)
+	if err != nil {
+		// 配置均可序列化，不会发生；退回到结构体的文本形式
+		data = []byte(fmt.Sprintf("%#v", c))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// load reads the cache file once. Callers must hold c.mu.
+func (c *toolCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	entries, err := c.readFile()
+	if err != nil {
+		logf("读取工具缓存 %s 失败，忽略缓存: %v", c.path, err)
+	}
+	c.entries = entries
+}
+
+// readFile returns the entries of the cache file, none if it does not exist.
+func (c *toolCache) readFile() (map[string]toolCacheEntry, error) {
+	entries := make(map[string]toolCacheEntry)
+	data, err := os.ReadFile(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return entries, err
+	}
+	var file toolCacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return entries, err
+	}
+	if file.Version != toolCacheVersion {
+		return entries, nil
+	}
+	for key, entry := range file.Servers {
+		entries[key] = entry
+	}
+	return entries, nil
+}
+
+// lookup returns the cached entry of a server config.
+func (c *toolCache) lookup(config *ServerConfig) (toolCacheEntry, bool) {
+	if c == nil {
+		return toolCacheEntry{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.load()
+	entry, ok := c.entries[toolCacheKey(config)]
+	return entry, ok
+}
+
+// store records what a server listed and writes the file in the background. An
+// initialize result of nil keeps the server info of the existing entry.
+func (c *toolCache) store(serverName string, config *ServerConfig, initResult *mcp.InitializeResult, tools []mcp.Tool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.load()
+	key := toolCacheKey(config)
+	entry := c.entries[key]
+	if initResult != nil {
+		if entry.ServerInfo.Version != "" && entry.ServerInfo.Version != initResult.ServerInfo.Version {
+			logf("服务器 %s 的版本已从 %s 变为 %s，更新工具缓存", serverName, entry.ServerInfo.Version, initResult.ServerInfo.Version)
+		}
+		entry.ProtocolVersion = initResult.ProtocolVersion
+		entry.ServerInfo = initResult.ServerInfo
+		entry.Capabilities = initResult.Capabilities
+	}
+	entry.Tools = append([]mcp.Tool(nil), tools...)
+	entry.UpdatedAt = time.Now()
+	c.entries[key] = entry
+	c.dirty = true
+	c.mu.Unlock()
+
+	go func() {
+		if err := c.save(); err != nil {
+			logf("写入工具缓存 %s 失败: %v", c.path, err)
+		}
+	}()
+}
+
+// save writes the cache file if entries changed. Entries other processes added to
+// the file in the meantime are kept. The file is replaced atomically.
+func (c *toolCache) save() error {
+	if c == nil {
+		return nil
+	}
+	c.saveMu.Lock()
+	defer c.saveMu.Unlock()
+
+	c.mu.Lock()
+	dirty := c.dirty
+	c.mu.Unlock()
+	if !dirty {
+		return nil
+	}
+
+	file := toolCacheFile{Version: toolCacheVersion}
+	// 读取失败时只写入本进程的条目
+	file.Servers, _ = c.readFile()
+	c.mu.Lock()
+	c.dirty = false
+	for key, entry := range c.entries {
+		file.Servers[key] = entry
+	}
+	c.mu.Unlock()
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
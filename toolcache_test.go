@@ -0,0 +1,162 @@
+package einomcphost
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readToolCache 读取工具缓存文件
+func readToolCache(t *testing.T, path string) toolCacheFile {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var file toolCacheFile
+	require.NoError(t, json.Unmarshal(data, &file))
+	return file
+}
+
+// TestToolCacheStartup 测试从工具缓存启动，并在后台连接后更新缓存
+func TestToolCacheStartup(t *testing.T) {
+	serverPath := buildTestServer(t)
+	path := filepath.Join(t.TempDir(), "cache", "tools.json")
+	settings := func() *MCPSettings {
+		return &MCPSettings{
+			MCPServers: map[string]*ServerConfig{
+				"proc": {Transport: transportStdio, Command: serverPath},
+			},
+		}
+	}
+
+	first, err := NewMCPHubFromSettings(context.Background(), settings(), WithStrictStartup(), WithToolCache(path))
+	require.NoError(t, err)
+	require.NoError(t, first.CloseServers())
+
+	file := readToolCache(t, path)
+	assert.Equal(t, toolCacheVersion, file.Version)
+	require.Len(t, file.Servers, 1)
+	key := toolCacheKey(settings().MCPServers["proc"])
+	entry := file.Servers[key]
+	assert.Equal(t, mcp.Implementation{Name: "test-mcp-server", Version: "1.0.0"}, entry.ServerInfo)
+	assert.NotNil(t, entry.Capabilities.Tools)
+	assert.Len(t, entry.Tools, 3)
+
+	// 缓存中的工具与服务器实际的工具不同，后台连接后被替换
+	var sum mcp.Tool
+	for _, tool := range entry.Tools {
+		if tool.Name == "sum" {
+			sum = tool
+		}
+	}
+	require.Equal(t, "sum", sum.Name)
+	entry.Tools = []mcp.Tool{sum, mcp.NewTool("cached_only")}
+	file.Servers[key] = entry
+	data, err := json.Marshal(file)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+
+	var (
+		mu      sync.Mutex
+		changes []HubEvent
+	)
+	// 审批在后台连接完成之前进行
+	approveAll := ToolApproverFunc(func(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error) {
+		return ApprovalDecision{Approved: true}, nil
+	})
+	hub, err := NewMCPHubFromSettings(context.Background(), settings(), WithToolCache(path), WithToolApprover(approveAll),
+		WithEventHandler(func(e HubEvent) {
+			if e.Type == EventToolsChanged {
+				mu.Lock()
+				changes = append(changes, e)
+				mu.Unlock()
+			}
+		}))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+
+	// 调用等待后台连接完成
+	assert.Equal(t, "4", invokeText(t, hub, "proc_sum", map[string]any{"a": 2, "b": 2}))
+	require.Eventually(t, func() bool {
+		return hub.ServerStatuses()["proc"].State == ServerStateConnected
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.ElementsMatch(t, []string{"proc_sum", "proc_multiply", "proc_echo"}, toolMapNames(t, hub))
+
+	mu.Lock()
+	require.Len(t, changes, 1)
+	assert.Equal(t, []string{"proc_cached_only"}, changes[0].Removed)
+	mu.Unlock()
+
+	require.NoError(t, hub.CloseServers())
+	assert.Len(t, readToolCache(t, path).Servers[key].Tools, 3)
+}
+
+// TestToolCacheUnreachableServer 测试缓存中的服务器无法启动时的报告
+func TestToolCacheUnreachableServer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tools.json")
+	settings := func() *MCPSettings {
+		return &MCPSettings{
+			MCPServers: map[string]*ServerConfig{
+				"gone": {Transport: transportStdio, Command: filepath.Join(dir, "missing-server")},
+			},
+		}
+	}
+	cache := &toolCache{path: path}
+	cache.store("gone", settings().MCPServers["gone"], nil, []mcp.Tool{mcp.NewTool("sum")})
+	require.NoError(t, cache.save())
+
+	// 严格模式下不使用缓存，启动失败时创建hub失败
+	_, err := NewMCPHubFromSettings(context.Background(), settings(), WithStrictStartup(), WithToolCache(path))
+	assert.ErrorContains(t, err, "gone")
+
+	// 默认模式下先注册缓存的工具，后台连接失败后报告失败
+	hub, err := NewMCPHubFromSettings(context.Background(), settings(), WithToolCache(path))
+	require.NoError(t, err)
+	t.Cleanup(func() { hub.CloseServers() })
+	assert.ElementsMatch(t, []string{"gone_sum"}, toolMapNames(t, hub))
+	require.Eventually(t, func() bool {
+		return hub.ServerStatuses()["gone"].State == ServerStateFailed
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Contains(t, hub.FailedServers(), "gone")
+
+	_, err = hub.InvokeTool(context.Background(), "gone_sum", nil)
+	assert.ErrorContains(t, err, "MCP服务器客户端不可用")
+}
+
+// TestToolCacheKey 测试工具缓存的键只取决于影响工具列表的设置
+func TestToolCacheKey(t *testing.T) {
+	config := &ServerConfig{Transport: transportStdio, Command: "server", Args: []string{"-v"}}
+
+	tuned := *config
+	tuned.Timeout = time.Minute
+	tuned.AllowedTools = []string{"sum"}
+	tuned.ToolOverrides = map[string]ToolOverride{"sum": {Description: "add"}}
+	assert.Equal(t, toolCacheKey(config), toolCacheKey(&tuned))
+
+	for _, changed := range []ServerConfig{
+		{Transport: transportStdio, Command: "server", Args: []string{"-vv"}},
+		{Transport: transportStdio, Command: "server", Args: []string{"-v"}, Env: map[string]string{"MODE": "full"}},
+		{Transport: transportStdio, Command: "other", Args: []string{"-v"}},
+	} {
+		assert.NotEqual(t, toolCacheKey(config), toolCacheKey(&changed))
+	}
+
+	// 格式版本不同的缓存文件被忽略
+	path := filepath.Join(t.TempDir(), "tools.json")
+	data, err := json.Marshal(toolCacheFile{Version: toolCacheVersion + 1, Servers: map[string]toolCacheEntry{
+		toolCacheKey(config): {Tools: []mcp.Tool{mcp.NewTool("sum")}},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	cache := &toolCache{path: path}
+	_, ok := cache.lookup(config)
+	assert.False(t, ok)
+}